@@ -2,30 +2,87 @@ import { useCallback, useRef, useState } from "react";
 
 // JSON shape returned by class-parser WASM
 export interface ClassInfo {
+  /** Result format version, bumped when fields are added; 1 when introduced. Older WASM builds omit it. */
+  schemaVersion?: number;
   majorVersion: number;
   minorVersion: number;
   javaVersion: string;
+  /** minorVersion is 0xFFFF: compiled with preview features, requires the matching JDK */
+  previewFeatures?: boolean;
+  /** Components of a record class, in declaration order */
+  recordComponents?: RecordComponentInfo[];
   accessFlags: string[];
+  /** access_flags bits as stored in the class file */
+  rawAccessFlags: number;
+  /** Binary name, e.g. com.example.Outer$Inner */
   className: string;
+  /** Simple name ("Inner"; anonymous classes keep their number, "1") */
+  simpleName: string;
+  /** Directly enclosing class, for nested classes */
+  outerName?: string;
+  /** Nested display name, e.g. com.example.Outer.Inner */
+  displayName: string;
   superClass: string;
   interfaces: string[];
   sourceFile?: string;
+  /** Referenced classes outside java.lang and the class's own package, sorted like a source import list */
+  imports?: string[];
+  /** [min, max] source line over all methods' line number tables; absent without line numbers */
+  sourceLineSpan?: [number, number];
   fields: FieldInfo[];
   methods: MethodInfo[];
   isDeprecated?: boolean;
   signature?: string;
+  fieldCount: number;
+  methodCount: number;
+  publicMethodCount: number;
+  abstractMethodCount: number;
+  /** Class attributes the parser does not decode (e.g. ScalaSig) */
+  unknownAttributes?: string[];
+  /** Raw bytes (base64) of those attributes, with the includeRawAttributes option */
+  unknownAttributeData?: Record<string, string>;
+  /** Fields of the kotlin.Metadata annotation, for classes compiled by kotlinc */
+  kotlinMetadata?: KotlinMetadataInfo;
+  /** What the parse skipped or worked around (verbose option only) */
+  warnings?: string[];
+}
+
+export interface KotlinMetadataInfo {
+  /** Metadata kind (k): 1 class, 2 file, 3 synthetic class, 4 multi-file class facade, 5 multi-file class part */
+  kind: number;
+  kindName: string;
+  /** Metadata version (mv), e.g. "1.9.0" */
+  metadataVersion?: string;
+  /** Raw protobuf payload (d1) and its string table (d2), not decoded */
+  d1?: string[];
+  d2?: string[];
 }
 
 export interface FieldInfo {
   accessFlags: string[];
+  /** access_flags bits as stored in the class file */
+  rawAccessFlags: number;
+  name: string;
+  descriptor: string;
+  typeName: string;
+  signature?: string;
+  /** The field as Java source would declare it, e.g. "private final int count" */
+  declarationString: string;
+}
+
+export interface RecordComponentInfo {
   name: string;
   descriptor: string;
   typeName: string;
   signature?: string;
+  /** Generic type in source syntax, e.g. "java.util.List<String>" */
+  genericType?: string;
 }
 
 export interface MethodInfo {
   accessFlags: string[];
+  /** access_flags bits as stored in the class file */
+  rawAccessFlags: number;
   name: string;
   descriptor: string;
   returnType: string;
@@ -35,6 +92,63 @@ export interface MethodInfo {
   bytecode?: string;
   maxStack?: number;
   maxLocals?: number;
+  /** Distinct opcode bytes the disassembler could not decode, ascending */
+  unknownOpcodes?: number[];
+  /** Non-standard attributes nested in the method's Code attribute */
+  unknownCodeAttributes?: string[];
+  /** Distinct fields read (getfield/getstatic), as "owner.name:descriptor" */
+  fieldReads?: string[];
+  /** Distinct fields written (putfield/putstatic), as "owner.name:descriptor" */
+  fieldWrites?: string[];
+  /** tableswitch/lookupswitch instructions, decoded alongside bytecode */
+  switches?: SwitchInfo[];
+  /** structuredBytecode: bytecode as instruction objects */
+  instructions?: InstructionInfo[];
+  /** simulateStack: operand stack depth (slots) before and after each reachable instruction */
+  stackDepths?: { pc: number; before: number; after: number }[];
+  /** simulateStack: underflows, depths over maxStack and inconsistent merges */
+  stackIssues?: string[];
+  /** Distinct branch/switch target PCs, ascending */
+  branchTargets?: number[];
+  /** Exception table has a handler with a catch type (a catch clause) */
+  hasCatch?: boolean;
+  /** Exception table has a catch-all handler (finally, try-with-resources or synchronized cleanup) */
+  hasFinally?: boolean;
+  /** Interface method with an inherited body (not abstract, static or private) */
+  isDefault?: boolean;
+  /** Constructor (`<init>`) */
+  isConstructor?: boolean;
+  /** Static initializer (`<clinit>`) */
+  isStaticInitializer?: boolean;
+  /** The method as Java source would declare it (modifiers, generics, parameter names, throws) */
+  declarationString: string;
+  /** name + descriptor: unique within the class, stable for linking and selection */
+  key: string;
+}
+
+export interface InstructionInfo {
+  pc: number;
+  /** Opcode byte; 196 for wide instructions */
+  opcode: number;
+  /** e.g. "invokevirtual"; for wide instructions the widened opcode's */
+  mnemonic: string;
+  wide?: boolean;
+  /**
+   * CP index (+ invokeinterface count / multianewarray dimensions), absolute branch target,
+   * switch default then key/target pairs, local slot (+ iinc delta), or immediate value
+   */
+  operands?: number[];
+  /** Resolved constant, local variable name, "truncated" or "unknown" */
+  comment?: string;
+}
+
+export interface SwitchInfo {
+  pc: number;
+  opcode: "tableswitch" | "lookupswitch";
+  /** Absolute PC of the default branch */
+  default: number;
+  /** Case key -> absolute target PC */
+  cases: { key: number; target: number }[];
 }
 
 interface ClassParserState {
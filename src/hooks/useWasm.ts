@@ -16,6 +16,18 @@ interface WasmState {
   parseZip: ((data: Uint8Array) => Promise<ParseResult>) | null;
 }
 
+/**
+ * Parse a ParseResult from the WASM modules. Empty content is omitted from
+ * their JSON to keep it small; restore it as "" so files always have content.
+ */
+function parseResultFromJSON(jsonStr: string): ParseResult {
+  const result = JSON.parse(jsonStr) as ParseResult;
+  for (const f of result.files) {
+    f.content ??= "";
+  }
+  return result;
+}
+
 export function useWasm(): WasmState {
   const [ready, setReady] = useState(false);
   const [loading, setLoading] = useState(true);
@@ -68,7 +80,7 @@ export function useWasm(): WasmState {
     ? async (data: Uint8Array): Promise<ParseResult> => {
         // eslint-disable-next-line @typescript-eslint/no-explicit-any
         const jsonStr: string = await (window as any).__wasm_parseTgz(data);
-        return JSON.parse(jsonStr) as ParseResult;
+        return parseResultFromJSON(jsonStr);
       }
     : null;
 
@@ -77,7 +89,7 @@ export function useWasm(): WasmState {
     ? async (url: string): Promise<ParseResult> => {
         // eslint-disable-next-line @typescript-eslint/no-explicit-any
         const jsonStr: string = await (window as any).__wasm_fetchAndParseTgz(url);
-        return JSON.parse(jsonStr) as ParseResult;
+        return parseResultFromJSON(jsonStr);
       }
     : null;
 
@@ -103,7 +115,7 @@ export function useWasm(): WasmState {
     ? async (data: Uint8Array): Promise<ParseResult> => {
         // eslint-disable-next-line @typescript-eslint/no-explicit-any
         const jsonStr: string = await (window as any).__wasm_parseZip(data);
-        return JSON.parse(jsonStr) as ParseResult;
+        return parseResultFromJSON(jsonStr);
       }
     : null;
 
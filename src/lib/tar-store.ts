@@ -57,11 +57,15 @@ export class TarStore {
       return { content: "", isBinary: true };
     }
 
+    // Sparse files were read eagerly while indexing (see FileIndexEntry.sparse).
+    if (entry.sparse) {
+      return { content: entry.content ?? "", isBinary: false };
+    }
+
     // eslint-disable-next-line @typescript-eslint/no-explicit-any
     const jsonStr: string = await (window as any).__wasm_readFileFromTar(
       this.blob,
-      entry.offset,
-      entry.size,
+      { offset: entry.offset, size: entry.size },
     );
     return JSON.parse(jsonStr) as { content: string; isBinary: boolean };
   }
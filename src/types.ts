@@ -2,20 +2,111 @@
 
 export interface ParsedFile {
   path: string;
+  /** The path as stored in the archive, when the normalizePaths option changed it. */
+  originalPath?: string;
   size: number;
   isDir: boolean;
+  /** Omitted from the WASM JSON when empty; useWasm restores it as "". */
   content: string;
+  /** Binary files have empty content unless the binaryAsBase64 option is set, in which case content is base64. */
   isBinary: boolean;
+  /** When true, content holds only a preview (see the previewBytes option). */
+  truncated?: boolean;
+  /** When true, content was not loaded because the file is too large or the zip content budget ran out (isBinary is also set). */
+  skipped?: boolean;
+  /** Syntax-highlighting hint derived from the file name or shebang, e.g. "go", "shell". */
+  language?: string;
+  /** Declared package of a .java source (javaPackage option). */
+  javaPackage?: string;
   /** When true, content is not yet loaded (lazy mode). */
   lazy?: boolean;
   /** When true, this is a Java .class file that can be parsed for metadata/bytecode. */
   isClassFile?: boolean;
   /** Base64-encoded raw bytes of the file (used for .class files sent to class-parser WASM). */
   rawBase64?: string;
+  /** Tar only: a container-layer whiteout file (.wh.name or .wh..wh..opq). */
+  isWhiteout?: boolean;
+  /** Path a whiteout deletes from lower layers; for an opaque whiteout, the directory ("" for the root) it empties. */
+  whiteoutPath?: string;
+  /** The whiteout is .wh..wh..opq, hiding everything previously in its directory. */
+  opaqueWhiteout?: boolean;
+  /** Text file looks minified (.min.js/.min.css name or average line length over 500). */
+  isMinified?: boolean;
+  /** Text file carries a generated-code marker ("Code generated", "DO NOT EDIT", "@generated") near the top. */
+  isGenerated?: boolean;
+  /** Text file line breaks; absent for single-line, binary and skipped files. */
+  lineEnding?: "lf" | "crlf" | "mixed";
+  /** Indentation of the text file's lines; absent when none are indented. */
+  indentStyle?: "spaces" | "tabs" | "mixed";
+  /** Original encoding of text that had a byte-order mark; content is UTF-8 without the BOM. */
+  charset?: "utf-8" | "utf-16le" | "utf-16be";
+  /** Hex SHA-256 of the file's content (hashContent option; not set for skipped or previewed files). */
+  sha256?: string;
+  /** Zip only: byte offset of the entry's compressed data in the original archive. */
+  dataOffset?: number;
+  /** Zip only: compressed length of the entry's data. */
+  compressedSize?: number;
+  /** Zip only: CRC and sizes are in a trailing data descriptor (written by a streaming zipper). */
+  hasDataDescriptor?: boolean;
+  /** Tar only: entry type from the typeflag. */
+  entryType?: "regular" | "dir" | "symlink" | "hardlink" | "char" | "block" | "fifo" | "other";
+  /** Target path of a symbolic link entry. */
+  linkTarget?: string;
+  /** Symlink whose content/isBinary were copied from its target (resolveSymlinks option). */
+  resolved?: boolean;
 }
 
 export interface ParseResult {
+  /** Result format version, bumped when fields are added; 1 when introduced. Older WASM builds omit it. */
+  schemaVersion?: number;
+  /** Detected archive format; set by parseArchive / fetchAndParseArchive. */
+  archiveType?: "zip" | "tgz" | "tar";
   files: ParsedFile[];
+  /** Hex SHA-256 of the archive bytes as downloaded (still compressed). */
+  sha256?: string;
+  /** Number of directory entries, including any left out by the skipDirs option. */
+  dirCount?: number;
+  /** Number of files whose content was skipped for size. */
+  skippedLargeFiles?: number;
+  /** Zip only: entries exceeded the contentBudget option; the rest were listed but skipped. */
+  contentBudgetExceeded?: boolean;
+  /** tgz only: original file name from the gzip header. */
+  gzipName?: string;
+  /** tgz only: gzip header modification time (RFC 3339). */
+  gzipModTime?: string;
+  /** tgz only: gzip header comment. */
+  gzipComment?: string;
+  /** tgz only: gzip input size vs. summed tar entry sizes; ratio = uncompressed / compressed. */
+  compression?: { compressedSize: number; uncompressedSize: number; ratio: number };
+  /** Zip only: bytes precede the first entry (self-extractor, launcher stub, polyglot). */
+  hasPrefixData?: boolean;
+  prefixBytes?: number;
+  /** What the prefix looks like, e.g. "pe", "elf", "script", "zip". */
+  prefixFormat?: string;
+  /** Zip only: Android package layout, when recognised. */
+  archiveKind?: "apk" | "aar";
+  /** Locations of the key APK/AAR entries (not decoded; an APK manifest is binary XML). */
+  android?: { manifest: string; dexFiles?: string[]; resources?: string; classesJar?: string };
+  /** Jar main manifest attributes from META-INF/MANIFEST.MF. */
+  manifest?: Record<string, string>;
+  /** Jar has a META-INF/*.SF signature file and signature block (not verified). */
+  isSigned?: boolean;
+  /** Manifest entries carrying digests; changing them breaks the signature. */
+  signedEntries?: string[];
+  /** Skipped and truncated content and other notable events (verbose option only). */
+  warnings?: string[];
+  /** Tar block padding after regular files' data, in bytes (tgz and tar only). */
+  blockWaste?: number;
+  /** Number of container image layers merged by resolveImageLayers. */
+  layerCount?: number;
+  /** Groups of paths that differ only in case and collide on case-insensitive filesystems. */
+  caseCollisions?: string[][];
+  /** Groups of non-empty files with identical content (hashContent option). */
+  duplicateContent?: string[][];
+  /** Files per lower-cased extension ("" for none). */
+  byExtension?: Record<string, { count: number; totalSize: number; textCount: number }>;
+  /** Uncompressed bytes of files per directory, to dirSizeDepth components ("" for root files). */
+  dirSizes?: Record<string, number>;
 }
 
 // ===== File index for lazy-loading mode =====
@@ -27,10 +118,24 @@ export interface FileIndexEntry {
   isBinary: boolean;
   /** Byte offset within the uncompressed tar blob */
   offset: number;
+  /** Sparse file: offset/size do not map to a blob range, so it cannot be read lazily. */
+  sparse?: boolean;
+  /** Sparse text files only: content read eagerly during indexing. */
+  content?: string;
 }
 
 export interface IndexResult {
+  /** Result format version, bumped when fields are added; 1 when introduced. Older WASM builds omit it. */
+  schemaVersion?: number;
   files: FileIndexEntry[];
+  /** Set by indexTgzResume when more segments follow; pass it back as resumeToken. */
+  resume?: ResumeToken;
+}
+
+/** Where an indexTgzResume segment stopped in the uncompressed tar. */
+export interface ResumeToken {
+  offset: number;
+  entries: number;
 }
 
 // ===== Package metadata (unified across ecosystems) =====
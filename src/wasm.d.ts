@@ -5,23 +5,199 @@ declare class Go {
   run(instance: WebAssembly.Instance): Promise<void>;
 }
 
-// Global functions registered by the Go WASM modules
+// Options accepted by the archive parsing exports
+interface WasmParseOptions {
+  /** Keep only the first N bytes of each text file; longer files get truncated: true */
+  previewBytes?: number;
+  /** Keep only the first N lines of each text file (after any previewBytes cut); longer files get truncated: true */
+  previewLines?: number;
+  /** Only return entries whose path matches this (Go RE2) regular expression */
+  pathRegex?: string;
+  /** Only return entries matching one of these globs (all when empty); patterns without "/" match the base name */
+  include?: string[];
+  /** Leave out entries matching any of these globs */
+  exclude?: string[];
+  /** Zip only: only return entries stored with this compression method */
+  method?: "store" | "deflate";
+  /** Record the `package` declaration of .java sources in javaPackage */
+  javaPackage?: boolean;
+  /** Return binary files that were read in full as base64 in content (isBinary stays true) */
+  binaryAsBase64?: boolean;
+  /** Fill symlink entries with their in-archive target's content (sets resolved: true) */
+  resolveSymlinks?: boolean;
+  /** Leave directory entries out of files (still counted in dirCount) */
+  skipDirs?: boolean;
+  /** Set sha256 on fully read files and group identical ones in duplicateContent */
+  hashContent?: boolean;
+  /** Log skipped and truncated content and other notable events in warnings */
+  verbose?: boolean;
+  /** Reject the archive on entries it cannot fully represent (unknown tar entry types, encrypted zip entries) */
+  strict?: boolean;
+  /** Clean entry paths ("./a", "d/../a" -> "a"), keeping changed ones' original in originalPath */
+  normalizePaths?: boolean;
+  /** Path components dirSizes groups files by (default 1, the top-level directories) */
+  dirSizeDepth?: number;
+  /** Zip only: total uncompressed bytes read for content (default 256MB); later entries are skipped */
+  contentBudget?: number;
+}
+
+// Options accepted by the class-parser exports
+interface WasmClassOptions {
+  /** Only disassemble methods matching "name" or "name:descriptor"; others get no bytecode */
+  methodFilter?: string;
+  /** Return the bytes of undecoded class attributes as base64 in unknownAttributeData (capped at 256KB) */
+  includeRawAttributes?: boolean;
+  /** Branch/switch targets as absolute PCs (default) or signed offsets like "goto +15" */
+  offsetStyle?: "absolute" | "relative";
+  /** Constant pool operands: "both" (default) "#12 // sym", "symbol" "sym // #12", "index" "#12", "none" "sym" */
+  bytecodeComments?: "index" | "symbol" | "both" | "none";
+  /** Member references as "name:(I)V" (raw, default) or "name(int):void" (readable) */
+  descriptorStyle?: "raw" | "readable";
+  /** Order of fields and methods: class file order (default), alphabetical with constructors first, or by access */
+  sortMembers?: "declared" | "name" | "access";
+  /** Reject the class on unknown opcodes or attributes and truncated code instead of skipping them */
+  strict?: boolean;
+  /** List ACC_SUPER as "super" in the class accessFlags (hidden by default: not a source modifier) */
+  showSuperFlag?: boolean;
+  /** Also return each disassembled method's bytecode as instruction objects in instructions */
+  structuredBytecode?: boolean;
+  /** Track operand stack depth per instruction into stackDepths, annotate bytecode, report problems in stackIssues */
+  simulateStack?: boolean;
+  /** Log skipped attributes, truncated bytecode and other workarounds in warnings */
+  verbose?: boolean;
+}
+
+// Rejection from the tgz/tar parse exports when the archive ends mid-entry:
+// name is "TruncatedArchiveError", entries counts the files read before the
+// cut and partial holds them as a JSON ParseResult.
+interface WasmTruncatedArchiveError extends Error {
+  entries: number;
+  partial: string;
+}
+
+// Global functions registered by the Go WASM modules. Each takes its input
+// followed by an options object, optional unless the export needs an option
+// to know what to do; exports that walk archive entries honour the
+// pathRegex/include/exclude filters of WasmParseOptions.
 interface Window {
+  // --- shared exports (registered by every module) ---
+  /** Sniff magic bytes, returns JSON {type: "zip" | "tgz" | "tar" | "unknown"};
+   *  options is reserved and ignored */
+  __wasm_detectArchive: (data: Uint8Array, options?: Record<string, never>) => Promise<string>;
+
   // --- tgz-parser exports ---
-  /** Original: parse from in-memory bytes */
-  __wasm_parseTgz: (data: Uint8Array) => Promise<string>;
-  /** Phase 1: fetch URL and parse via streaming — no JS ArrayBuffer copy */
-  __wasm_fetchAndParseTgz: (url: string) => Promise<string>;
-  /** Phase 2: fetch URL, stream decompressed tar chunks to onChunk, return file index */
-  __wasm_indexTgz: (url: string, onChunk: (chunk: Uint8Array) => void) => Promise<string>;
+  // Parsing a truncated tgz/tar rejects with a WasmTruncatedArchiveError.
+  /** Original: parse from in-memory bytes. onProgress is called at most every 100ms and once at the end; a throw from it rejects. */
+  __wasm_parseTgz: (
+    data: Uint8Array,
+    options?: WasmParseOptions & { onProgress?: (entriesProcessed: number, bytesUncompressed: number) => void },
+  ) => Promise<string>;
+  /** Phase 1: fetch URL and parse via streaming — no JS ArrayBuffer copy.
+   *  options doubles as the fetch() init (headers, credentials, ...).
+   *  A Content-Type: application/x-tar response is parsed without gunzipping. */
+  __wasm_fetchAndParseTgz: (url: string, options?: RequestInit & WasmParseOptions) => Promise<string>;
+  /** Memory-bounded fetchAndParseTgz: calls onEntry with each ParsedFile JSON as it is read, in archive order,
+   *  and resolves with the count. Nothing is accumulated, so resolveSymlinks and duplicateContent do not apply;
+   *  a throw from onEntry stops the download and rejects. */
+  __wasm_fetchAndStreamTgz: (
+    url: string,
+    onEntry: (entry: string) => void,
+    options?: RequestInit & WasmParseOptions,
+  ) => Promise<number>;
+  /** Fetch URL and return a JSON array of entry paths only, skipping all file data */
+  __wasm_fetchAndListTgz: (url: string, options?: RequestInit) => Promise<string>;
+  /** Fetch container image layer tgzs (bottom first) and merge them, applying whiteouts; returns JSON ParseResult */
+  __wasm_resolveImageLayers: (layerUrls: string[], options?: RequestInit & WasmParseOptions) => Promise<string>;
+  /** Phase 2: fetch URL, stream decompressed tar chunks to onChunk, return file index.
+   *  options doubles as the fetch() init; peekBytes (default 512) is how much of each file binary detection reads. */
+  __wasm_indexTgz: (
+    url: string,
+    onChunk: (chunk: Uint8Array) => void,
+    options?: RequestInit & { peekBytes?: number },
+  ) => Promise<string>;
+  /** indexTgz in segments of about segmentBytes (default 256MB) uncompressed; pass the result's resume back to continue */
+  __wasm_indexTgzResume: (
+    url: string,
+    onChunk: (chunk: Uint8Array) => void,
+    resumeToken?: { offset: number; entries: number } | null,
+    options?: RequestInit & { peekBytes?: number; segmentBytes?: number },
+  ) => Promise<string>;
   /** Phase 2: read a single file from the uncompressed tar Blob */
-  __wasm_readFileFromTar: (blob: Blob, offset: number, size: number) => Promise<string>;
+  __wasm_readFileFromTar: (blob: Blob, options: { offset: number; size: number }) => Promise<string>;
+  /** Sniff the format (zip, tgz or tar) and parse from in-memory bytes */
+  __wasm_parseArchive: (data: Uint8Array, options?: WasmParseOptions) => Promise<string>;
+  /** Fetch URL, sniff the format and parse the streamed body */
+  __wasm_fetchAndParseArchive: (url: string, options?: RequestInit & WasmParseOptions) => Promise<string>;
+  /** Grep text files in an archive, returns JSON {matches: [{path, hits: [{line, text}]}], limitReached?} */
+  __wasm_searchArchive: (
+    data: Uint8Array,
+    query: string,
+    options?: { regex?: boolean; caseInsensitive?: boolean; pathGlob?: string; maxMatches?: number },
+  ) => Promise<string>;
+  /**
+   * Aggregate stats without file content, returns JSON {archiveType, fileCount, dirCount,
+   * totalUncompressed, totalCompressed, binaryCount, textCount, largestFile?: {path, size}, byExtension}
+   */
+  __wasm_archiveSummary: (data: Uint8Array, options?: WasmParseOptions) => Promise<string>;
+  /** Order-independent hash of file paths, sizes and contents, returns JSON {algo, digest, entryCount} */
+  __wasm_archiveDigest: (data: Uint8Array, options?: { algo?: "sha256" | "sha1" }) => Promise<string>;
+  /** Compare two archives' files by content hash, returns JSON {oldType, newType, added, removed, modified} */
+  __wasm_diffArchives: (oldData: Uint8Array, newData: Uint8Array, options?: WasmParseOptions) => Promise<string>;
+  /** Copy the entries passing the options' path filters (include, exclude, pathRegex) into a new .tgz */
+  __wasm_repackTgz: (data: Uint8Array, options?: WasmParseOptions) => Promise<Uint8Array>;
 
   // --- zip-parser exports ---
   /** Parse a zip archive from in-memory bytes */
-  __wasm_parseZip: (data: Uint8Array) => Promise<string>;
+  __wasm_parseZip: (data: Uint8Array, options?: WasmParseOptions) => Promise<string>;
+  /** Parse a zip, calling onEntry with each ParsedFile JSON; resolves with the entry count.
+   *  A throw from onEntry stops the walk and rejects. */
+  __wasm_parseZipStreaming: (
+    data: Uint8Array,
+    onEntry: (entry: string) => void,
+    options?: WasmParseOptions,
+  ) => Promise<number>;
+  /** Entry names from the central directory, returns a JSON array of path strings */
+  __wasm_listPaths: (data: Uint8Array, options?: WasmParseOptions) => Promise<string>;
+  /** Validate every entry's CRC-32, returns JSON {ok, corruptEntries: [{path, error}], totalEntries} */
+  __wasm_checkZip: (data: Uint8Array, options?: WasmParseOptions) => Promise<string>;
+  /** Count a jar's .class files by target Java release, returns JSON {"8": 120, "11": 45, ...} */
+  __wasm_jarClassVersions: (data: Uint8Array, options?: WasmParseOptions) => Promise<string>;
+  /** Convert a zip into an equivalent .tgz (paths, sizes, modes, modtimes, symlinks) */
+  __wasm_zipToTgz: (data: Uint8Array, options?: WasmParseOptions) => Promise<Uint8Array>;
 
   // --- class-parser exports ---
   /** Parse a Java .class file from raw bytes, returns JSON ClassInfo */
-  __wasm_parseClass: (data: Uint8Array) => Promise<string>;
+  __wasm_parseClass: (data: Uint8Array, options?: WasmClassOptions) => Promise<string>;
+  /** Parse a gzip-compressed .class file (.class.gz); rejects input that is not gzipped */
+  __wasm_parseClassGz: (data: Uint8Array, options?: WasmClassOptions) => Promise<string>;
+  /** Parse a class stored in a Blob range (e.g. a jar entry's dataOffset/compressedSize), returns JSON ClassInfo */
+  __wasm_parseClassFromBlob: (
+    blob: Blob,
+    offset: number,
+    size: number,
+    options?: WasmClassOptions & { method?: "store" | "deflate" },
+  ) => Promise<string>;
+  /**
+   * Parse many .class files, returns JSON [{class?: ClassInfo, error?: string}] in input order.
+   * Calls progress and yields to the event loop every progressEvery (default 50) classes;
+   * a throw from progress rejects.
+   */
+  __wasm_parseClasses: (
+    classes: Uint8Array[],
+    options?: WasmClassOptions & { progress?: (done: number, total: number) => void; progressEvery?: number },
+  ) => Promise<string>;
+  /** Method declarations only (no bytecode), returns JSON {className, methods: MethodInfo[]} */
+  __wasm_classSignatures: (data: Uint8Array, options?: WasmClassOptions) => Promise<string>;
+  /** javap -c style text of one method (declaration + bytecode); first match by name if no descriptor */
+  __wasm_disassembleMethod: (data: Uint8Array, options: { name: string; descriptor?: string }) => Promise<string>;
+  /** Find ldc/ldc_w loads of a string literal, returns JSON [{method: "name:descriptor", pc}] */
+  __wasm_findStringRefs: (data: Uint8Array, options: { literal: string }) => Promise<string>;
+  /** Check branch/switch targets start instructions; returns JSON {valid, methods: [{key, badTargets, branchTargets}]} */
+  __wasm_verifyClass: (data: Uint8Array, options?: WasmClassOptions) => Promise<string>;
+  /**
+   * Link every class in a jar by superclass and interfaces. Returns JSON
+   * {classes: {name: {path, superClass?, interfaces, superChain}}, subtypes: {type: [class...]},
+   *  external: [type...], errors?: {path: message}}; external types are those not in the jar.
+   */
+  __wasm_jarTypeHierarchy: (data: Uint8Array, options?: WasmParseOptions) => Promise<string>;
 }
@@ -0,0 +1,215 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+	"testing"
+
+	parser "github.com/wreulicke/classfile-parser"
+)
+
+// testPool is a small constant pool for the bytecode tests:
+//
+//	#1 Utf8 java/lang/Object   #6 Methodref #2.#5
+//	#2 Class #1                #7 String #3
+//	#3 Utf8 <init>             #8 Integer 42
+//	#4 Utf8 ()V                #9 Fieldref #2.#5
+//	#5 NameAndType #3:#4
+func testPool() *parser.ConstantPool {
+	utf8 := func(s string) *parser.ConstantUtf8 {
+		return &parser.ConstantUtf8{Length: uint16(len(s)), Bytes: []byte(s)}
+	}
+	return &parser.ConstantPool{Constants: []parser.Constant{
+		utf8("java/lang/Object"),
+		&parser.ConstantClass{NameIndex: 1},
+		utf8("<init>"),
+		utf8("()V"),
+		&parser.ConstantNameAndType{NameIndex: 3, DescriptorIndex: 4},
+		&parser.ConstantMethodref{ClassIndex: 2, NameAndTypeIndex: 5},
+		&parser.ConstantString{StringIndex: 3},
+		&parser.ConstantInteger{Bytes: 42},
+		&parser.ConstantFieldref{ClassIndex: 2, NameAndTypeIndex: 5},
+	}}
+}
+
+// Method bodies in the shapes javac emits, against testPool.
+var seedBodies = [][]byte{
+	// Object.<init>: aload_0, invokespecial #6, return
+	{0x2a, 0xb7, 0x00, 0x06, 0xb1},
+
+	// for (int i = 0; i < 10; i++) sum += i; return sum;
+	{
+		0x03, 0x3c, 0x03, 0x3d, 0x1c, 0x10, 0x0a, 0xa2, 0x00, 0x0d,
+		0x1b, 0x1c, 0x60, 0x3c, 0x84, 0x02, 0x01, 0xa7, 0xff, 0xf3,
+		0x1b, 0xac,
+	},
+
+	// switch (x) { case 0: return 1; case 1: return 2; default: return 0; }
+	{
+		0x1b, 0xaa, 0x00, 0x00,
+		0x00, 0x00, 0x00, 0x1b, // default -> 28
+		0x00, 0x00, 0x00, 0x00, // low 0
+		0x00, 0x00, 0x00, 0x01, // high 1
+		0x00, 0x00, 0x00, 0x17, // 0 -> 24
+		0x00, 0x00, 0x00, 0x19, // 1 -> 26
+		0x04, 0xac, 0x05, 0xac, 0x03, 0xac,
+	},
+
+	// The same switch over sparse keys 7 and 1000
+	{
+		0x1b, 0xab, 0x00, 0x00,
+		0x00, 0x00, 0x00, 0x1f, // default -> 32
+		0x00, 0x00, 0x00, 0x02, // npairs
+		0x00, 0x00, 0x00, 0x07, 0x00, 0x00, 0x00, 0x1b, // 7 -> 28
+		0x00, 0x00, 0x03, 0xe8, 0x00, 0x00, 0x00, 0x1d, // 1000 -> 30
+		0x04, 0xac, 0x05, 0xac, 0x03, 0xac,
+	},
+
+	// Constant pool operands: getstatic #9, ldc #7, ldc_w #8,
+	// invokevirtual #6, pop, return
+	{0xb2, 0x00, 0x09, 0x12, 0x07, 0x13, 0x00, 0x08, 0xb6, 0x00, 0x06, 0x57, 0xb1},
+
+	// wide iinc 256, 1000; wide aload 300; areturn
+	{0xc4, 0x84, 0x01, 0x00, 0x03, 0xe8, 0xc4, 0x19, 0x01, 0x2c, 0xb0},
+}
+
+// FuzzDisassemble feeds arbitrary code to the bytecode walkers, which
+// must return (the test timeout catches a walk that stops advancing)
+// without panicking. -fuzz is not supported on js/wasm, so besides the
+// javac bodies the corpus holds reproducible random code, mostly valid
+// opcodes, which a plain go test run checks as well.
+func FuzzDisassemble(f *testing.F) {
+	for _, code := range seedBodies {
+		f.Add(code, uint16(0), uint16(4))
+	}
+	r := rand.New(rand.NewSource(1))
+	for range 2000 {
+		code := make([]byte, r.Intn(48))
+		for k := range code {
+			code[k] = byte(r.Intn(202))
+			if r.Intn(8) == 0 {
+				code[k] = byte(r.Intn(256))
+			}
+		}
+		f.Add(code, uint16(r.Intn(48)), uint16(r.Intn(8)))
+	}
+	cp := testPool()
+	vars := []localVar{{Start: 0, End: 1 << 16, Slot: 1, Name: "sum"}, {Start: 4, End: 20, Slot: 2, Name: "i"}}
+	styles := []classOptions{
+		{},
+		{RelativeOffsets: true, ReadableDescriptors: true, BytecodeComments: "symbol"},
+		{BytecodeComments: "index"},
+		{BytecodeComments: "none"},
+	}
+	f.Fuzz(func(t *testing.T, code []byte, handler, maxStack uint16) {
+		insns := decodeInstructions(code, cp, vars, classOptions{})
+		if len(insns) > len(code) {
+			t.Fatalf("%d instructions from %d bytes", len(insns), len(code))
+		}
+		for k, in := range insns {
+			if in.PC >= len(code) || k > 0 && in.PC <= insns[k-1].PC {
+				t.Fatalf("instruction %d at pc %d does not advance", k, in.PC)
+			}
+		}
+
+		stack, _ := simulateStack(code, cp, []int{int(handler)}, int(maxStack))
+		for _, opts := range styles {
+			disassemble(code, cp, vars, stack, opts)
+		}
+	})
+}
+
+// operandBytes is the fixed operand length of each opcode that has
+// operands, from the JVM specification, chapter 6.
+var operandBytes = map[byte]int{
+	16: 1, 18: 1, 21: 1, 22: 1, 23: 1, 24: 1, 25: 1, // bipush, ldc, ?load
+	54: 1, 55: 1, 56: 1, 57: 1, 58: 1, 169: 1, 188: 1, // ?store, ret, newarray
+	17: 2, 19: 2, 20: 2, 132: 2, // sipush, ldc_w, ldc2_w, iinc
+	153: 2, 154: 2, 155: 2, 156: 2, 157: 2, 158: 2, 159: 2, 160: 2, // if*
+	161: 2, 162: 2, 163: 2, 164: 2, 165: 2, 166: 2, 167: 2, 168: 2, // if_*, goto, jsr
+	178: 2, 179: 2, 180: 2, 181: 2, 182: 2, 183: 2, 184: 2, // fields, invoke*
+	187: 2, 189: 2, 192: 2, 193: 2, 198: 2, 199: 2, // new, ..., ifnonnull
+	197: 3, 185: 4, 186: 4, 200: 4, 201: 4, // multianewarray, invokeinterface, invokedynamic, goto_w, jsr_w
+}
+
+func u32(v uint32) []byte { return []byte{byte(v >> 24), byte(v >> 16), byte(v >> 8), byte(v)} }
+
+func cat(parts ...[]byte) []byte {
+	var b []byte
+	for _, p := range parts {
+		b = append(b, p...)
+	}
+	return b
+}
+
+// Every operand-bearing instruction cut short, at the end of code, ends
+// both walks with a truncated instruction.
+func TestDisassembleTruncated(t *testing.T) {
+	type tc struct {
+		name string
+		code []byte
+	}
+	var cases []tc
+	for op, n := range operandBytes {
+		for k := range n {
+			code := append([]byte{op}, make([]byte, k)...)
+			cases = append(cases, tc{fmt.Sprintf("%s+%d", opcodeNames[op], k), code})
+		}
+	}
+	for n := 1; n <= 3; n++ { // wide iload needs 4 bytes; 1 is wide alone at the end
+		cases = append(cases, tc{fmt.Sprintf("wide iload %d bytes", n), []byte{0xc4, 0x15, 0x01, 0x00}[:n]})
+	}
+	for n := 3; n <= 5; n++ { // wide iinc needs 6 bytes
+		cases = append(cases, tc{fmt.Sprintf("wide iinc %d bytes", n), []byte{0xc4, 0x84, 0x00, 0x01, 0x00, 0x05}[:n]})
+	}
+	for pad := range 4 { // switch headers at each alignment
+		nops := make([]byte, pad)
+		for _, op := range []byte{0xaa, 0xab} {
+			at := pad + 1
+			for at%4 != 0 {
+				at++
+			}
+			// default, low, high / default, npairs
+			header := cat(u32(0), u32(0), u32(0))
+			if op == 0xab {
+				header = header[:8]
+			}
+			full := cat(nops, []byte{op}, make([]byte, at-pad-1), header)
+			for n := pad + 1; n < len(full); n++ {
+				cases = append(cases, tc{fmt.Sprintf("%s header at %d, %d bytes", opcodeNames[op], pad, n), full[:n]})
+			}
+		}
+		// Counts far beyond the code that follows
+		align := make([]byte, 3-pad)
+		cases = append(cases,
+			tc{fmt.Sprintf("tableswitch 2^32 entries at %d", pad), cat(nops, []byte{0xaa}, align, u32(0), u32(0x80000000), u32(0x7fffffff), u32(8))},
+			tc{fmt.Sprintf("lookupswitch 2^31-1 pairs at %d", pad), cat(nops, []byte{0xab}, align, u32(0), u32(0x7fffffff), u32(1), u32(8))},
+		)
+	}
+
+	cp := testPool()
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			insns := decodeInstructions(c.code, cp, nil, classOptions{})
+			last := insns[len(insns)-1]
+			if last.Comment != "truncated" || last.Operands != nil {
+				t.Errorf("decodeInstructions(% x) ends with %+v, want truncated", c.code, last)
+			}
+			want := fmt.Sprintf("%4d: %s // truncated\n", last.PC, opcodeNames[c.code[last.PC]])
+			if got := disassemble(c.code, cp, nil, nil, classOptions{}); !strings.HasSuffix(got, want) {
+				t.Errorf("disassemble(% x) = %q, want it to end %q", c.code, got, want)
+			}
+		})
+	}
+}
+
+// Full-length operands are not reported as truncated.
+func TestDisassembleComplete(t *testing.T) {
+	for op, n := range operandBytes {
+		code := append([]byte{op}, make([]byte, n)...)
+		if out := disassemble(code, testPool(), nil, nil, classOptions{}); strings.Contains(out, "truncated") {
+			t.Errorf("%s with %d operand bytes: %q", opcodeNames[op], n, out)
+		}
+	}
+}
@@ -2,4 +2,9 @@ module pkg-inspector/wasm/class-parser
 
 go 1.25.0
 
-require github.com/wreulicke/classfile-parser v0.0.0-20241112005056-e43882242369
+require (
+	github.com/wreulicke/classfile-parser v0.0.0-20241112005056-e43882242369
+	pkg-inspector/wasm/internal v0.0.0
+)
+
+replace pkg-inspector/wasm/internal => ../internal
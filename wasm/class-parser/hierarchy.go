@@ -0,0 +1,156 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"path"
+	"sort"
+	"strings"
+
+	"pkg-inspector/wasm/internal/archive"
+)
+
+// ---------------------------------------------------------------------------
+// Jar type hierarchy
+// ---------------------------------------------------------------------------
+
+// TypeHierarchy is the __wasm_jarTypeHierarchy result. Class names are
+// binary names with dots, as in ClassInfo.ClassName.
+type TypeHierarchy struct {
+	// Classes maps every class in the jar to its direct supertypes.
+	Classes map[string]TypeNode `json:"classes"`
+
+	// Subtypes is the reverse adjacency list: each supertype (in the jar
+	// or not) to the jar classes that extend or implement it, sorted.
+	Subtypes map[string][]string `json:"subtypes"`
+
+	// External lists, sorted, the supertypes not defined in the jar (JDK
+	// or dependency classes). They are leaves of the hierarchy.
+	External []string `json:"external"`
+
+	// Errors maps entry paths that failed to parse to the reason.
+	Errors map[string]string `json:"errors,omitempty"`
+}
+
+// TypeNode is one jar class in a TypeHierarchy.
+type TypeNode struct {
+	Path       string   `json:"path"`
+	SuperClass string   `json:"superClass,omitempty"`
+	Interfaces []string `json:"interfaces"`
+
+	// SuperChain follows SuperClass through the jar: the superclass, its
+	// superclass, and so on, ending with the first one defined outside
+	// the jar (usually java.lang.Object).
+	SuperChain []string `json:"superChain"`
+}
+
+// jarTypeHierarchy parses every .class entry of the jar in data and links
+// them by superclass and interfaces. A class that fails to parse is
+// reported in Errors rather than failing the whole jar; when a class
+// name appears more than once (multi-release jars), the first entry wins.
+// Only entries passing the path filters of opts are read.
+func jarTypeHierarchy(data []byte, opts archive.Options) (*TypeHierarchy, error) {
+	r, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, err
+	}
+
+	h := &TypeHierarchy{
+		Classes:  make(map[string]TypeNode),
+		Subtypes: make(map[string][]string),
+		External: make([]string, 0),
+	}
+	for _, f := range r.File {
+		if f.FileInfo().IsDir() || !strings.HasSuffix(strings.ToLower(f.Name), ".class") ||
+			path.Base(f.Name) == "module-info.class" || !opts.Includes(f.Name) {
+			continue
+		}
+		node, name, err := readTypeNode(f)
+		if err != nil {
+			if h.Errors == nil {
+				h.Errors = make(map[string]string)
+			}
+			h.Errors[f.Name] = err.Error()
+			continue
+		}
+		if _, dup := h.Classes[name]; !dup {
+			h.Classes[name] = node
+		}
+	}
+
+	external := make(map[string]bool)
+	for name, node := range h.Classes {
+		supers := node.Interfaces
+		if node.SuperClass != "" {
+			supers = append([]string{node.SuperClass}, supers...)
+		}
+		for _, s := range supers {
+			h.Subtypes[s] = append(h.Subtypes[s], name)
+			if _, ok := h.Classes[s]; !ok {
+				external[s] = true
+			}
+		}
+
+		// The chain stops at the first external class; seen guards
+		// against cycles in a malformed jar.
+		seen := map[string]bool{name: true}
+		for s := node.SuperClass; s != "" && !seen[s]; {
+			seen[s] = true
+			node.SuperChain = append(node.SuperChain, s)
+			next, ok := h.Classes[s]
+			if !ok {
+				break
+			}
+			s = next.SuperClass
+		}
+		h.Classes[name] = node
+	}
+
+	for _, subs := range h.Subtypes {
+		sort.Strings(subs)
+	}
+	for s := range external {
+		h.External = append(h.External, s)
+	}
+	sort.Strings(h.External)
+	return h, nil
+}
+
+// readTypeNode reads the class in zip entry f and returns its supertypes
+// and name. Classes over archive.MaxFileContentSize, which parseZip
+// leaves unread too, fail with an error.
+func readTypeNode(f *zip.File) (_ TypeNode, _ string, err error) {
+	defer recoverMalformed(&err)
+
+	rc, err := f.Open()
+	if err != nil {
+		return TypeNode{}, "", err
+	}
+	data, err := readClassAtMost(rc, archive.MaxFileContentSize)
+	rc.Close()
+	if err != nil {
+		return TypeNode{}, "", err
+	}
+
+	cf, _, _, err := parseClassBytes(data)
+	if err != nil {
+		return TypeNode{}, "", err
+	}
+	cp := cf.ConstantPool
+	name, ok := lookupClassName(cp, cf.ThisClass)
+	if !ok {
+		return TypeNode{}, "", errors.New("bad this_class index")
+	}
+
+	node := TypeNode{Path: f.Name, Interfaces: make([]string, 0), SuperChain: make([]string, 0)}
+	if sc, ok := lookupClassName(cp, cf.SuperClass); ok {
+		node.SuperClass = strings.ReplaceAll(sc, "/", ".")
+	}
+	for _, idx := range cf.Interfaces {
+		if iName, ok := lookupClassName(cp, idx); ok {
+			node.Interfaces = append(node.Interfaces, strings.ReplaceAll(iName, "/", "."))
+		}
+	}
+	return node, strings.ReplaceAll(name, "/", "."), nil
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"testing"
+
+	"pkg-inspector/wasm/internal/archive"
+)
+
+// A class too large to read is reported in Errors and the rest of the
+// jar is still linked.
+func TestJarTypeHierarchyOversized(t *testing.T) {
+	class := testClass(0x21, nil, nil)
+	big := append(class, make([]byte, archive.MaxFileContentSize)...)
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, data := range map[string][]byte{"T.class": class, "big/Big.class": big} {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write(data)
+	}
+	zw.Close()
+
+	h, err := jarTypeHierarchy(buf.Bytes(), archive.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "decompressed class exceeds 524288 bytes"; h.Errors["big/Big.class"] != want {
+		t.Errorf("Errors = %q, want %q for big/Big.class", h.Errors, want)
+	}
+	if node, ok := h.Classes["T"]; !ok || node.SuperClass != "java.lang.Object" {
+		t.Errorf("Classes = %+v, want T extending java.lang.Object", h.Classes)
+	}
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"sort"
+	"strings"
+
+	parser "github.com/wreulicke/classfile-parser"
+)
+
+// ---------------------------------------------------------------------------
+// Referenced classes and import list
+// ---------------------------------------------------------------------------
+
+// descriptorClasses adds the internal names of the class types in a field
+// or method descriptor ("Ljava/util/List;") to seen.
+func descriptorClasses(desc string, seen map[string]bool) {
+	for {
+		i := strings.IndexByte(desc, 'L')
+		if i < 0 {
+			return
+		}
+		end := strings.IndexByte(desc[i:], ';')
+		if end < 0 {
+			return
+		}
+		seen[desc[i+1:i+end]] = true
+		desc = desc[i+end+1:]
+	}
+}
+
+// referencedClasses returns the internal names of every class cf refers
+// to: CONSTANT_Class entries (array classes by element type) and the
+// class types in its own members' and referenced members' descriptors.
+func referencedClasses(cf *parser.Classfile) map[string]bool {
+	cp := cf.ConstantPool
+	seen := make(map[string]bool)
+	for _, c := range cp.Constants {
+		switch v := c.(type) {
+		case *parser.ConstantClass:
+			if name, ok := lookupUtf8(cp, v.NameIndex); ok {
+				if strings.HasPrefix(name, "[") {
+					descriptorClasses(name, seen)
+				} else {
+					seen[name] = true
+				}
+			}
+		case *parser.ConstantNameAndType:
+			if desc, ok := lookupUtf8(cp, v.DescriptorIndex); ok {
+				descriptorClasses(desc, seen)
+			}
+		case *parser.ConstantMethodType:
+			if desc, ok := lookupUtf8(cp, v.DescriptorIndex); ok {
+				descriptorClasses(desc, seen)
+			}
+		}
+	}
+	for _, f := range cf.Fields {
+		if desc, ok := lookupUtf8(cp, f.DescriptorIndex); ok {
+			descriptorClasses(desc, seen)
+		}
+	}
+	for _, m := range cf.Methods {
+		if desc, ok := lookupUtf8(cp, m.DescriptorIndex); ok {
+			descriptorClasses(desc, seen)
+		}
+	}
+	return seen
+}
+
+// classImports lists the classes cf refers to the way a source file's
+// import statements would: sorted source names ("java.util.Map.Entry"),
+// leaving out java.lang, the class's own package and the class itself.
+func classImports(cf *parser.Classfile, className string) []string {
+	pkg := ""
+	if i := strings.LastIndexByte(className, '.'); i >= 0 {
+		pkg = className[:i]
+	}
+	imports := make([]string, 0)
+	for name := range referencedClasses(cf) {
+		name = strings.ReplaceAll(name, "/", ".")
+		p := ""
+		if i := strings.LastIndexByte(name, '.'); i >= 0 {
+			p = name[:i]
+		}
+		if p == pkg || p == "java.lang" {
+			continue
+		}
+		imports = append(imports, strings.ReplaceAll(name, "$", "."))
+	}
+	sort.Strings(imports)
+	return imports
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"strings"
+
+	parser "github.com/wreulicke/classfile-parser"
+)
+
+// ---------------------------------------------------------------------------
+// Structured bytecode (structuredBytecode option)
+// ---------------------------------------------------------------------------
+
+// InstructionInfo is one decoded instruction, the data behind a line of
+// MethodInfo.Bytecode. Operands depend on the opcode:
+//
+//   - constant pool instructions: the pool index, then the count of
+//     invokeinterface or the dimensions of multianewarray
+//   - branches: the absolute target PC
+//   - tableswitch/lookupswitch: the default target, then key, target
+//     pairs
+//   - loads, stores, ret: the local variable slot; iinc: slot, delta
+//   - bipush, sipush: the value; newarray: the element type code
+//
+// Comment is the resolved constant or the local variable name. For wide
+// instructions Opcode is 196 (wide) and Mnemonic the widened one's.
+// A truncated last instruction has no operands and Comment "truncated".
+type InstructionInfo struct {
+	PC       int    `json:"pc"`
+	Opcode   int    `json:"opcode"`
+	Mnemonic string `json:"mnemonic"`
+	Wide     bool   `json:"wide,omitempty"`
+	Operands []int  `json:"operands,omitempty"`
+	Comment  string `json:"comment,omitempty"`
+}
+
+// decodeInstructions decodes code into one InstructionInfo per
+// instruction; disassemble formats its result, so this is the one place
+// operand layouts are read. The walk advances by insnLength and stops
+// after the first truncated instruction. vars names local variables as
+// in disassemble; opts.ReadableDescriptors applies to the comments.
+func decodeInstructions(code []byte, cp *parser.ConstantPool, vars []localVar, opts classOptions) []InstructionInfo {
+	insns := make([]InstructionInfo, 0)
+	u16 := func(at int) int { return int(binary.BigEndian.Uint16(code[at : at+2])) }
+	for i := 0; i < len(code); i += insnLength(code, i) {
+		op := code[i]
+		in := InstructionInfo{PC: i, Opcode: int(op), Mnemonic: opcodeNames[op]}
+		if in.Mnemonic == "" {
+			in.Mnemonic = fmt.Sprintf("0x%02x", op)
+		}
+		if i+insnSize(code, i) > len(code) {
+			// Operands run past the end of a malformed Code attribute
+			in.Comment = "truncated"
+			insns = append(insns, in)
+			break
+		}
+		in.Comment = strings.TrimPrefix(localComment(code, i, vars), " // ")
+		cpComment := func(idx int) {
+			in.Comment = formatConstant(cp, uint16(idx), opts.ReadableDescriptors)
+		}
+
+		switch op {
+		// bipush, newarray: signed value / element type
+		case 16:
+			in.Operands = []int{int(int8(code[i+1]))}
+		case 188:
+			in.Operands = []int{int(code[i+1])}
+
+		// ?load, ?store, ret: local variable slot
+		case 21, 22, 23, 24, 25, 54, 55, 56, 57, 58, 169:
+			in.Operands = []int{int(code[i+1])}
+
+		// ldc (1-byte CP index)
+		case 18:
+			in.Operands = []int{int(code[i+1])}
+			cpComment(int(code[i+1]))
+
+		// 2-byte CP index
+		case 19, 20, 178, 179, 180, 181, 182, 183, 184, 186, 187, 189, 192, 193:
+			in.Operands = []int{u16(i + 1)}
+			cpComment(u16(i + 1))
+
+		// invokeinterface: CP index + count; multianewarray: CP index + dimensions
+		case 185, 197:
+			in.Operands = []int{u16(i + 1), int(code[i+3])}
+			cpComment(u16(i + 1))
+
+		// 2-byte signed branch offset
+		case 153, 154, 155, 156, 157, 158, 159, 160, 161, 162, 163, 164,
+			165, 166, 167, 168, 198, 199: // if*, goto, jsr, ifnull, ifnonnull
+			in.Operands = []int{i + int(int16(u16(i+1)))}
+
+		// goto_w, jsr_w: 4-byte signed branch offset
+		case 200, 201:
+			in.Operands = []int{i + int(int32(binary.BigEndian.Uint32(code[i+1:i+5])))}
+
+		// sipush: 2-byte signed value
+		case 17:
+			in.Operands = []int{int(int16(u16(i + 1)))}
+
+		// iinc: slot, signed delta
+		case 132:
+			in.Operands = []int{int(code[i+1]), int(int8(code[i+2]))}
+
+		// tableswitch, lookupswitch
+		case 170, 171:
+			if t, ok := decodeSwitch(code, i); ok {
+				in.Operands = []int{t.Default}
+				for k, key := range t.Keys {
+					in.Operands = append(in.Operands, int(key), t.Targets[k])
+				}
+			}
+
+		// wide: 2-byte slot, plus a 2-byte signed delta for iinc
+		case 196:
+			in.Wide = true
+			in.Mnemonic = opcodeNames[code[i+1]]
+			if in.Mnemonic == "" {
+				in.Mnemonic = fmt.Sprintf("0x%02x", code[i+1])
+			}
+			in.Operands = []int{u16(i + 2)}
+			if code[i+1] == 132 {
+				in.Operands = append(in.Operands, int(int16(u16(i+4))))
+			}
+
+		default:
+			if opcodeNames[op] == "" {
+				in.Comment = "unknown"
+			}
+		}
+		insns = append(insns, in)
+	}
+	return insns
+}
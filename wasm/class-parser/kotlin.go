@@ -0,0 +1,113 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+
+	parser "github.com/wreulicke/classfile-parser"
+)
+
+// ---------------------------------------------------------------------------
+// Kotlin @Metadata
+// ---------------------------------------------------------------------------
+
+// KotlinMetadataInfo surfaces the fields of a class's kotlin.Metadata
+// annotation. The protobuf payload in D1/D2 is passed through undecoded
+// for Kotlin-aware tooling.
+type KotlinMetadataInfo struct {
+	Kind            int      `json:"kind"`                      // k
+	KindName        string   `json:"kindName"`                  // "class", "file", ...
+	MetadataVersion string   `json:"metadataVersion,omitempty"` // mv, e.g. "1.9.0"
+	D1              []string `json:"d1,omitempty"`
+	D2              []string `json:"d2,omitempty"`
+}
+
+// kotlinKinds names the values of kotlin.Metadata.k.
+var kotlinKinds = map[int]string{
+	1: "class",
+	2: "file",
+	3: "synthetic class",
+	4: "multi-file class facade",
+	5: "multi-file class part",
+}
+
+// kotlinMetadata extracts the kotlin.Metadata annotation of cf, or nil
+// if the class has none.
+func kotlinMetadata(cf *parser.Classfile) *KotlinMetadataInfo {
+	rva := cf.RuntimeVisibleAnnotations()
+	if rva == nil {
+		return nil
+	}
+	cp := cf.ConstantPool
+
+	for _, a := range rva.Annotations {
+		if typ, _ := lookupUtf8(cp, a.TypeIndex); typ != "Lkotlin/Metadata;" {
+			continue
+		}
+
+		km := &KotlinMetadataInfo{Kind: 1} // k defaults to 1 (class)
+		for _, p := range a.ElementValuePairs {
+			name, _ := lookupUtf8(cp, p.ElementNameIndex)
+			switch name {
+			case "k":
+				if k, ok := elementInt(cp, p.ElementValue); ok {
+					km.Kind = k
+				}
+			case "mv":
+				var parts []string
+				for _, v := range elementArray(p.ElementValue) {
+					if n, ok := elementInt(cp, v); ok {
+						parts = append(parts, strconv.Itoa(n))
+					}
+				}
+				km.MetadataVersion = strings.Join(parts, ".")
+			case "d1":
+				km.D1 = elementStrings(cp, p.ElementValue)
+			case "d2":
+				km.D2 = elementStrings(cp, p.ElementValue)
+			}
+		}
+		km.KindName = kotlinKinds[km.Kind]
+		if km.KindName == "" {
+			km.KindName = "unknown"
+		}
+		return km
+	}
+	return nil
+}
+
+// elementInt reads an int constant element value.
+func elementInt(cp *parser.ConstantPool, v parser.ElementValue) (int, bool) {
+	c, ok := v.(*parser.ElementValueConstValue)
+	if !ok || c.ConstValueIndex < 1 || int(c.ConstValueIndex) > len(cp.Constants) {
+		return 0, false
+	}
+	i, ok := cp.Constants[c.ConstValueIndex-1].(*parser.ConstantInteger)
+	if !ok {
+		return 0, false
+	}
+	return int(int32(i.Bytes)), true
+}
+
+// elementArray returns the values of an array element value. A single
+// value stands for a one-element array, as annotations allow.
+func elementArray(v parser.ElementValue) []parser.ElementValue {
+	if arr, ok := v.(*parser.ElementValueArrayValue); ok {
+		return arr.Values
+	}
+	return []parser.ElementValue{v}
+}
+
+// elementStrings reads a String[] element value.
+func elementStrings(cp *parser.ConstantPool, v parser.ElementValue) []string {
+	var out []string
+	for _, e := range elementArray(v) {
+		c, ok := e.(*parser.ElementValueConstValue)
+		if !ok {
+			continue
+		}
+		s, _ := lookupUtf8(cp, c.ConstValueIndex)
+		out = append(out, s)
+	}
+	return out
+}
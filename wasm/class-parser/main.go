@@ -2,13 +2,21 @@ package main
 
 import (
 	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"encoding/base64"
 	"encoding/binary"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"strings"
 	"syscall/js"
+	"unicode/utf16"
+	"unicode/utf8"
 
 	parser "github.com/wreulicke/classfile-parser"
+	"pkg-inspector/wasm/internal/archive"
 )
 
 // ---------------------------------------------------------------------------
@@ -16,59 +24,214 @@ import (
 // ---------------------------------------------------------------------------
 
 type ClassInfo struct {
-	MajorVersion int          `json:"majorVersion"`
-	MinorVersion int          `json:"minorVersion"`
-	JavaVersion  string       `json:"javaVersion"`
-	AccessFlags  []string     `json:"accessFlags"`
-	ClassName    string       `json:"className"`
-	SuperClass   string       `json:"superClass"`
-	Interfaces   []string     `json:"interfaces"`
-	SourceFile   string       `json:"sourceFile,omitempty"`
-	Fields       []FieldInfo  `json:"fields"`
-	Methods      []MethodInfo `json:"methods"`
-	IsDeprecated bool         `json:"isDeprecated,omitempty"`
-	Signature    string       `json:"signature,omitempty"`
+	SchemaVersion int `json:"schemaVersion"` // see archive.SchemaVersion
+
+	MajorVersion   int          `json:"majorVersion"`
+	MinorVersion   int          `json:"minorVersion"`
+	JavaVersion    string       `json:"javaVersion"`
+	AccessFlags    []string     `json:"accessFlags"`
+	RawAccessFlags int          `json:"rawAccessFlags"` // access_flags bits as stored
+	ClassName      string       `json:"className"`      // binary name, e.g. com.example.Outer$Inner
+	SuperClass     string       `json:"superClass"`
+	Interfaces     []string     `json:"interfaces"`
+	SourceFile     string       `json:"sourceFile,omitempty"`
+	Fields         []FieldInfo  `json:"fields"`
+	Methods        []MethodInfo `json:"methods"`
+	IsDeprecated   bool         `json:"isDeprecated,omitempty"`
+	Signature      string       `json:"signature,omitempty"`
+
+	// Imports are the classes the class refers to outside java.lang and
+	// its own package, sorted, like the import list of its source file
+	// (but every class, with no wildcards). See classImports.
+	Imports []string `json:"imports,omitempty"`
+
+	// SourceLineSpan is the lowest and highest source line in the
+	// methods' LineNumberTables, a measure of how long the source was;
+	// absent when no method has line numbers (compiled with -g:none).
+	SourceLineSpan *[2]int `json:"sourceLineSpan,omitempty"`
+
+	// PreviewFeatures is set when MinorVersion is 0xFFFF: the class uses
+	// preview features and only loads on the JDK release that compiled it.
+	PreviewFeatures bool `json:"previewFeatures,omitempty"`
+
+	// RecordComponents are the components of a record class, in
+	// declaration order.
+	RecordComponents []RecordComponentInfo `json:"recordComponents,omitempty"`
+
+	// Source-level names from InnerClasses: SimpleName "Inner",
+	// OuterName "com.example.Outer" (nested classes only) and
+	// DisplayName "com.example.Outer.Inner".
+	SimpleName  string `json:"simpleName"`
+	OuterName   string `json:"outerName,omitempty"`
+	DisplayName string `json:"displayName"`
+
+	// Summary counts, so list views can show badges without walking
+	// Fields and Methods.
+	FieldCount          int `json:"fieldCount"`
+	MethodCount         int `json:"methodCount"`
+	PublicMethodCount   int `json:"publicMethodCount"`
+	AbstractMethodCount int `json:"abstractMethodCount"`
+
+	// UnknownAttributes names class attributes this parser does not
+	// decode (e.g. ScalaSig). With the includeRawAttributes option their
+	// bytes are in UnknownAttributeData (name -> base64), up to
+	// maxRawAttributeBytes in total.
+	UnknownAttributes    []string          `json:"unknownAttributes,omitempty"`
+	UnknownAttributeData map[string]string `json:"unknownAttributeData,omitempty"`
+
+	// KotlinMetadata is set for classes compiled by kotlinc.
+	KotlinMetadata *KotlinMetadataInfo `json:"kotlinMetadata,omitempty"`
+
+	// Warnings logs what the parse skipped or worked around (undecoded
+	// attributes, truncated bytecode, ...), under the verbose option.
+	Warnings []string `json:"warnings,omitempty"`
 }
 
 type FieldInfo struct {
-	AccessFlags []string `json:"accessFlags"`
-	Name        string   `json:"name"`
-	Descriptor  string   `json:"descriptor"`
-	TypeName    string   `json:"typeName"`
-	Signature   string   `json:"signature,omitempty"`
+	AccessFlags    []string `json:"accessFlags"`
+	RawAccessFlags int      `json:"rawAccessFlags"`
+	Name           string   `json:"name"`
+	Descriptor     string   `json:"descriptor"`
+	TypeName       string   `json:"typeName"`
+	Signature      string   `json:"signature,omitempty"`
+
+	// DeclarationString is the field as Java source would declare it,
+	// e.g. "private final int count".
+	DeclarationString string `json:"declarationString"`
+}
+
+type RecordComponentInfo struct {
+	Name       string `json:"name"`
+	Descriptor string `json:"descriptor"`
+	TypeName   string `json:"typeName"`
+	Signature  string `json:"signature,omitempty"`
+
+	// GenericType is Signature in source syntax, e.g.
+	// "java.util.List<String>", for generic components.
+	GenericType string `json:"genericType,omitempty"`
 }
 
 type MethodInfo struct {
-	AccessFlags []string `json:"accessFlags"`
-	Name        string   `json:"name"`
-	Descriptor  string   `json:"descriptor"`
-	ReturnType  string   `json:"returnType"`
-	ParamTypes  []string `json:"paramTypes"`
-	Exceptions  []string `json:"exceptions,omitempty"`
-	Signature   string   `json:"signature,omitempty"`
-	Bytecode    string   `json:"bytecode,omitempty"`
-	MaxStack    int      `json:"maxStack,omitempty"`
-	MaxLocals   int      `json:"maxLocals,omitempty"`
+	AccessFlags    []string `json:"accessFlags"`
+	RawAccessFlags int      `json:"rawAccessFlags"`
+	Name           string   `json:"name"`
+	Descriptor     string   `json:"descriptor"`
+	ReturnType     string   `json:"returnType"`
+	ParamTypes     []string `json:"paramTypes"`
+	Exceptions     []string `json:"exceptions,omitempty"`
+	Signature      string   `json:"signature,omitempty"`
+	Bytecode       string   `json:"bytecode,omitempty"`
+	MaxStack       int      `json:"maxStack,omitempty"`
+	MaxLocals      int      `json:"maxLocals,omitempty"`
+
+	// UnknownOpcodes lists, in ascending order, the distinct opcode bytes
+	// the disassembler could not decode (shown as "0x.. (unknown)").
+	// Usually a sign of a class format newer than this tool.
+	UnknownOpcodes []int `json:"unknownOpcodes,omitempty"`
+
+	// UnknownCodeAttributes names attributes nested in the Code attribute
+	// other than the standard LineNumberTable, LocalVariableTable,
+	// LocalVariableTypeTable, StackMapTable and type annotations.
+	UnknownCodeAttributes []string `json:"unknownCodeAttributes,omitempty"`
+
+	// FieldReads and FieldWrites are the distinct fields the method
+	// accesses with getfield/getstatic and putfield/putstatic, as
+	// "owner.name:descriptor" in order of first use.
+	FieldReads  []string `json:"fieldReads,omitempty"`
+	FieldWrites []string `json:"fieldWrites,omitempty"`
+
+	// Switches are the method's tableswitch/lookupswitch instructions,
+	// decoded alongside Bytecode.
+	Switches []SwitchInfo `json:"switches,omitempty"`
+
+	// Instructions is Bytecode decoded into objects, under the
+	// structuredBytecode option.
+	Instructions []InstructionInfo `json:"instructions,omitempty"`
+
+	// StackDepths and StackIssues are the simulateStack results: the
+	// operand stack depth around each reachable instruction, and any
+	// underflow, depth over MaxStack or inconsistent merge found, which
+	// usually means a malformed class.
+	StackDepths []StackDepth `json:"stackDepths,omitempty"`
+	StackIssues []string     `json:"stackIssues,omitempty"`
+
+	// BranchTargets are the distinct PCs the method's branch and switch
+	// instructions jump to, ascending (see __wasm_verifyClass).
+	BranchTargets []int `json:"branchTargets,omitempty"`
+
+	// HasCatch and HasFinally summarise the exception table: a handler
+	// with a catch type is a catch clause, one without (catch_type 0)
+	// catches everything, as javac emits for finally blocks,
+	// try-with-resources cleanup and synchronized blocks.
+	HasCatch   bool `json:"hasCatch,omitempty"`
+	HasFinally bool `json:"hasFinally,omitempty"`
+
+	// IsDefault marks an interface method with a body that implementers
+	// inherit: not abstract, static or private (Java 9 private interface
+	// methods have bodies too but are not defaults).
+	IsDefault bool `json:"isDefault,omitempty"`
+
+	IsConstructor       bool `json:"isConstructor,omitempty"`       // <init>
+	IsStaticInitializer bool `json:"isStaticInitializer,omitempty"` // <clinit>
+
+	// DeclarationString is the method as Java source would declare it,
+	// built from the generic signature (or descriptor), MethodParameters
+	// names and thrown exceptions, e.g.
+	// "public static java.util.List<String> merge(int count, String name) throws java.io.IOException".
+	DeclarationString string `json:"declarationString"`
+
+	// Key is Name + Descriptor, e.g. "merge(ILjava/lang/String;)Ljava/lang/Object;":
+	// unique within the class and stable under filtering, unlike the
+	// method's index, so the UI can link and select by it.
+	Key string `json:"key"`
 }
 
-// ---------------------------------------------------------------------------
-// Java version mapping
-// ---------------------------------------------------------------------------
+// SwitchInfo is a decoded tableswitch or lookupswitch at PC. Targets are
+// absolute PCs.
+type SwitchInfo struct {
+	PC      int          `json:"pc"`
+	Opcode  string       `json:"opcode"` // "tableswitch" or "lookupswitch"
+	Default int          `json:"default"`
+	Cases   []SwitchCase `json:"cases"`
+}
+
+type SwitchCase struct {
+	Key    int32 `json:"key"`
+	Target int   `json:"target"`
+}
 
-var majorVersionMap = map[int]string{
-	45: "1.1", 46: "1.2", 47: "1.3", 48: "1.4",
-	49: "5", 50: "6", 51: "7", 52: "8",
-	53: "9", 54: "10", 55: "11", 56: "12",
-	57: "13", 58: "14", 59: "15", 60: "16",
-	61: "17", 62: "18", 63: "19", 64: "20",
-	65: "21", 66: "22", 67: "23", 68: "24",
+// StringRef is one __wasm_findStringRefs hit: an ldc/ldc_w at PC in
+// Method ("name:descriptor") that loads the searched string literal.
+type StringRef struct {
+	Method string `json:"method"`
+	PC     int    `json:"pc"`
+}
+
+// ClassSignatures is the __wasm_classSignatures result: the methods of
+// ClassName with their declarations but no Code-derived fields.
+type ClassSignatures struct {
+	ClassName string       `json:"className"`
+	Methods   []MethodInfo `json:"methods"`
+}
+
+// ClassBatchEntry is one element of the __wasm_parseClasses result, in the
+// same order as the input. A class that fails to parse carries Error
+// instead of failing the whole batch.
+type ClassBatchEntry struct {
+	Class *ClassInfo `json:"class,omitempty"`
+	Error string     `json:"error,omitempty"`
 }
 
 // ---------------------------------------------------------------------------
 // Access flag helpers
 // ---------------------------------------------------------------------------
 
-func classAccessFlags(flags parser.AccessFlags) []string {
+// accInterface is ACC_INTERFACE, which the parser's constant set lacks.
+const accInterface parser.AccessFlags = 0x0200
+
+// classAccessFlags lists the class modifiers and kind in flags. ACC_SUPER
+// is only listed, as "super", when showSuper is set.
+func classAccessFlags(flags parser.AccessFlags, showSuper bool) []string {
 	result := make([]string, 0)
 	if flags.Is(parser.ACC_PUBLIC) {
 		result = append(result, "public")
@@ -76,8 +239,11 @@ func classAccessFlags(flags parser.AccessFlags) []string {
 	if flags.Is(parser.ACC_FINAL) {
 		result = append(result, "final")
 	}
-	if flags.Is(parser.ACC_SUPER) {
-		// ACC_SUPER is set by modern compilers but not a source-level modifier
+	// ACC_SUPER is set by modern compilers but is not a source-level
+	// modifier, so it is not listed unless asked for (it changes
+	// invokespecial semantics in pre-Java 8 class files).
+	if showSuper && flags.Is(parser.ACC_SUPER) {
+		result = append(result, "super")
 	}
 	if flags.Is(parser.ACC_ABSTRACT) {
 		result = append(result, "abstract")
@@ -85,23 +251,18 @@ func classAccessFlags(flags parser.AccessFlags) []string {
 	if flags.Is(parser.ACC_SYNTHETIC) {
 		result = append(result, "synthetic")
 	}
-	if flags.Is(parser.ACC_ANNOTATION) {
-		result = append(result, "annotation")
-	}
-	if flags.Is(parser.ACC_ENUM) {
-		result = append(result, "enum")
-	}
-	if flags.Is(parser.ACC_MODULE) && !flags.Is(parser.ACC_SUPER) {
+	// Class kind. Annotation types are interfaces at the bytecode level
+	// (ACC_ANNOTATION requires ACC_INTERFACE), so they get both words.
+	switch {
+	case flags.Is(parser.ACC_MODULE):
 		result = append(result, "module")
-	}
-	// Determine class kind
-	if flags.Is(parser.ACC_ANNOTATION) {
-		// already added
-	} else if flags.Is(parser.ACC_ENUM) {
-		// already added
-	} else if flags.Is(0x0200) { // ACC_INTERFACE
+	case flags.Is(parser.ACC_ANNOTATION):
+		result = append(result, "annotation", "interface")
+	case flags.Is(accInterface):
 		result = append(result, "interface")
-	} else {
+	case flags.Is(parser.ACC_ENUM):
+		result = append(result, "enum")
+	default:
 		result = append(result, "class")
 	}
 	return result
@@ -308,8 +469,84 @@ var opcodeNames = [256]string{
 	198: "ifnull", 199: "ifnonnull", 200: "goto_w", 201: "jsr_w",
 }
 
+// ---------------------------------------------------------------------------
+// Constant pool strings
+// ---------------------------------------------------------------------------
+
+// decodeModifiedUtf8 converts the "modified UTF-8" of CONSTANT_Utf8 entries
+// (JVMS 4.4.7) to a Go string. NUL is stored as C0 80 and supplementary
+// characters as a pair of 3-byte surrogates, neither of which a plain
+// string conversion understands. Malformed bytes become U+FFFD.
+func decodeModifiedUtf8(b []byte) string {
+	ascii := true
+	for _, c := range b {
+		if c == 0 || c >= 0x80 {
+			ascii = false
+			break
+		}
+	}
+	if ascii {
+		return string(b)
+	}
+
+	units := make([]uint16, 0, len(b))
+	for i := 0; i < len(b); {
+		c := b[i]
+		switch {
+		case c != 0 && c < 0x80:
+			units = append(units, uint16(c))
+			i++
+		case c&0xe0 == 0xc0 && i+1 < len(b) && b[i+1]&0xc0 == 0x80:
+			units = append(units, uint16(c&0x1f)<<6|uint16(b[i+1]&0x3f))
+			i += 2
+		case c&0xf0 == 0xe0 && i+2 < len(b) && b[i+1]&0xc0 == 0x80 && b[i+2]&0xc0 == 0x80:
+			units = append(units, uint16(c&0x0f)<<12|uint16(b[i+1]&0x3f)<<6|uint16(b[i+2]&0x3f))
+			i += 3
+		default:
+			units = append(units, utf8.RuneError)
+			i++
+		}
+	}
+	// utf16.Decode joins surrogate pairs and maps lone halves to U+FFFD.
+	return string(utf16.Decode(units))
+}
+
+// lookupUtf8 returns the decoded CONSTANT_Utf8 at index, or false if
+// index does not name one.
+func lookupUtf8(cp *parser.ConstantPool, index uint16) (string, bool) {
+	if int(index) < 1 || int(index) > len(cp.Constants) {
+		return "", false
+	}
+	u, ok := cp.Constants[index-1].(*parser.ConstantUtf8)
+	if !ok {
+		return "", false
+	}
+	return decodeModifiedUtf8(u.Bytes), true
+}
+
+// lookupClassName returns the internal name (java/lang/String) of the
+// CONSTANT_Class at index, or false if index does not name one.
+func lookupClassName(cp *parser.ConstantPool, index uint16) (string, bool) {
+	if int(index) < 1 || int(index) > len(cp.Constants) {
+		return "", false
+	}
+	c, ok := cp.Constants[index-1].(*parser.ConstantClass)
+	if !ok {
+		return "", false
+	}
+	return lookupUtf8(cp, c.NameIndex)
+}
+
 // resolveConstantRef resolves a constant pool index to a human-readable string
 func resolveConstantRef(cp *parser.ConstantPool, index uint16) string {
+	return formatConstant(cp, index, false)
+}
+
+// formatConstant is resolveConstantRef, rendering the descriptors of
+// NameAndType (and so member reference) constants as Java types when
+// readable is set: "merge(int, java.lang.String):java.util.List"
+// instead of "merge:(ILjava/lang/String;)Ljava/util/List;".
+func formatConstant(cp *parser.ConstantPool, index uint16, readable bool) string {
 	if int(index) < 1 || int(index) > len(cp.Constants) {
 		return fmt.Sprintf("#%d", index)
 	}
@@ -320,30 +557,27 @@ func resolveConstantRef(cp *parser.ConstantPool, index uint16) string {
 
 	switch v := c.(type) {
 	case *parser.ConstantClass:
-		name := cp.LookupUtf8(v.NameIndex)
-		if name != nil {
-			return strings.ReplaceAll(name.String(), "/", ".")
+		if name, ok := lookupUtf8(cp, v.NameIndex); ok {
+			return strings.ReplaceAll(name, "/", ".")
 		}
 	case *parser.ConstantString:
-		s := cp.LookupUtf8(v.StringIndex)
-		if s != nil {
-			str := s.String()
+		if str, ok := lookupUtf8(cp, v.StringIndex); ok {
 			if len(str) > 40 {
 				str = str[:37] + "..."
 			}
 			return fmt.Sprintf("\"%s\"", str)
 		}
 	case *parser.ConstantFieldref:
-		return resolveRef(cp, v.ClassIndex, v.NameAndTypeIndex)
+		return resolveRef(cp, v.ClassIndex, v.NameAndTypeIndex, readable)
 	case *parser.ConstantMethodref:
-		return resolveRef(cp, v.ClassIndex, v.NameAndTypeIndex)
+		return resolveRef(cp, v.ClassIndex, v.NameAndTypeIndex, readable)
 	case *parser.ConstantInterfaceMethodref:
-		return resolveRef(cp, v.ClassIndex, v.NameAndTypeIndex)
+		return resolveRef(cp, v.ClassIndex, v.NameAndTypeIndex, readable)
 	case *parser.ConstantNameAndType:
-		name := cp.LookupUtf8(v.NameIndex)
-		desc := cp.LookupUtf8(v.DescriptorIndex)
-		if name != nil && desc != nil {
-			return name.String() + ":" + desc.String()
+		name, ok1 := lookupUtf8(cp, v.NameIndex)
+		desc, ok2 := lookupUtf8(cp, v.DescriptorIndex)
+		if ok1 && ok2 {
+			return nameAndType(name, desc, readable)
 		}
 	case *parser.ConstantInteger:
 		return fmt.Sprintf("%d", int32(v.Bytes))
@@ -353,17 +587,17 @@ func resolveConstantRef(cp *parser.ConstantPool, index uint16) string {
 		val := int64(v.HighBytes)<<32 | int64(v.LowBytes)
 		return fmt.Sprintf("%dL", val)
 	case *parser.ConstantUtf8:
-		return v.String()
+		return decodeModifiedUtf8(v.Bytes)
 	case *parser.ConstantInvokeDynamic:
-		nat := resolveConstantRef(cp, v.NameAndTypeIndex)
+		nat := formatConstant(cp, v.NameAndTypeIndex, readable)
 		return fmt.Sprintf("InvokeDynamic #%d:%s", v.BootstrapMethodAttrIndex, nat)
 	}
 	return fmt.Sprintf("#%d", index)
 }
 
-func resolveRef(cp *parser.ConstantPool, classIndex, natIndex uint16) string {
-	className, err := cp.GetClassName(classIndex)
-	if err != nil {
+func resolveRef(cp *parser.ConstantPool, classIndex, natIndex uint16, readable bool) string {
+	className, ok := lookupClassName(cp, classIndex)
+	if !ok {
 		className = fmt.Sprintf("#%d", classIndex)
 	} else {
 		className = strings.ReplaceAll(className, "/", ".")
@@ -374,235 +608,653 @@ func resolveRef(cp *parser.ConstantPool, classIndex, natIndex uint16) string {
 	if !ok {
 		return className + ".#" + fmt.Sprintf("%d", natIndex)
 	}
-	name := cp.LookupUtf8(nat.NameIndex)
-	desc := cp.LookupUtf8(nat.DescriptorIndex)
-	if name != nil && desc != nil {
-		return className + "." + name.String() + ":" + desc.String()
+	name, ok1 := lookupUtf8(cp, nat.NameIndex)
+	desc, ok2 := lookupUtf8(cp, nat.DescriptorIndex)
+	if ok1 && ok2 {
+		return className + "." + nameAndType(name, desc, readable)
 	}
 	return className + ".?"
 }
 
-// disassemble converts raw bytecode bytes into javap-like text output
-func disassemble(code []byte, cp *parser.ConstantPool) string {
-	var sb strings.Builder
-	i := 0
-	for i < len(code) {
-		op := code[i]
-		name := opcodeNames[op]
-		if name == "" {
-			name = fmt.Sprintf("0x%02x", op)
-		}
+// nameAndType renders a NameAndType constant as "name:descriptor", or
+// with readable set as "name(int, java.lang.String):void" for methods
+// and "name:int" for fields.
+func nameAndType(name, desc string, readable bool) string {
+	if !readable {
+		return name + ":" + desc
+	}
+	if strings.HasPrefix(desc, "(") {
+		params, ret := parseMethodDescriptor(desc)
+		return name + "(" + strings.Join(params, ", ") + "):" + ret
+	}
+	return name + ":" + parseFieldDescriptor(desc)
+}
 
-		switch op {
-		// No operands
-		case 0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15,
-			26, 27, 28, 29, 30, 31, 32, 33, 34, 35, 36, 37, 38, 39,
-			40, 41, 42, 43, 44, 45, 46, 47, 48, 49, 50, 51, 52, 53,
-			59, 60, 61, 62, 63, 64, 65, 66, 67, 68, 69, 70, 71, 72,
-			73, 74, 75, 76, 77, 78, 79, 80, 81, 82, 83, 84, 85, 86,
-			87, 88, 89, 90, 91, 92, 93, 94, 95,
-			96, 97, 98, 99, 100, 101, 102, 103, 104, 105, 106, 107,
-			108, 109, 110, 111, 112, 113, 114, 115, 116, 117, 118, 119,
-			120, 121, 122, 123, 124, 125, 126, 127, 128, 129, 130, 131,
-			133, 134, 135, 136, 137, 138, 139, 140, 141, 142, 143, 144,
-			145, 146, 147, 148, 149, 150, 151, 152,
-			172, 173, 174, 175, 176, 177, 190, 191, 194, 195:
-			fmt.Fprintf(&sb, "%4d: %s\n", i, name)
+// insnLength returns the length in bytes of the instruction at pc,
+// including operands. Truncated instructions consume the rest of code, so
+// a walk driven by insnLength always advances and ends at len(code).
+func insnLength(code []byte, pc int) int {
+	return min(insnSize(code, pc), len(code)-pc)
+}
+
+// insnSize is the encoded length of the instruction at pc, which may run
+// past the end of a truncated code array. A switch whose header is cut
+// off counts as one byte longer than what remains.
+func insnSize(code []byte, pc int) int {
+	n := 1
+	switch op := code[pc]; op {
+	case 16, 18, 21, 22, 23, 24, 25, 54, 55, 56, 57, 58, 169, 188:
+		n = 2
+	case 17, 19, 20, 132, 153, 154, 155, 156, 157, 158, 159, 160, 161, 162, 163, 164,
+		165, 166, 167, 168, 178, 179, 180, 181, 182, 183, 184, 187, 189, 192, 193, 198, 199:
+		n = 3
+	case 197:
+		n = 4
+	case 185, 186, 200, 201:
+		n = 5
+	case 170, 171: // tableswitch, lookupswitch
+		i := pc + 1
+		for i%4 != 0 {
 			i++
+		}
+		if op == 170 && i+12 <= len(code) {
+			low := int64(int32(binary.BigEndian.Uint32(code[i+4 : i+8])))
+			high := int64(int32(binary.BigEndian.Uint32(code[i+8 : i+12])))
+			n = i + 12 - pc
+			if high >= low {
+				n += int(min(high-low+1, int64(len(code)))) * 4
+			}
+		} else if op == 171 && i+8 <= len(code) {
+			npairs := int64(int32(binary.BigEndian.Uint32(code[i+4 : i+8])))
+			n = i + 8 - pc + int(max(0, min(npairs, int64(len(code)))))*8
+		} else {
+			n = len(code) - pc + 1
+		}
+	case 196: // wide
+		n = 4
+		if pc+1 < len(code) && code[pc+1] == 132 {
+			n = 6
+		}
+	}
+	return n
+}
+
+// switchTable is a decoded tableswitch or lookupswitch. Targets are
+// absolute PCs.
+type switchTable struct {
+	Default int
+	Keys    []int32
+	Targets []int
+}
+
+// decodeSwitch decodes the tableswitch/lookupswitch at pc. Entries cut
+// off by the end of code are dropped; ok is false if even the header is
+// missing.
+func decodeSwitch(code []byte, pc int) (t switchTable, ok bool) {
+	i := pc + 1
+	for i%4 != 0 {
+		i++
+	}
+	u32 := func(at int) int32 { return int32(binary.BigEndian.Uint32(code[at : at+4])) }
+
+	if code[pc] == 170 {
+		if i+12 > len(code) {
+			return t, false
+		}
+		t.Default = pc + int(u32(i))
+		low, high := u32(i+4), u32(i+8)
+		i += 12
+		for k := int64(low); k <= int64(high) && i+4 <= len(code); k++ {
+			t.Keys = append(t.Keys, int32(k))
+			t.Targets = append(t.Targets, pc+int(u32(i)))
+			i += 4
+		}
+		return t, true
+	}
+
+	if i+8 > len(code) {
+		return t, false
+	}
+	t.Default = pc + int(u32(i))
+	npairs := u32(i + 4)
+	i += 8
+	for k := int32(0); k < npairs && i+8 <= len(code); k++ {
+		t.Keys = append(t.Keys, u32(i))
+		t.Targets = append(t.Targets, pc+int(u32(i+4)))
+		i += 8
+	}
+	return t, true
+}
+
+// localSlot returns the local variable slot the load, store, iinc or
+// ret at pc accesses, and whether it is a store.
+func localSlot(code []byte, pc int) (slot int, store, ok bool) {
+	op := code[pc]
+	switch {
+	case op >= 21 && op <= 25, op == 169, op == 132: // ?load, ret, iinc
+		if pc+1 < len(code) {
+			return int(code[pc+1]), false, true
+		}
+	case op >= 54 && op <= 58: // ?store
+		if pc+1 < len(code) {
+			return int(code[pc+1]), true, true
+		}
+	case op >= 26 && op <= 45: // ?load_<n>
+		return int(op-26) % 4, false, true
+	case op >= 59 && op <= 78: // ?store_<n>
+		return int(op-59) % 4, true, true
+	case op == 196: // wide
+		if pc+3 < len(code) {
+			wideOp := code[pc+1]
+			slot := int(binary.BigEndian.Uint16(code[pc+2 : pc+4]))
+			return slot, wideOp >= 54 && wideOp <= 58, true
+		}
+	}
+	return 0, false, false
+}
+
+// localComment returns " // name" for an instruction that accesses a
+// local variable named in vars, or "". A store starts the variable's
+// scope, so its name is looked up at the following instruction.
+func localComment(code []byte, pc int, vars []localVar) string {
+	if len(vars) == 0 {
+		return ""
+	}
+	slot, store, ok := localSlot(code, pc)
+	if !ok {
+		return ""
+	}
+	at := pc
+	if store {
+		at += insnLength(code, pc)
+	}
+	if name := localName(vars, slot, at); name != "" {
+		return " // " + name
+	}
+	return ""
+}
+
+// disassemble converts raw bytecode bytes into javap-like text output.
+// Loads and stores are annotated with variable names from vars, the
+// method's LocalVariableTable (may be nil), and instructions with their
+// stack depths from stack (may be nil, see simulateStack). opts selects
+// how branch targets and constant pool operands are shown.
+//
+// The instructions come from decodeInstructions, so any byte sequence
+// is accepted and a "// truncated" line ends the listing at the first
+// instruction whose operands run past the end of code.
+func disassemble(code []byte, cp *parser.ConstantPool, vars []localVar, stack []StackDepth, opts classOptions) string {
+	var sb bytes.Buffer
+	depths := make(map[int]StackDepth, len(stack))
+	for _, d := range stack {
+		depths[d.PC] = d
+	}
+	// branch renders the target of a jump at pc.
+	branch := func(pc, target int) string {
+		if opts.RelativeOffsets {
+			return fmt.Sprintf("%+d", target-pc)
+		}
+		return fmt.Sprintf("%d", target)
+	}
+	// cpOperand renders constant pool operand idx, resolved as ref,
+	// followed by any further operands in extra, in the BytecodeComments
+	// style.
+	cpOperand := func(idx int, ref, extra string) string {
+		switch opts.BytecodeComments {
+		case "symbol":
+			return fmt.Sprintf("%s%s // #%d", ref, extra, idx)
+		case "index":
+			return fmt.Sprintf("#%d%s", idx, extra)
+		case "none":
+			return ref + extra
+		}
+		return fmt.Sprintf("#%d%s // %s", idx, extra, ref)
+	}
+	for _, in := range decodeInstructions(code, cp, vars, opts) {
+		i, name := in.PC, in.Mnemonic
+		if i+insnSize(code, i) > len(code) {
+			// Operands run past the end of a malformed Code attribute
+			fmt.Fprintf(&sb, "%4d: %s // truncated\n", i, name)
+			break
+		}
+		local := ""
+		if in.Comment != "" {
+			local = " // " + in.Comment
+		}
+		start := sb.Len()
 
+		switch op := code[i]; op {
 		// 1-byte operand (local variable index or byte value)
 		case 16, 21, 22, 23, 24, 25, 54, 55, 56, 57, 58, 169, 188: // bipush, ?load, ?store, ret, newarray
-			if i+1 < len(code) {
-				fmt.Fprintf(&sb, "%4d: %-16s %d\n", i, name, int8(code[i+1]))
-			} else {
-				fmt.Fprintf(&sb, "%4d: %s\n", i, name)
-			}
-			i += 2
+			fmt.Fprintf(&sb, "%4d: %-16s %d%s\n", i, name, in.Operands[0], local)
 
-		// ldc (1-byte CP index)
-		case 18:
-			if i+1 < len(code) {
-				idx := uint16(code[i+1])
-				ref := resolveConstantRef(cp, idx)
-				fmt.Fprintf(&sb, "%4d: %-16s #%d // %s\n", i, name, idx, ref)
-			}
-			i += 2
-
-		// 2-byte operand: CP index (ldc_w, ldc2_w, getstatic, putstatic, getfield, putfield,
-		// invokevirtual, invokespecial, invokestatic, new, anewarray, checkcast, instanceof)
-		case 19, 20, 178, 179, 180, 181, 182, 183, 184, 187, 189, 192, 193:
-			if i+2 < len(code) {
-				idx := binary.BigEndian.Uint16(code[i+1 : i+3])
-				ref := resolveConstantRef(cp, idx)
-				fmt.Fprintf(&sb, "%4d: %-16s #%d // %s\n", i, name, idx, ref)
-			}
-			i += 3
+		// Constant pool index, plus the count of invokeinterface or the
+		// dimensions of multianewarray
+		case 18, 19, 20, 178, 179, 180, 181, 182, 183, 184, 186, 187, 189, 192, 193:
+			fmt.Fprintf(&sb, "%4d: %-16s %s\n", i, name, cpOperand(in.Operands[0], in.Comment, ""))
+		case 185, 197:
+			extra := fmt.Sprintf(", %d", in.Operands[1])
+			fmt.Fprintf(&sb, "%4d: %-16s %s\n", i, name, cpOperand(in.Operands[0], in.Comment, extra))
 
-		// 2-byte signed branch offset
+		// Branches: if*, goto, jsr, ifnull, ifnonnull, goto_w, jsr_w
 		case 153, 154, 155, 156, 157, 158, 159, 160, 161, 162, 163, 164,
-			165, 166, 167, 168, 198, 199: // if*, goto, jsr, ifnull, ifnonnull
-			if i+2 < len(code) {
-				offset := int16(binary.BigEndian.Uint16(code[i+1 : i+3]))
-				target := i + int(offset)
-				fmt.Fprintf(&sb, "%4d: %-16s %d\n", i, name, target)
-			}
-			i += 3
+			165, 166, 167, 168, 198, 199, 200, 201:
+			fmt.Fprintf(&sb, "%4d: %-16s %s\n", i, name, branch(i, in.Operands[0]))
 
 		// sipush: 2-byte signed value
 		case 17:
-			if i+2 < len(code) {
-				val := int16(binary.BigEndian.Uint16(code[i+1 : i+3]))
-				fmt.Fprintf(&sb, "%4d: %-16s %d\n", i, name, val)
-			}
-			i += 3
+			fmt.Fprintf(&sb, "%4d: %-16s %d\n", i, name, in.Operands[0])
 
-		// iinc: 2 single-byte operands
+		// iinc: slot, signed delta
 		case 132:
-			if i+2 < len(code) {
-				fmt.Fprintf(&sb, "%4d: %-16s %d, %d\n", i, name, code[i+1], int8(code[i+2]))
+			fmt.Fprintf(&sb, "%4d: %-16s %d, %d%s\n", i, name, in.Operands[0], in.Operands[1], local)
+
+		// tableswitch, lookupswitch: default, then key, target pairs
+		case 170, 171:
+			fmt.Fprintf(&sb, "%4d: %s { // ...\n", i, name)
+			if len(in.Operands) > 0 {
+				for k := 1; k+1 < len(in.Operands); k += 2 {
+					fmt.Fprintf(&sb, "%12d: %s\n", in.Operands[k], branch(i, in.Operands[k+1]))
+				}
+				fmt.Fprintf(&sb, "     default: %s\n", branch(i, in.Operands[0]))
 			}
-			i += 3
+			sb.WriteString("      }\n")
 
-		// invokeinterface: 2-byte CP index + count + 0
-		case 185:
-			if i+4 < len(code) {
-				idx := binary.BigEndian.Uint16(code[i+1 : i+3])
-				ref := resolveConstantRef(cp, idx)
-				fmt.Fprintf(&sb, "%4d: %-16s #%d, %d // %s\n", i, name, idx, code[i+3], ref)
-			}
-			i += 5
-
-		// invokedynamic: 2-byte CP index + 0 + 0
-		case 186:
-			if i+4 < len(code) {
-				idx := binary.BigEndian.Uint16(code[i+1 : i+3])
-				ref := resolveConstantRef(cp, idx)
-				fmt.Fprintf(&sb, "%4d: %-16s #%d // %s\n", i, name, idx, ref)
+		// wide: 2-byte slot, plus a signed delta for iinc
+		case 196:
+			if len(in.Operands) == 2 {
+				fmt.Fprintf(&sb, "%4d: wide %-12s %d, %d%s\n", i, name, in.Operands[0], in.Operands[1], local)
+			} else {
+				fmt.Fprintf(&sb, "%4d: wide %-12s %d%s\n", i, name, in.Operands[0], local)
 			}
-			i += 5
-
-		// multianewarray: 2-byte CP index + 1-byte dimensions
-		case 197:
-			if i+3 < len(code) {
-				idx := binary.BigEndian.Uint16(code[i+1 : i+3])
-				ref := resolveConstantRef(cp, idx)
-				fmt.Fprintf(&sb, "%4d: %-16s #%d, %d // %s\n", i, name, idx, code[i+3], ref)
+
+		default:
+			if opcodeNames[op] == "" {
+				fmt.Fprintf(&sb, "%4d: 0x%02x (unknown)\n", i, op)
+			} else {
+				fmt.Fprintf(&sb, "%4d: %s%s\n", i, name, local)
 			}
-			i += 4
+		}
 
-		// goto_w, jsr_w: 4-byte signed branch offset
-		case 200, 201:
-			if i+4 < len(code) {
-				offset := int32(binary.BigEndian.Uint32(code[i+1 : i+5]))
-				target := i + int(offset)
-				fmt.Fprintf(&sb, "%4d: %-16s %d\n", i, name, target)
+		// Stack depths go at the end of the instruction's first line
+		if d, ok := depths[i]; ok {
+			text := append([]byte(nil), sb.Bytes()[start:]...)
+			sb.Truncate(start)
+			if nl := bytes.IndexByte(text, '\n'); nl >= 0 {
+				sb.Write(text[:nl])
+				fmt.Fprintf(&sb, "  [stack %d -> %d]", d.Before, d.After)
+				text = text[nl:]
 			}
-			i += 5
+			sb.Write(text)
+		}
+	}
+	return sb.String()
+}
 
-		// tableswitch: variable length
-		case 170:
-			fmt.Fprintf(&sb, "%4d: tableswitch { // ...\n", i)
-			i++
-			// skip padding to 4-byte alignment
-			for i%4 != 0 {
-				i++
+// unknownOpcodes returns the distinct opcodes in code that disassemble
+// reports as unknown, in ascending order.
+func unknownOpcodes(code []byte) []int {
+	var seen [256]bool
+	for i := 0; i < len(code); i += insnLength(code, i) {
+		if opcodeNames[code[i]] == "" {
+			seen[code[i]] = true
+		}
+	}
+	var ops []int
+	for op, ok := range seen {
+		if ok {
+			ops = append(ops, op)
+		}
+	}
+	return ops
+}
+
+// truncatedPC returns the PC of an instruction whose operands run past
+// the end of code, or -1 if code ends cleanly.
+func truncatedPC(code []byte) int {
+	for i := 0; i < len(code); i += insnLength(code, i) {
+		if i+insnSize(code, i) > len(code) {
+			return i
+		}
+	}
+	return -1
+}
+
+// switches decodes every tableswitch/lookupswitch in code.
+func switches(code []byte) []SwitchInfo {
+	var out []SwitchInfo
+	for i := 0; i < len(code); i += insnLength(code, i) {
+		if code[i] != 170 && code[i] != 171 {
+			continue
+		}
+		t, ok := decodeSwitch(code, i)
+		if !ok {
+			continue
+		}
+		sw := SwitchInfo{PC: i, Opcode: opcodeNames[code[i]], Default: t.Default, Cases: []SwitchCase{}}
+		for k, key := range t.Keys {
+			sw.Cases = append(sw.Cases, SwitchCase{Key: key, Target: t.Targets[k]})
+		}
+		out = append(out, sw)
+	}
+	return out
+}
+
+// fieldAccesses returns the distinct fields code reads (getstatic,
+// getfield) and writes (putstatic, putfield), in order of first use.
+func fieldAccesses(code []byte, cp *parser.ConstantPool) (reads, writes []string) {
+	seenRead := make(map[string]bool)
+	seenWrite := make(map[string]bool)
+	for i := 0; i < len(code); i += insnLength(code, i) {
+		op := code[i]
+		if op < 178 || op > 181 || i+2 >= len(code) {
+			continue
+		}
+		ref := resolveConstantRef(cp, binary.BigEndian.Uint16(code[i+1:i+3]))
+		switch op {
+		case 178, 180: // getstatic, getfield
+			if !seenRead[ref] {
+				seenRead[ref] = true
+				reads = append(reads, ref)
 			}
-			if i+12 <= len(code) {
-				defaultOff := int32(binary.BigEndian.Uint32(code[i : i+4]))
-				low := int32(binary.BigEndian.Uint32(code[i+4 : i+8]))
-				high := int32(binary.BigEndian.Uint32(code[i+8 : i+12]))
-				i += 12
-				for j := low; j <= high && i+4 <= len(code); j++ {
-					off := int32(binary.BigEndian.Uint32(code[i : i+4]))
-					fmt.Fprintf(&sb, "%12d: %d\n", j, int(off)+i-12-1)
-					i += 4
-				}
-				fmt.Fprintf(&sb, "     default: %d\n", int(defaultOff)+i-12-1)
+		case 179, 181: // putstatic, putfield
+			if !seenWrite[ref] {
+				seenWrite[ref] = true
+				writes = append(writes, ref)
 			}
-			sb.WriteString("      }\n")
+		}
+	}
+	return reads, writes
+}
 
-		// lookupswitch: variable length
-		case 171:
-			basePC := i
-			fmt.Fprintf(&sb, "%4d: lookupswitch { // ...\n", i)
-			i++
-			for i%4 != 0 {
-				i++
-			}
-			if i+8 <= len(code) {
-				defaultOff := int32(binary.BigEndian.Uint32(code[i : i+4]))
-				npairs := int32(binary.BigEndian.Uint32(code[i+4 : i+8]))
-				i += 8
-				for j := int32(0); j < npairs && i+8 <= len(code); j++ {
-					matchVal := int32(binary.BigEndian.Uint32(code[i : i+4]))
-					off := int32(binary.BigEndian.Uint32(code[i+4 : i+8]))
-					fmt.Fprintf(&sb, "%12d: %d\n", matchVal, basePC+int(off))
-					i += 8
-				}
-				fmt.Fprintf(&sb, "     default: %d\n", basePC+int(defaultOff))
+// findStringRefs returns the PCs of ldc/ldc_w instructions in code that
+// load the String constant literal.
+func findStringRefs(code []byte, cp *parser.ConstantPool, literal string) []int {
+	var pcs []int
+	for i := 0; i < len(code); i += insnLength(code, i) {
+		var idx uint16
+		switch {
+		case code[i] == 18 && i+1 < len(code): // ldc
+			idx = uint16(code[i+1])
+		case code[i] == 19 && i+2 < len(code): // ldc_w
+			idx = binary.BigEndian.Uint16(code[i+1 : i+3])
+		default:
+			continue
+		}
+		if idx < 1 || int(idx) > len(cp.Constants) {
+			continue
+		}
+		if s, ok := cp.Constants[idx-1].(*parser.ConstantString); ok {
+			if v, ok := lookupUtf8(cp, s.StringIndex); ok && v == literal {
+				pcs = append(pcs, i)
 			}
-			sb.WriteString("      }\n")
+		}
+	}
+	return pcs
+}
 
-		// wide: prefix for wider operands
-		case 196:
-			if i+1 < len(code) {
-				wideOp := code[i+1]
-				wideName := opcodeNames[wideOp]
-				if wideName == "" {
-					wideName = fmt.Sprintf("0x%02x", wideOp)
-				}
-				if wideOp == 132 { // wide iinc
-					if i+5 < len(code) {
-						idx := binary.BigEndian.Uint16(code[i+2 : i+4])
-						val := int16(binary.BigEndian.Uint16(code[i+4 : i+6]))
-						fmt.Fprintf(&sb, "%4d: wide %-12s %d, %d\n", i, wideName, idx, val)
-					}
-					i += 6
-				} else {
-					if i+3 < len(code) {
-						idx := binary.BigEndian.Uint16(code[i+2 : i+4])
-						fmt.Fprintf(&sb, "%4d: wide %-12s %d\n", i, wideName, idx)
-					}
-					i += 4
-				}
-			} else {
-				fmt.Fprintf(&sb, "%4d: wide\n", i)
-				i += 2
+// ---------------------------------------------------------------------------
+// Parse options
+// ---------------------------------------------------------------------------
+
+// classOptions controls parseClassFile.
+type classOptions struct {
+	// MethodFilter limits disassembly to methods matching "name" or
+	// "name:descriptor". Other methods are still listed, without Bytecode.
+	MethodFilter string
+
+	// IncludeRawAttributes fills ClassInfo.UnknownAttributeData.
+	IncludeRawAttributes bool
+
+	// RelativeOffsets prints branch and switch targets as signed offsets
+	// from the instruction ("goto +15") instead of absolute PCs
+	// (offsetStyle "relative").
+	RelativeOffsets bool
+
+	// BytecodeComments picks how constant pool operands read
+	// (bytecodeComments option): "both" (default) "#12 // symbol",
+	// "symbol" "symbol // #12", "index" "#12", "none" "symbol".
+	BytecodeComments string
+
+	// ReadableDescriptors renders the descriptors in member references
+	// of the disassembly as Java types (descriptorStyle "readable"), see
+	// formatConstant. The default "raw" keeps JVM descriptors.
+	ReadableDescriptors bool
+
+	// Verbose fills ClassInfo.Warnings.
+	Verbose bool
+
+	// SortMembers orders Fields and Methods: sortDeclared (default),
+	// sortName or sortAccess. See sortMembers.
+	SortMembers string
+
+	// SimulateStack fills MethodInfo.StackDepths and StackIssues for
+	// disassembled methods and annotates their Bytecode with the depths.
+	SimulateStack bool
+
+	// StructuredBytecode fills MethodInfo.Instructions for disassembled
+	// methods, alongside Bytecode.
+	StructuredBytecode bool
+
+	// ShowSuperFlag lists ACC_SUPER as "super" in ClassInfo.AccessFlags.
+	ShowSuperFlag bool
+
+	// Strict fails the parse on the first construct that would otherwise
+	// be tolerated and, under Verbose, warned about: unknown opcodes and
+	// attributes, truncated code, unscannable Code attributes.
+	Strict bool
+}
+
+// maxRawAttributeBytes caps the total size of UnknownAttributeData
+// (before base64); attributes past the cap are named but not included.
+const maxRawAttributeBytes = 256 * 1024
+
+// classOptionsFromJS reads classOptions from a JS options object. An
+// undefined or null object yields the defaults.
+func classOptionsFromJS(v js.Value) (classOptions, error) {
+	var opts classOptions
+	if v.IsUndefined() || v.IsNull() {
+		return opts, nil
+	}
+
+	var err error
+	if opts.MethodFilter, _, err = archive.OptionString(v, "methodFilter"); err != nil {
+		return opts, err
+	}
+	opts.IncludeRawAttributes = v.Get("includeRawAttributes").Truthy()
+	opts.Verbose = v.Get("verbose").Truthy()
+	opts.SimulateStack = v.Get("simulateStack").Truthy()
+	opts.StructuredBytecode = v.Get("structuredBytecode").Truthy()
+	opts.ShowSuperFlag = v.Get("showSuperFlag").Truthy()
+	opts.Strict = v.Get("strict").Truthy()
+
+	style, _, err := archive.OptionString(v, "offsetStyle", "absolute", "relative")
+	if err != nil {
+		return opts, err
+	}
+	opts.RelativeOffsets = style == "relative"
+
+	style, _, err = archive.OptionString(v, "descriptorStyle", "raw", "readable")
+	if err != nil {
+		return opts, err
+	}
+	opts.ReadableDescriptors = style == "readable"
+
+	if opts.SortMembers, _, err = archive.OptionString(v, "sortMembers", sortDeclared, sortName, sortAccess); err != nil {
+		return opts, err
+	}
+	if opts.BytecodeComments, _, err = archive.OptionString(v, "bytecodeComments", "index", "symbol", "both", "none"); err != nil {
+		return opts, err
+	}
+
+	return opts, nil
+}
+
+// disassembles reports whether the bytecode of method name/desc is wanted.
+func (o classOptions) disassembles(name, desc string) bool {
+	if o.MethodFilter == "" {
+		return true
+	}
+	if fname, fdesc, ok := strings.Cut(o.MethodFilter, ":"); ok {
+		return fname == name && fdesc == desc
+	}
+	return o.MethodFilter == name
+}
+
+// ---------------------------------------------------------------------------
+// Nested class names
+// ---------------------------------------------------------------------------
+
+// nestedNames works out the source-level names of a class from the
+// InnerClasses table, which lists this class and its enclosing classes
+// when they are nested. It returns the simple name, the binary name of
+// the directly enclosing class ("" for top-level classes) and a display
+// name with "." between the nesting levels (com.example.Outer.Inner).
+// Anonymous classes have no inner name and keep their numeric one ("1");
+// anonymous and local classes have no outer class entry, so their
+// enclosing class comes from the binary name.
+func nestedNames(cf *parser.Classfile, className string) (simple, outer, display string) {
+	type nesting struct{ outer, simple string }
+	table := make(map[string]nesting)
+	if ic := cf.InnerClasses(); ic != nil {
+		for _, e := range ic.InnerClasses {
+			inner, ok := lookupClassName(cf.ConstantPool, e.InnerClassInfoIndex)
+			if !ok {
+				continue
 			}
+			inner = strings.ReplaceAll(inner, "/", ".")
+			dollar := strings.LastIndexByte(inner, '$')
+
+			var n nesting
+			if o, ok := lookupClassName(cf.ConstantPool, e.OuterClassInfoIndex); ok {
+				n.outer = strings.ReplaceAll(o, "/", ".")
+			} else if dollar > 0 {
+				n.outer = inner[:dollar]
+			}
+			n.simple, _ = lookupUtf8(cf.ConstantPool, e.InnerNameIndex)
+			if n.simple == "" && dollar >= 0 {
+				n.simple = inner[dollar+1:]
+			}
+			if n.outer != "" && n.simple != "" {
+				table[inner] = n
+			}
+		}
+	}
 
-		default:
-			fmt.Fprintf(&sb, "%4d: 0x%02x (unknown)\n", i, op)
-			i++
+	var render func(name string, depth int) string
+	render = func(name string, depth int) string {
+		n, ok := table[name]
+		if !ok || depth > 32 {
+			return name
 		}
+		return render(n.outer, depth+1) + "." + n.simple
 	}
-	return sb.String()
+
+	if n, ok := table[className]; ok {
+		return n.simple, n.outer, render(className, 0)
+	}
+	return className[strings.LastIndexByte(className, '.')+1:], "", className
 }
 
 // ---------------------------------------------------------------------------
 // Main parse function
 // ---------------------------------------------------------------------------
 
-func parseClassFile(data []byte) (*ClassInfo, error) {
-	p := parser.New(bytes.NewReader(data))
-	cf, err := p.Parse()
+// parseClassBytes parses data with the classfile library, first removing
+// the attributes it cannot decode (see stripUnknownAttributes). It
+// returns the bytes actually parsed and the removed attributes.
+func parseClassBytes(data []byte) (*parser.Classfile, []byte, []rawAttribute, error) {
+	clean, unknown, err := stripUnknownAttributes(data)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to parse class file: %w", err)
+	}
+	cf, err := parser.New(bytes.NewReader(clean)).Parse()
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to parse class file: %w", err)
+	}
+	return cf, clean, unknown, nil
+}
+
+// recordComponents decodes the Record attribute, including each
+// component's own Signature attribute. It returns nil for non-records.
+func recordComponents(cf *parser.Classfile) []RecordComponentInfo {
+	cp := cf.ConstantPool
+	for _, a := range cf.Attributes {
+		rec, ok := a.(*parser.AttributeRecord)
+		if !ok {
+			continue
+		}
+		comps := make([]RecordComponentInfo, 0, len(rec.Components))
+		for _, c := range rec.Components {
+			name, _ := lookupUtf8(cp, c.NameIndex)
+			desc, _ := lookupUtf8(cp, c.DescriptorIndex)
+			rc := RecordComponentInfo{
+				Name:       name,
+				Descriptor: desc,
+				TypeName:   parseFieldDescriptor(desc),
+			}
+			for _, ca := range c.Attributes {
+				if sig, ok := ca.(*parser.AttributeSignature); ok {
+					rc.Signature, _ = lookupUtf8(cp, sig.Signature)
+					if t, ok := parseFieldSignature(rc.Signature); ok {
+						rc.GenericType = t
+					}
+				}
+			}
+			comps = append(comps, rc)
+		}
+		return comps
+	}
+	return nil
+}
+
+// recoverMalformed turns a panic while decoding a corrupt class file
+// into an error, so one bad class cannot take down the module.
+func recoverMalformed(err *error) {
+	if r := recover(); r != nil {
+		*err = fmt.Errorf("malformed class file: %v", r)
+	}
+}
+
+func parseClassFile(data []byte, opts classOptions) (_ *ClassInfo, err error) {
+	defer recoverMalformed(&err)
+
+	cf, data, unknownAttrs, err := parseClassBytes(data)
 	if err != nil {
-		return nil, fmt.Errorf("failed to parse class file: %w", err)
+		return nil, err
 	}
 
 	cp := cf.ConstantPool
 
+	var warnings []string
+	warn := func(format string, a ...any) {
+		if opts.Verbose {
+			warnings = append(warnings, fmt.Sprintf(format, a...))
+		}
+	}
+	// unsupported reports a construct the parser cannot fully represent:
+	// a warning, or under opts.Strict the error that fails the parse.
+	unsupported := func(format string, a ...any) error {
+		if opts.Strict {
+			return fmt.Errorf("strict: "+format, a...)
+		}
+		warn(format, a...)
+		return nil
+	}
+
 	// Class name
-	className, err := cf.ThisClassName()
-	if err != nil {
+	className, ok := lookupClassName(cp, cf.ThisClass)
+	if !ok {
 		className = "?"
 	}
 	className = strings.ReplaceAll(className, "/", ".")
 
+	simpleName, outerName, displayName := nestedNames(cf, className)
+
 	// Super class
 	superClass := ""
 	if cf.SuperClass != 0 {
-		sc, err := cf.SuperClassName()
-		if err == nil {
+		if sc, ok := lookupClassName(cp, cf.SuperClass); ok {
 			superClass = strings.ReplaceAll(sc, "/", ".")
 		}
 	}
@@ -610,127 +1262,468 @@ func parseClassFile(data []byte) (*ClassInfo, error) {
 	// Interfaces (must be non-nil so JSON encodes as [] not null)
 	interfaces := make([]string, 0)
 	for _, idx := range cf.Interfaces {
-		iName, err := cp.GetClassName(idx)
-		if err == nil {
+		if iName, ok := lookupClassName(cp, idx); ok {
 			interfaces = append(interfaces, strings.ReplaceAll(iName, "/", "."))
 		}
 	}
 
 	// Java version
-	javaVersion := majorVersionMap[int(cf.MajorVersion)]
-	if javaVersion == "" {
-		javaVersion = fmt.Sprintf("unknown (%d)", cf.MajorVersion)
-	}
+	javaVersion := archive.JavaVersion(int(cf.MajorVersion))
 
 	// Source file
 	sourceFile := ""
 	if sf := cf.SourceFile(); sf != nil {
-		if utf8 := cp.LookupUtf8(sf.SourcefileIndex); utf8 != nil {
-			sourceFile = utf8.String()
-		}
+		sourceFile, _ = lookupUtf8(cp, sf.SourcefileIndex)
 	}
 
 	// Signature
 	signature := ""
 	if sig := cf.Signature(); sig != nil {
-		if utf8 := cp.LookupUtf8(sig.Signature); utf8 != nil {
-			signature = utf8.String()
-		}
+		signature, _ = lookupUtf8(cp, sig.Signature)
 	}
 
 	// Fields
 	fields := make([]FieldInfo, 0, len(cf.Fields))
 	for _, f := range cf.Fields {
-		name, _ := f.Name(cp)
-		desc, _ := f.Descriptor(cp)
+		name, _ := lookupUtf8(cp, f.NameIndex)
+		desc, _ := lookupUtf8(cp, f.DescriptorIndex)
 		fi := FieldInfo{
-			AccessFlags: fieldAccessFlags(f.AccessFlags),
-			Name:        name,
-			Descriptor:  desc,
-			TypeName:    parseFieldDescriptor(desc),
+			AccessFlags:    fieldAccessFlags(f.AccessFlags),
+			RawAccessFlags: int(f.AccessFlags),
+			Name:           name,
+			Descriptor:     desc,
+			TypeName:       parseFieldDescriptor(desc),
 		}
 		if sig := f.Signature(); sig != nil {
-			if utf8 := cp.LookupUtf8(sig.Signature); utf8 != nil {
-				fi.Signature = utf8.String()
-			}
+			fi.Signature, _ = lookupUtf8(cp, sig.Signature)
 		}
+		fi.DeclarationString = fieldDeclaration(&fi, f.AccessFlags)
 		fields = append(fields, fi)
 	}
 
+	// Attributes nested in Code, which the library does not read. The
+	// library already accepted the file, so a scan error is not fatal.
+	codeAttrs, scanErr := scanCodeAttributes(data, cp)
+	if scanErr != nil {
+		if err := unsupported("Code attributes not scanned (no local variable names or unknownCodeAttributes): %v", scanErr); err != nil {
+			return nil, err
+		}
+	}
+
 	// Methods
+	isInterface := cf.AccessFlags.Is(accInterface)
 	methods := make([]MethodInfo, 0, len(cf.Methods))
-	for _, m := range cf.Methods {
-		name, _ := m.Name(cp)
-		desc, _ := m.Descriptor(cp)
-		paramTypes, retType := parseMethodDescriptor(desc)
-
-		mi := MethodInfo{
-			AccessFlags: methodAccessFlags(m.AccessFlags),
-			Name:        name,
-			Descriptor:  desc,
-			ReturnType:  retType,
-			ParamTypes:  paramTypes,
-		}
-
-		// Exceptions
-		if exc := m.Exceptions(); exc != nil {
-			for _, idx := range exc.ExceptionIndexes {
-				eName, err := cp.GetClassName(idx)
-				if err == nil {
-					mi.Exceptions = append(mi.Exceptions, strings.ReplaceAll(eName, "/", "."))
-				}
-			}
+	publicMethods, abstractMethods := 0, 0
+	for mIdx, m := range cf.Methods {
+		if m.AccessFlags.Is(parser.ACC_PUBLIC) {
+			publicMethods++
 		}
-
-		// Signature
-		if sig := m.Signature(); sig != nil {
-			if utf8 := cp.LookupUtf8(sig.Signature); utf8 != nil {
-				mi.Signature = utf8.String()
-			}
+		if m.AccessFlags.Is(parser.ACC_ABSTRACT) {
+			abstractMethods++
 		}
 
+		mi := methodHeader(cp, className, m, isInterface)
+		name, desc := mi.Name, mi.Descriptor
+
 		// Bytecode disassembly
 		if codeAttr := m.Code(); codeAttr != nil {
 			mi.MaxStack = int(codeAttr.MaxStack)
 			mi.MaxLocals = int(codeAttr.MaxLocals)
-			mi.Bytecode = disassemble(codeAttr.Codes, cp)
+			if mIdx < len(codeAttrs) {
+				mi.UnknownCodeAttributes = unknownCodeAttributes(codeAttrs[mIdx])
+			}
+			mi.FieldReads, mi.FieldWrites = fieldAccesses(codeAttr.Codes, cp)
+			mi.BranchTargets = branchTargets(codeAttr.Codes)
+			for _, e := range codeAttr.ExceptionTable {
+				if e.CatchType == 0 {
+					mi.HasFinally = true
+				} else {
+					mi.HasCatch = true
+				}
+			}
+			if opts.disassembles(name, desc) {
+				var vars []localVar
+				if mIdx < len(codeAttrs) {
+					vars = localVariables(codeAttrs[mIdx], cp)
+				}
+				if opts.SimulateStack {
+					handlers := make([]int, 0, len(codeAttr.ExceptionTable))
+					for _, e := range codeAttr.ExceptionTable {
+						handlers = append(handlers, int(e.HandlerPc))
+					}
+					mi.StackDepths, mi.StackIssues = simulateStack(codeAttr.Codes, cp, handlers, mi.MaxStack)
+				}
+				mi.Bytecode = disassemble(codeAttr.Codes, cp, vars, mi.StackDepths, opts)
+				if opts.StructuredBytecode {
+					mi.Instructions = decodeInstructions(codeAttr.Codes, cp, vars, opts)
+				}
+				mi.UnknownOpcodes = unknownOpcodes(codeAttr.Codes)
+				mi.Switches = switches(codeAttr.Codes)
+			}
+			if pc := truncatedPC(codeAttr.Codes); pc >= 0 {
+				if err := unsupported("%s: code ends inside the instruction at pc %d", mi.Key, pc); err != nil {
+					return nil, err
+				}
+			}
+			for _, op := range unknownOpcodes(codeAttr.Codes) {
+				if err := unsupported("%s: unknown opcode 0x%02x", mi.Key, op); err != nil {
+					return nil, err
+				}
+			}
+			for _, a := range mi.UnknownCodeAttributes {
+				if err := unsupported("%s: Code attribute %s not decoded", mi.Key, a); err != nil {
+					return nil, err
+				}
+			}
 		}
 
 		methods = append(methods, mi)
 	}
+	sortMembers(fields, methods, opts.SortMembers)
+
+	// Attributes the library could not decode
+	var unknownAttrNames []string
+	var unknownAttrData map[string]string
+	rawBytes := 0
+	for _, a := range unknownAttrs {
+		if a.Member != "" {
+			if err := unsupported("%s: attribute %s not decoded", a.Member, a.Name); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		unknownAttrNames = append(unknownAttrNames, a.Name)
+		if err := unsupported("class attribute %s not decoded", a.Name); err != nil {
+			return nil, err
+		}
+		if !opts.IncludeRawAttributes {
+			continue
+		}
+		if rawBytes+len(a.Data) > maxRawAttributeBytes {
+			warn("class attribute %s data left out: over the %d byte cap", a.Name, maxRawAttributeBytes)
+			continue
+		}
+		if unknownAttrData == nil {
+			unknownAttrData = make(map[string]string)
+		}
+		unknownAttrData[a.Name] = base64.StdEncoding.EncodeToString(a.Data)
+		rawBytes += len(a.Data)
+	}
 
 	return &ClassInfo{
-		MajorVersion: int(cf.MajorVersion),
-		MinorVersion: int(cf.MinorVersion),
-		JavaVersion:  javaVersion,
-		AccessFlags:  classAccessFlags(cf.AccessFlags),
-		ClassName:    className,
-		SuperClass:   superClass,
-		Interfaces:   interfaces,
-		SourceFile:   sourceFile,
-		Fields:       fields,
-		Methods:      methods,
-		IsDeprecated: cf.Deprecated() != nil,
-		Signature:    signature,
+		SchemaVersion:  archive.SchemaVersion,
+		MajorVersion:   int(cf.MajorVersion),
+		MinorVersion:   int(cf.MinorVersion),
+		JavaVersion:    javaVersion,
+		AccessFlags:    classAccessFlags(cf.AccessFlags, opts.ShowSuperFlag),
+		RawAccessFlags: int(cf.AccessFlags),
+		ClassName:      className,
+		SimpleName:     simpleName,
+		OuterName:      outerName,
+		DisplayName:    displayName,
+		SuperClass:     superClass,
+		Interfaces:     interfaces,
+		SourceFile:     sourceFile,
+		Fields:         fields,
+		Methods:        methods,
+		IsDeprecated:   cf.Deprecated() != nil,
+		Signature:      signature,
+
+		Imports:         classImports(cf, className),
+		SourceLineSpan:  sourceLineSpan(codeAttrs),
+		PreviewFeatures: cf.MinorVersion == 0xFFFF,
+
+		RecordComponents: recordComponents(cf),
+
+		FieldCount:          len(fields),
+		MethodCount:         len(methods),
+		PublicMethodCount:   publicMethods,
+		AbstractMethodCount: abstractMethods,
+
+		UnknownAttributes:    unknownAttrNames,
+		UnknownAttributeData: unknownAttrData,
+
+		KotlinMetadata: kotlinMetadata(cf),
+
+		Warnings: warnings,
 	}, nil
 }
 
 // ---------------------------------------------------------------------------
-// JS exports
+// String references
+// ---------------------------------------------------------------------------
+
+// methodHeader decodes everything about method m of class className
+// but its Code: flags, descriptor types, thrown exceptions, generic
+// signature and the declaration built from them.
+func methodHeader(cp *parser.ConstantPool, className string, m *parser.Method, isInterface bool) MethodInfo {
+	name, _ := lookupUtf8(cp, m.NameIndex)
+	desc, _ := lookupUtf8(cp, m.DescriptorIndex)
+	paramTypes, retType := parseMethodDescriptor(desc)
+
+	mi := MethodInfo{
+		AccessFlags:         methodAccessFlags(m.AccessFlags),
+		RawAccessFlags:      int(m.AccessFlags),
+		Name:                name,
+		Descriptor:          desc,
+		Key:                 name + desc,
+		ReturnType:          retType,
+		ParamTypes:          paramTypes,
+		IsDefault:           isInterface && !m.AccessFlags.Is(parser.ACC_ABSTRACT|parser.ACC_STATIC|parser.ACC_PRIVATE),
+		IsConstructor:       name == "<init>",
+		IsStaticInitializer: name == "<clinit>",
+	}
+
+	// Exceptions
+	if exc := m.Exceptions(); exc != nil {
+		for _, idx := range exc.ExceptionIndexes {
+			if eName, ok := lookupClassName(cp, idx); ok {
+				mi.Exceptions = append(mi.Exceptions, strings.ReplaceAll(eName, "/", "."))
+			}
+		}
+	}
+
+	// Signature
+	if sig := m.Signature(); sig != nil {
+		mi.Signature, _ = lookupUtf8(cp, sig.Signature)
+	}
+
+	// Declaration, with parameter names when MethodParameters has them
+	var paramNames []string
+	if mp := m.MethodParameters(); mp != nil {
+		for _, p := range mp.Parameters {
+			pName, _ := lookupUtf8(cp, p.NameIndex)
+			paramNames = append(paramNames, pName)
+		}
+	}
+	mi.DeclarationString = methodDeclaration(className, &mi, m.AccessFlags, paramNames)
+	return mi
+}
+
+// classSignatures is the fast path of __wasm_classSignatures: the
+// class name and its methods' headers (see methodHeader), without
+// scanning, disassembling or analysing any Code. opts.SortMembers orders
+// the methods as in parseClassFile.
+func classSignatures(data []byte, opts classOptions) (_ *ClassSignatures, err error) {
+	defer recoverMalformed(&err)
+
+	cf, _, _, err := parseClassBytes(data)
+	if err != nil {
+		return nil, err
+	}
+
+	cp := cf.ConstantPool
+	className, ok := lookupClassName(cp, cf.ThisClass)
+	if !ok {
+		className = "?"
+	}
+	className = strings.ReplaceAll(className, "/", ".")
+
+	isInterface := cf.AccessFlags.Is(accInterface)
+	result := &ClassSignatures{ClassName: className, Methods: make([]MethodInfo, 0, len(cf.Methods))}
+	for _, m := range cf.Methods {
+		result.Methods = append(result.Methods, methodHeader(cp, className, m, isInterface))
+	}
+	sortMembers(nil, result.Methods, opts.SortMembers)
+	return result, nil
+}
+
+// classStringRefs lists every place a method of the class loads the
+// String constant literal, in method order.
+func classStringRefs(data []byte, literal string) (_ []StringRef, err error) {
+	defer recoverMalformed(&err)
+
+	cf, _, _, err := parseClassBytes(data)
+	if err != nil {
+		return nil, err
+	}
+
+	cp := cf.ConstantPool
+	refs := make([]StringRef, 0)
+	for _, m := range cf.Methods {
+		codeAttr := m.Code()
+		if codeAttr == nil {
+			continue
+		}
+		name, _ := lookupUtf8(cp, m.NameIndex)
+		desc, _ := lookupUtf8(cp, m.DescriptorIndex)
+		for _, pc := range findStringRefs(codeAttr.Codes, cp, literal) {
+			refs = append(refs, StringRef{Method: name + ":" + desc, PC: pc})
+		}
+	}
+	return refs, nil
+}
+
+// ---------------------------------------------------------------------------
+// Single-method listings
+// ---------------------------------------------------------------------------
+
+// methodListing renders one method javap -c style: its declaration, then
+// its disassembled Code indented underneath. desc may be "" to take the
+// first method called name. Like classSignatures it skips the rest of
+// parseClassFile: only the chosen method's Code is decoded.
+func methodListing(data []byte, name, desc string) (_ string, err error) {
+	defer recoverMalformed(&err)
+
+	cf, data, _, err := parseClassBytes(data)
+	if err != nil {
+		return "", err
+	}
+
+	cp := cf.ConstantPool
+	className, ok := lookupClassName(cp, cf.ThisClass)
+	if !ok {
+		className = "?"
+	}
+	className = strings.ReplaceAll(className, "/", ".")
+
+	filter := name
+	if desc != "" {
+		filter += ":" + desc
+	}
+	opts := classOptions{MethodFilter: filter}
+	for mIdx, m := range cf.Methods {
+		mName, _ := lookupUtf8(cp, m.NameIndex)
+		mDesc, _ := lookupUtf8(cp, m.DescriptorIndex)
+		if !opts.disassembles(mName, mDesc) {
+			continue
+		}
+
+		mi := methodHeader(cp, className, m, cf.AccessFlags.Is(accInterface))
+		var sb strings.Builder
+		sb.WriteString(mi.DeclarationString + ";\n")
+		codeAttr := m.Code()
+		if codeAttr == nil {
+			return sb.String(), nil
+		}
+		// Local variable names are a nicety: without them the listing
+		// still stands, as in parseClassFile.
+		var vars []localVar
+		if codeAttrs, err := scanCodeAttributes(data, cp); err == nil && mIdx < len(codeAttrs) {
+			vars = localVariables(codeAttrs[mIdx], cp)
+		}
+		sb.WriteString("  Code:\n")
+		for _, line := range strings.SplitAfter(disassemble(codeAttr.Codes, cp, vars, nil, opts), "\n") {
+			if line != "" {
+				sb.WriteString("    " + line)
+			}
+		}
+		return sb.String(), nil
+	}
+	return "", fmt.Errorf("no method %s in %s", filter, className)
+}
+
+// ---------------------------------------------------------------------------
+// Blob reads
+// ---------------------------------------------------------------------------
+
+// readClassFromBlob reads a class file's bytes from a range of a JS Blob,
+// typically a jar, at a zip entry's DataOffset/CompressedSize. method is
+// the entry's zip compression method: "store" (the default) or
+// "deflate", in which case the range is inflated first.
+func readClassFromBlob(blob js.Value, offset, size int64, method string) ([]byte, error) {
+	data, err := archive.ReadBlob(blob, offset, size)
+	if err != nil {
+		return nil, err
+	}
+
+	switch method {
+	case "", "store":
+		return data, nil
+	case "deflate":
+		fr := flate.NewReader(bytes.NewReader(data))
+		defer fr.Close()
+		return readClassAtMost(fr, archive.MaxTotalSize)
+	}
+	return nil, fmt.Errorf("unknown compression method %q (want store or deflate)", method)
+}
+
+// readClassAtMost reads a decompressed class from r, failing if it
+// holds more than limit bytes.
+func readClassAtMost(r io.Reader, limit int64) ([]byte, error) {
+	data, err := io.ReadAll(io.LimitReader(r, limit+1))
+	if err == nil && int64(len(data)) > limit {
+		return nil, fmt.Errorf("decompressed class exceeds %d bytes", limit)
+	}
+	return data, err
+}
+
+// gunzipClass decompresses a gzipped class file (.class.gz), failing if
+// it inflates to more than archive.MaxTotalSize bytes.
+func gunzipClass(data []byte) ([]byte, error) {
+	if len(data) < 2 || data[0] != 0x1f || data[1] != 0x8b {
+		return nil, errors.New("not gzip-compressed (no 1f 8b magic)")
+	}
+	zr, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+	return readClassAtMost(zr, archive.MaxTotalSize)
+}
+
+// ---------------------------------------------------------------------------
+// Batch parsing
 // ---------------------------------------------------------------------------
 
-func jsError(msg string) any {
-	return js.Global().Get("Promise").Call("reject",
-		js.Global().Get("Error").New(msg))
+// defaultProgressEvery is how many classes parseClasses handles between
+// progress callbacks (and yields) when the caller does not say.
+const defaultProgressEvery = 50
+
+// yieldToJS parks the calling goroutine until a setTimeout(0) callback
+// fires. WASM runs on the JS thread, so without this a long batch blocks
+// rendering and input until it finishes.
+func yieldToJS() {
+	done := make(chan struct{})
+	cb := js.FuncOf(func(_ js.Value, _ []js.Value) any {
+		close(done)
+		return nil
+	})
+	defer cb.Release()
+	js.Global().Call("setTimeout", cb, 0)
+	<-done
 }
 
+// parseClassBatch parses each class in turn. After every `every` classes
+// it calls progress(done, total), if set, and yields to the JS event loop.
+// A throw from progress stops the batch and is returned.
+func parseClassBatch(classes [][]byte, opts classOptions, every int, progress js.Value) ([]ClassBatchEntry, error) {
+	results := make([]ClassBatchEntry, len(classes))
+	for i, data := range classes {
+		info, err := parseClassFile(data, opts)
+		if err != nil {
+			results[i].Error = err.Error()
+		} else {
+			results[i].Class = info
+		}
+
+		done := i + 1
+		if done%every == 0 || done == len(classes) {
+			if progress.Type() == js.TypeFunction {
+				if err := archive.InvokeCallback(progress, "progress", done, len(classes)); err != nil {
+					return nil, err
+				}
+			}
+			if done < len(classes) {
+				yieldToJS()
+			}
+		}
+	}
+	return results, nil
+}
+
+// ---------------------------------------------------------------------------
+// JS exports
+// ---------------------------------------------------------------------------
+
 func main() {
-	// __wasm_parseClass(Uint8Array) -> Promise<string>
+	// __wasm_parseClass(Uint8Array, options?: object) -> Promise<string>
 	// Parse a Java .class file from raw bytes.
 	// Returns JSON ClassInfo.
+	// options: { methodFilter?: string } (see classOptions)
 	js.Global().Set("__wasm_parseClass", js.FuncOf(func(_ js.Value, args []js.Value) any {
-		if len(args) != 1 {
-			return jsError("parseClass requires exactly 1 argument (Uint8Array)")
+		if len(args) < 1 || len(args) > 2 {
+			return archive.JSError("parseClass requires 1 or 2 arguments (Uint8Array, options?)")
 		}
 
 		handler := js.FuncOf(func(_ js.Value, promise []js.Value) any {
@@ -740,11 +1733,351 @@ func main() {
 			go func() {
 				jsArr := args[0]
 				length := jsArr.Get("length").Int()
+				var options js.Value
+				if len(args) == 2 {
+					options = args[1]
+				}
+
+				opts, err := classOptionsFromJS(options)
+				if err != nil {
+					reject.Invoke(js.Global().Get("Error").New("Invalid options: " + err.Error()))
+					return
+				}
 
 				data := make([]byte, length)
 				js.CopyBytesToGo(data, jsArr)
 
-				result, err := parseClassFile(data)
+				result, err := parseClassFile(data, opts)
+				if err != nil {
+					reject.Invoke(js.Global().Get("Error").New("Failed to parse class file: " + err.Error()))
+					return
+				}
+
+				jsonBytes, err := json.Marshal(result)
+				if err != nil {
+					reject.Invoke(js.Global().Get("Error").New("Failed to serialize result: " + err.Error()))
+					return
+				}
+
+				resolve.Invoke(string(jsonBytes))
+			}()
+
+			return nil
+		})
+
+		return js.Global().Get("Promise").New(handler)
+	}))
+
+	// __wasm_parseClassFromBlob(blob: Blob, offset: number, size: number, options?: object) -> Promise<string>
+	// Parse a class file stored in a range of a Blob (e.g. a jar entry at
+	// its dataOffset/compressedSize) without copying the whole archive.
+	// Returns JSON ClassInfo.
+	// options: parseClass options plus { method?: "store" | "deflate" },
+	// the entry's zip compression method (a zip entry whose
+	// compressedSize differs from its size is deflated).
+	js.Global().Set("__wasm_parseClassFromBlob", js.FuncOf(func(_ js.Value, args []js.Value) any {
+		if len(args) < 3 || len(args) > 4 {
+			return archive.JSError("parseClassFromBlob requires 3 or 4 arguments (blob, offset, size, options?)")
+		}
+
+		handler := js.FuncOf(func(_ js.Value, promise []js.Value) any {
+			resolve := promise[0]
+			reject := promise[1]
+
+			go func() {
+				blob := args[0]
+				offset := int64(args[1].Float())
+				size := int64(args[2].Float())
+				var options js.Value
+				if len(args) == 4 && !args[3].IsUndefined() && !args[3].IsNull() {
+					options = args[3]
+				}
+
+				opts, err := classOptionsFromJS(options)
+				if err != nil {
+					reject.Invoke(js.Global().Get("Error").New("Invalid options: " + err.Error()))
+					return
+				}
+				method, _, err := archive.OptionString(options, "method", archive.MethodStore, archive.MethodDeflate)
+				if err != nil {
+					reject.Invoke(js.Global().Get("Error").New("Invalid options: " + err.Error()))
+					return
+				}
+
+				data, err := readClassFromBlob(blob, offset, size, method)
+				if err != nil {
+					reject.Invoke(js.Global().Get("Error").New("Failed to read class file: " + err.Error()))
+					return
+				}
+
+				result, err := parseClassFile(data, opts)
+				if err != nil {
+					reject.Invoke(js.Global().Get("Error").New("Failed to parse class file: " + err.Error()))
+					return
+				}
+
+				jsonBytes, err := json.Marshal(result)
+				if err != nil {
+					reject.Invoke(js.Global().Get("Error").New("Failed to serialize result: " + err.Error()))
+					return
+				}
+
+				resolve.Invoke(string(jsonBytes))
+			}()
+
+			return nil
+		})
+
+		return js.Global().Get("Promise").New(handler)
+	}))
+
+	// __wasm_parseClassGz(Uint8Array, options?: object) -> Promise<string>
+	// parseClass for a gzip-compressed class file (.class.gz). Input
+	// without the gzip magic is rejected rather than parsed as is.
+	js.Global().Set("__wasm_parseClassGz", js.FuncOf(func(_ js.Value, args []js.Value) any {
+		if len(args) < 1 || len(args) > 2 {
+			return archive.JSError("parseClassGz requires 1 or 2 arguments (Uint8Array, options?)")
+		}
+
+		handler := js.FuncOf(func(_ js.Value, promise []js.Value) any {
+			resolve := promise[0]
+			reject := promise[1]
+
+			go func() {
+				jsArr := args[0]
+				var options js.Value
+				if len(args) == 2 {
+					options = args[1]
+				}
+
+				opts, err := classOptionsFromJS(options)
+				if err != nil {
+					reject.Invoke(js.Global().Get("Error").New("Invalid options: " + err.Error()))
+					return
+				}
+
+				compressed := make([]byte, jsArr.Get("length").Int())
+				js.CopyBytesToGo(compressed, jsArr)
+
+				data, err := gunzipClass(compressed)
+				if err != nil {
+					reject.Invoke(js.Global().Get("Error").New("Failed to read class file: " + err.Error()))
+					return
+				}
+
+				result, err := parseClassFile(data, opts)
+				if err != nil {
+					reject.Invoke(js.Global().Get("Error").New("Failed to parse class file: " + err.Error()))
+					return
+				}
+
+				jsonBytes, err := json.Marshal(result)
+				if err != nil {
+					reject.Invoke(js.Global().Get("Error").New("Failed to serialize result: " + err.Error()))
+					return
+				}
+
+				resolve.Invoke(string(jsonBytes))
+			}()
+
+			return nil
+		})
+
+		return js.Global().Get("Promise").New(handler)
+	}))
+
+	// __wasm_parseClasses(Uint8Array[], options?: object) -> Promise<string>
+	// Parse many .class files in one call. Returns a JSON array of
+	// {class?: ClassInfo, error?: string} in input order.
+	// options: { progress?: (done, total) => void, progressEvery?: number }
+	// plus the parseClass options, applied to every class.
+	// Every progressEvery classes (default 50) progress is called and the
+	// batch yields to the JS event loop via setTimeout(0), so the UI keeps
+	// painting. Each yield costs a timer tick (~4ms once browsers clamp
+	// nested timers); raise progressEvery for throughput, lower it for
+	// responsiveness. A throw from progress rejects the batch.
+	js.Global().Set("__wasm_parseClasses", js.FuncOf(func(_ js.Value, args []js.Value) any {
+		if len(args) < 1 || len(args) > 2 {
+			return archive.JSError("parseClasses requires 1 or 2 arguments (Uint8Array[], options?)")
+		}
+
+		handler := js.FuncOf(func(_ js.Value, promise []js.Value) any {
+			resolve := promise[0]
+			reject := promise[1]
+
+			go func() {
+				jsList := args[0]
+				var options js.Value
+				if len(args) == 2 && !args[1].IsUndefined() && !args[1].IsNull() {
+					options = args[1]
+				}
+
+				opts, err := classOptionsFromJS(options)
+				if err != nil {
+					reject.Invoke(js.Global().Get("Error").New("Invalid options: " + err.Error()))
+					return
+				}
+
+				every := defaultProgressEvery
+				if n, ok, err := archive.OptionNumber(options, "progressEvery", true); err != nil {
+					reject.Invoke(js.Global().Get("Error").New("Invalid options: " + err.Error()))
+					return
+				} else if ok {
+					every = int(n)
+				}
+				progress, _, err := archive.OptionFunc(options, "progress")
+				if err != nil {
+					reject.Invoke(js.Global().Get("Error").New("Invalid options: " + err.Error()))
+					return
+				}
+
+				n := jsList.Get("length").Int()
+				classes := make([][]byte, n)
+				for i := range classes {
+					jsArr := jsList.Index(i)
+					classes[i] = make([]byte, jsArr.Get("length").Int())
+					js.CopyBytesToGo(classes[i], jsArr)
+				}
+
+				result, err := parseClassBatch(classes, opts, every, progress)
+				if err != nil {
+					reject.Invoke(js.Global().Get("Error").New("Failed to parse classes: " + err.Error()))
+					return
+				}
+
+				jsonBytes, err := json.Marshal(result)
+				if err != nil {
+					reject.Invoke(js.Global().Get("Error").New("Failed to serialize result: " + err.Error()))
+					return
+				}
+
+				resolve.Invoke(string(jsonBytes))
+			}()
+
+			return nil
+		})
+
+		return js.Global().Get("Promise").New(handler)
+	}))
+
+	// __wasm_disassembleMethod(Uint8Array, options: object) -> Promise<string>
+	// javap -c style listing of one method: its declaration and bytecode.
+	// Without a descriptor the first method with that name is used.
+	// Returns plain text, not JSON.
+	// options: { name: string, descriptor?: string }
+	js.Global().Set("__wasm_disassembleMethod", js.FuncOf(func(_ js.Value, args []js.Value) any {
+		if len(args) != 2 {
+			return archive.JSError("disassembleMethod requires 2 arguments (Uint8Array, options)")
+		}
+
+		handler := js.FuncOf(func(_ js.Value, promise []js.Value) any {
+			resolve := promise[0]
+			reject := promise[1]
+
+			go func() {
+				jsArr := args[0]
+				name, err := archive.RequiredOptionString(args[1], "name")
+				var desc string
+				if err == nil {
+					desc, _, err = archive.OptionString(args[1], "descriptor")
+				}
+				if err != nil {
+					reject.Invoke(js.Global().Get("Error").New("Invalid options: " + err.Error()))
+					return
+				}
+
+				data := make([]byte, jsArr.Get("length").Int())
+				js.CopyBytesToGo(data, jsArr)
+
+				listing, err := methodListing(data, name, desc)
+				if err != nil {
+					reject.Invoke(js.Global().Get("Error").New("Failed to disassemble method: " + err.Error()))
+					return
+				}
+
+				resolve.Invoke(listing)
+			}()
+
+			return nil
+		})
+
+		return js.Global().Get("Promise").New(handler)
+	}))
+
+	// __wasm_classSignatures(Uint8Array, options?: object) -> Promise<string>
+	// A class's API outline: JSON {className, methods: MethodInfo[]}
+	// with flags, types, exceptions, generic signature and declaration
+	// of each method but no bytecode or other Code-derived fields.
+	// options: as parseClass; sortMembers applies.
+	js.Global().Set("__wasm_classSignatures", js.FuncOf(func(_ js.Value, args []js.Value) any {
+		if len(args) < 1 || len(args) > 2 {
+			return archive.JSError("classSignatures requires 1 or 2 arguments (Uint8Array, options?)")
+		}
+
+		handler := js.FuncOf(func(_ js.Value, promise []js.Value) any {
+			resolve := promise[0]
+			reject := promise[1]
+
+			go func() {
+				jsArr := args[0]
+				var options js.Value
+				if len(args) == 2 {
+					options = args[1]
+				}
+				opts, err := classOptionsFromJS(options)
+				if err != nil {
+					reject.Invoke(js.Global().Get("Error").New("Invalid options: " + err.Error()))
+					return
+				}
+				data := make([]byte, jsArr.Get("length").Int())
+				js.CopyBytesToGo(data, jsArr)
+
+				result, err := classSignatures(data, opts)
+				if err != nil {
+					reject.Invoke(js.Global().Get("Error").New("Failed to parse class file: " + err.Error()))
+					return
+				}
+
+				jsonBytes, err := json.Marshal(result)
+				if err != nil {
+					reject.Invoke(js.Global().Get("Error").New("Failed to serialize result: " + err.Error()))
+					return
+				}
+
+				resolve.Invoke(string(jsonBytes))
+			}()
+
+			return nil
+		})
+
+		return js.Global().Get("Promise").New(handler)
+	}))
+
+	// __wasm_findStringRefs(Uint8Array, options: object) -> Promise<string>
+	// Find the ldc/ldc_w instructions that load a string literal.
+	// Returns JSON [{method: "name:descriptor", pc}].
+	// options: { literal: string }
+	js.Global().Set("__wasm_findStringRefs", js.FuncOf(func(_ js.Value, args []js.Value) any {
+		if len(args) != 2 {
+			return archive.JSError("findStringRefs requires 2 arguments (Uint8Array, options)")
+		}
+
+		handler := js.FuncOf(func(_ js.Value, promise []js.Value) any {
+			resolve := promise[0]
+			reject := promise[1]
+
+			go func() {
+				jsArr := args[0]
+				literal, err := archive.RequiredOptionString(args[1], "literal")
+				if err != nil {
+					reject.Invoke(js.Global().Get("Error").New("Invalid options: " + err.Error()))
+					return
+				}
+
+				data := make([]byte, jsArr.Get("length").Int())
+				js.CopyBytesToGo(data, jsArr)
+
+				result, err := classStringRefs(data, literal)
 				if err != nil {
 					reject.Invoke(js.Global().Get("Error").New("Failed to parse class file: " + err.Error()))
 					return
@@ -765,6 +2098,117 @@ func main() {
 		return js.Global().Get("Promise").New(handler)
 	}))
 
+	// __wasm_verifyClass(Uint8Array, options?: object) -> Promise<string>
+	// Check that every branch and switch target starts an instruction.
+	// Returns JSON {valid, methods: [{key, badTargets, branchTargets}]}
+	// listing only the methods with bad targets.
+	// options: as parseClass; methodFilter limits the methods checked.
+	js.Global().Set("__wasm_verifyClass", js.FuncOf(func(_ js.Value, args []js.Value) any {
+		if len(args) < 1 || len(args) > 2 {
+			return archive.JSError("verifyClass requires 1 or 2 arguments (Uint8Array, options?)")
+		}
+
+		handler := js.FuncOf(func(_ js.Value, promise []js.Value) any {
+			resolve := promise[0]
+			reject := promise[1]
+
+			go func() {
+				jsArr := args[0]
+				var options js.Value
+				if len(args) == 2 {
+					options = args[1]
+				}
+				opts, err := classOptionsFromJS(options)
+				if err != nil {
+					reject.Invoke(js.Global().Get("Error").New("Invalid options: " + err.Error()))
+					return
+				}
+				data := make([]byte, jsArr.Get("length").Int())
+				js.CopyBytesToGo(data, jsArr)
+
+				result, err := verifyClass(data, opts)
+				if err != nil {
+					reject.Invoke(js.Global().Get("Error").New("Failed to parse class file: " + err.Error()))
+					return
+				}
+
+				jsonBytes, err := json.Marshal(result)
+				if err != nil {
+					reject.Invoke(js.Global().Get("Error").New("Failed to serialize result: " + err.Error()))
+					return
+				}
+
+				resolve.Invoke(string(jsonBytes))
+			}()
+
+			return nil
+		})
+
+		return js.Global().Get("Promise").New(handler)
+	}))
+
+	// __wasm_jarTypeHierarchy(Uint8Array, options?: object) -> Promise<string>
+	// Parse every .class in a jar and link them by superclass and
+	// interfaces. Returns JSON TypeHierarchy
+	// {classes, subtypes, external, errors?}.
+	// options: the path filters of parseZip (pathRegex, include, exclude)
+	js.Global().Set("__wasm_jarTypeHierarchy", js.FuncOf(func(_ js.Value, args []js.Value) any {
+		if len(args) < 1 || len(args) > 2 {
+			return archive.JSError("jarTypeHierarchy requires 1 or 2 arguments (Uint8Array, options?)")
+		}
+
+		handler := js.FuncOf(func(_ js.Value, promise []js.Value) any {
+			resolve := promise[0]
+			reject := promise[1]
+
+			go func() {
+				jsArr := args[0]
+				length := jsArr.Get("length").Int()
+				var options js.Value
+				if len(args) == 2 {
+					options = args[1]
+				}
+				opts, err := archive.OptionsFromJS(options)
+				if err != nil {
+					reject.Invoke(js.Global().Get("Error").New("Invalid options: " + err.Error()))
+					return
+				}
+
+				if length > archive.MaxTotalSize {
+					reject.Invoke(js.Global().Get("Error").New("Archive too large (>100MB)"))
+					return
+				}
+
+				data := make([]byte, length)
+				js.CopyBytesToGo(data, jsArr)
+
+				result, err := jarTypeHierarchy(data, opts)
+				if err != nil {
+					reject.Invoke(js.Global().Get("Error").New("Failed to read jar: " + err.Error()))
+					return
+				}
+
+				jsonBytes, err := json.Marshal(result)
+				if err != nil {
+					reject.Invoke(js.Global().Get("Error").New("Failed to serialize result: " + err.Error()))
+					return
+				}
+
+				resolve.Invoke(string(jsonBytes))
+			}()
+
+			return nil
+		})
+
+		return js.Global().Get("Promise").New(handler)
+	}))
+
+	// __wasm_detectArchive(Uint8Array, options?: object) -> Promise<string>
+	// Sniff magic bytes to tell zip, tgz and tar apart.
+	// Returns JSON {type: "zip"|"tgz"|"tar"|"unknown"}.
+	// options is reserved and ignored.
+	js.Global().Set("__wasm_detectArchive", js.FuncOf(archive.DetectArchive))
+
 	// Block forever — WASM instance must stay alive to serve calls.
 	select {}
 }
@@ -0,0 +1,222 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"strconv"
+	"strings"
+	"syscall/js"
+	"testing"
+
+	parser "github.com/wreulicke/classfile-parser"
+	"pkg-inspector/wasm/internal/archive"
+)
+
+// testClass assembles a minimal class file: class T extends Object with
+// the given access flags, one int field f and one method m()V, each
+// carrying the attributes named in fieldAttrs and methodAttrs (two
+// bytes of data apiece), then a bare ()V method for each extraMethods
+// name.
+func testClass(access uint16, fieldAttrs, methodAttrs []string, extraMethods ...string) []byte {
+	u2 := binary.BigEndian.AppendUint16
+	var constants [][]byte
+	add := func(c []byte) uint16 {
+		constants = append(constants, c)
+		return uint16(len(constants))
+	}
+	utf8 := func(s string) uint16 { return add(append(u2([]byte{1}, uint16(len(s))), s...)) }
+	class := func(name string) uint16 { return add(u2([]byte{7}, utf8(name))) }
+	member := func(b []byte, access uint16, name, desc string, attrs []string) []byte {
+		b = u2(u2(u2(b, access), utf8(name)), utf8(desc))
+		b = u2(b, uint16(len(attrs)))
+		for _, a := range attrs {
+			b = binary.BigEndian.AppendUint32(u2(b, utf8(a)), 2)
+			b = append(b, 0, 0)
+		}
+		return b
+	}
+
+	body := u2(nil, access)
+	body = u2(body, class("T"))
+	body = u2(body, class("java/lang/Object"))
+	body = u2(body, 0) // interfaces
+	body = member(u2(body, 1), 0, "f", "I", fieldAttrs)
+	body = u2(body, uint16(1+len(extraMethods)))
+	body = member(body, 0, "m", "()V", methodAttrs)
+	for _, name := range extraMethods {
+		body = member(body, 0, name, "()V", nil)
+	}
+	body = u2(body, 0) // class attributes
+
+	b := []byte{0xca, 0xfe, 0xba, 0xbe, 0, 0, 0, 52}
+	b = u2(b, uint16(len(constants)+1))
+	for _, c := range constants {
+		b = append(b, c...)
+	}
+	return append(b, body...)
+}
+
+// Field and method attributes the library cannot decode are warned about,
+// and rejected under strict, like class attributes.
+func TestUnknownMemberAttributes(t *testing.T) {
+	data := testClass(0x21, []string{"ConstantValue", "FieldExtra"}, []string{"MethodExtra"})
+
+	info, err := parseClassFile(data, classOptions{Verbose: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"field f: attribute FieldExtra not decoded", "method m()V: attribute MethodExtra not decoded"}
+	for _, w := range want {
+		if !strings.Contains(strings.Join(info.Warnings, "\n"), w) {
+			t.Errorf("warnings %q lack %q", info.Warnings, w)
+		}
+	}
+	if len(info.UnknownAttributes) != 0 {
+		t.Errorf("UnknownAttributes = %q, want only class attributes", info.UnknownAttributes)
+	}
+
+	_, err = parseClassFile(data, classOptions{Strict: true})
+	if err == nil || err.Error() != "strict: "+want[0] {
+		t.Errorf("strict parse: err = %v, want %q", err, "strict: "+want[0])
+	}
+}
+
+// Only <init> is a constructor and only <clinit> a static initializer.
+func TestInitializerFlags(t *testing.T) {
+	info, err := parseClassFile(testClass(0x21, nil, nil, "<init>", "<clinit>"), classOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string][2]bool{"m": {false, false}, "<init>": {true, false}, "<clinit>": {false, true}}
+	if len(info.Methods) != len(want) {
+		t.Fatalf("%d methods, want %d", len(info.Methods), len(want))
+	}
+	for _, m := range info.Methods {
+		if got := [2]bool{m.IsConstructor, m.IsStaticInitializer}; got != want[m.Name] {
+			t.Errorf("%s: isConstructor, isStaticInitializer = %v, want %v", m.Name, got, want[m.Name])
+		}
+	}
+}
+
+// methodListing picks the method by name, then descriptor.
+func TestMethodListing(t *testing.T) {
+	data := testClass(0x21, nil, nil, "<init>")
+	if got, err := methodListing(data, "<init>", "()V"); err != nil || got != "T();\n" {
+		t.Errorf("methodListing(<init>) = %q, %v", got, err)
+	}
+	if _, err := methodListing(data, "m", "(I)V"); err == nil || err.Error() != "no method m:(I)V in T" {
+		t.Errorf("methodListing(m:(I)V) err = %v", err)
+	}
+}
+
+// Class kinds as javac flags them.
+func TestClassAccessFlags(t *testing.T) {
+	for _, tc := range []struct {
+		flags     uint16
+		showSuper bool
+		want      string
+	}{
+		{0x0021, false, "public class"},                         // public class C
+		{0x0021, true, "public super class"},                    // ... with showSuperFlag
+		{0x0601, false, "public abstract interface"},            // public interface I
+		{0x2601, false, "public abstract annotation interface"}, // public @interface A
+		{0x4031, false, "public final enum"},                    // public enum E
+		{0x4421, true, "public super abstract enum"},            // enum with constant bodies
+		{0x0020, false, "class"},                                // package-private class
+		{0x8000, false, "module"},                               // module-info
+	} {
+		got := strings.Join(classAccessFlags(parser.AccessFlags(tc.flags), tc.showSuper), " ")
+		if got != tc.want {
+			t.Errorf("classAccessFlags(%#04x, %v) = %q, want %q", tc.flags, tc.showSuper, got, tc.want)
+		}
+	}
+	info, err := parseClassFile(testClass(0x2601, nil, nil), classOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(info.AccessFlags, " "); got != "public abstract annotation interface" {
+		t.Errorf("parsed annotation type has accessFlags %q", got)
+	}
+}
+
+func TestDecodeModifiedUtf8(t *testing.T) {
+	for _, tc := range []struct {
+		in   []byte
+		want string
+	}{
+		{[]byte("java/lang/Object"), "java/lang/Object"},
+		{[]byte{'a', 0xc0, 0x80, 'b'}, "a\x00b"},                   // embedded NUL
+		{[]byte{0xc3, 0xa9, 0xe2, 0x82, 0xac}, "é€"},               // 2- and 3-byte forms
+		{[]byte{0xed, 0xa0, 0xbd, 0xed, 0xb8, 0x80}, "\U0001F600"}, // surrogate pair
+		{[]byte{'<', 0xed, 0xa0, 0xbd, 0xed, 0xb8, 0x80, 0xc0, 0x80, '>'}, "<\U0001F600\x00>"},
+		{[]byte{0xf0, 0x9f, 0x98, 0x80}, "����"}, // standard 4-byte UTF-8 is not valid
+		{[]byte{0xed, 0xa0, 0xbd, 'x'}, "�x"},    // lone high surrogate
+		{[]byte{0xed, 0xb8, 0x80}, "�"},          // lone low surrogate
+		{[]byte{'a', 0x00, 0xc3}, "a��"},         // raw NUL, truncated sequence
+	} {
+		if got := decodeModifiedUtf8(tc.in); got != tc.want {
+			t.Errorf("decodeModifiedUtf8(% x) = %+q, want %+q", tc.in, got, tc.want)
+		}
+	}
+}
+
+func TestReadClassAtMost(t *testing.T) {
+	for _, n := range []int{0, 99, 100} {
+		data, err := readClassAtMost(bytes.NewReader(make([]byte, n)), 100)
+		if err != nil || len(data) != n {
+			t.Errorf("%d bytes under a limit of 100: %d bytes, %v", n, len(data), err)
+		}
+	}
+	_, err := readClassAtMost(bytes.NewReader(make([]byte, 101)), 100)
+	if err == nil || err.Error() != "decompressed class exceeds 100 bytes" {
+		t.Errorf("101 bytes under a limit of 100: err = %v", err)
+	}
+}
+
+// A .class.gz inflating past archive.MaxTotalSize is rejected, not cut.
+func TestGunzipClassTooLarge(t *testing.T) {
+	var buf bytes.Buffer
+	zw, _ := gzip.NewWriterLevel(&buf, gzip.BestSpeed)
+	zw.Write(testClass(0x21, nil, nil))
+	zw.Write(make([]byte, archive.MaxTotalSize))
+	zw.Close()
+
+	_, err := gunzipClass(buf.Bytes())
+	if want := "decompressed class exceeds " + strconv.Itoa(archive.MaxTotalSize) + " bytes"; err == nil || err.Error() != want {
+		t.Errorf("err = %v, want %q", err, want)
+	}
+
+	buf.Reset()
+	zw = gzip.NewWriter(&buf)
+	zw.Write(testClass(0x21, nil, nil))
+	zw.Close()
+	if data, err := gunzipClass(buf.Bytes()); err != nil || !bytes.Equal(data, testClass(0x21, nil, nil)) {
+		t.Errorf("small class: %d bytes, %v", len(data), err)
+	}
+}
+
+// A throwing progress callback stops parseClassBatch with an error
+// instead of panicking.
+func TestParseClassBatchProgress(t *testing.T) {
+	classes := [][]byte{testClass(0x21, nil, nil), {0xca, 0xfe}, testClass(0x21, nil, nil)}
+
+	var calls []int
+	progress := js.FuncOf(func(_ js.Value, args []js.Value) any {
+		calls = append(calls, args[0].Int())
+		return nil
+	})
+	defer progress.Release()
+	results, err := parseClassBatch(classes, classOptions{}, 2, progress.Value)
+	if err != nil || len(results) != 3 || results[1].Error == "" || results[2].Class == nil {
+		t.Errorf("parseClassBatch = %+v, %v", results, err)
+	}
+	if len(calls) != 2 || calls[0] != 2 || calls[1] != 3 {
+		t.Errorf("progress calls %v, want [2 3]", calls)
+	}
+
+	throws := js.Global().Get("Function").New("throw new Error('stop')")
+	if _, err := parseClassBatch(classes, classOptions{}, 1, throws); err == nil || err.Error() != "progress threw: JavaScript error: stop" {
+		t.Errorf("throwing progress: err = %v", err)
+	}
+}
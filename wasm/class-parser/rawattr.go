@@ -0,0 +1,360 @@
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	parser "github.com/wreulicke/classfile-parser"
+)
+
+// ---------------------------------------------------------------------------
+// Raw attributes
+// ---------------------------------------------------------------------------
+
+// The classfile library rejects classes carrying attributes it does not
+// know (ScalaSig, vendor extensions, ...) and stops reading a Code
+// attribute after its exception table, so AttributeCode.Attributes
+// (LineNumberTable, LocalVariableTable, StackMapTable, ...) is always
+// empty. The helpers here walk the class file bytes directly to work
+// around both.
+
+// libraryAttributes are the attribute names the classfile library can
+// decode. It rejects the whole class on any other name, so
+// stripUnknownAttributes removes the rest before parsing.
+var libraryAttributes = map[string]bool{
+	"ConstantValue":                        true,
+	"Code":                                 true,
+	"StackMapTable":                        true,
+	"Exceptions":                           true,
+	"InnerClasses":                         true,
+	"EnclosingMethod":                      true,
+	"Synthetic":                            true,
+	"Signature":                            true,
+	"SourceFile":                           true,
+	"SourceDebugExtension":                 true,
+	"LineNumberTable":                      true,
+	"LocalVariableTable":                   true,
+	"LocalVariableTypeTable":               true,
+	"Deprecated":                           true,
+	"RuntimeVisibleAnnotations":            true,
+	"RuntimeInvisibleAnnotations":          true,
+	"RuntimeVisibleParameterAnnotations":   true,
+	"RuntimeInvisibleParameterAnnotations": true,
+	"RuntimeVisibleTypeAnnotations":        true,
+	"RuntimeInvisibleTypeAnnotations":      true,
+	"AnnotationDefault":                    true,
+	"BootstrapMethods":                     true,
+	"MethodParameters":                     true,
+	"Module":                               true,
+	"ModulePackages":                       true,
+	"ModuleMainClass":                      true,
+	"NestHost":                             true,
+	"NestMembers":                          true,
+	"Record":                               true,
+	"PermittedSubclasses":                  true,
+}
+
+// rawAttribute is an attribute as stored in the class file: its name and
+// undecoded info bytes. Member is set on the field and method attributes
+// stripUnknownAttributes removes: "field name" or "method name(desc)ret".
+type rawAttribute struct {
+	Name   string
+	Data   []byte
+	Member string
+}
+
+// knownCodeAttributes are the Code sub-attributes JVMS defines. Anything
+// else is reported in MethodInfo.UnknownCodeAttributes.
+var knownCodeAttributes = map[string]bool{
+	"LineNumberTable":                 true,
+	"LocalVariableTable":              true,
+	"LocalVariableTypeTable":          true,
+	"StackMapTable":                   true,
+	"RuntimeVisibleTypeAnnotations":   true,
+	"RuntimeInvisibleTypeAnnotations": true,
+}
+
+var errShortClass = errors.New("unexpected end of class file")
+
+// classReader is a bounds-checked big-endian cursor over class file bytes.
+type classReader struct {
+	b   []byte
+	pos int
+	err error
+}
+
+func (r *classReader) skip(n int) {
+	if r.err != nil {
+		return
+	}
+	if n < 0 || r.pos+n > len(r.b) {
+		r.err = errShortClass
+		r.pos = len(r.b)
+		return
+	}
+	r.pos += n
+}
+
+func (r *classReader) bytes(n int) []byte {
+	start := r.pos
+	r.skip(n)
+	if r.err != nil {
+		return nil
+	}
+	return r.b[start:r.pos]
+}
+
+func (r *classReader) u1() int {
+	if b := r.bytes(1); b != nil {
+		return int(b[0])
+	}
+	return 0
+}
+
+func (r *classReader) u2() int {
+	if b := r.bytes(2); b != nil {
+		return int(binary.BigEndian.Uint16(b))
+	}
+	return 0
+}
+
+func (r *classReader) u4() int {
+	if b := r.bytes(4); b != nil {
+		return int(binary.BigEndian.Uint32(b))
+	}
+	return 0
+}
+
+// attributes reads an attributes_count-prefixed attribute table.
+func (r *classReader) attributes(cp *parser.ConstantPool) []rawAttribute {
+	n := r.u2()
+	var attrs []rawAttribute
+	for i := 0; i < n && r.err == nil; i++ {
+		name, _ := lookupUtf8(cp, uint16(r.u2()))
+		data := r.bytes(r.u4())
+		attrs = append(attrs, rawAttribute{Name: name, Data: data})
+	}
+	return attrs
+}
+
+// skipMembers skips a fields_count- or methods_count-prefixed table.
+func (r *classReader) skipMembers(cp *parser.ConstantPool) {
+	n := r.u2()
+	for i := 0; i < n && r.err == nil; i++ {
+		r.skip(6)
+		r.attributes(cp)
+	}
+}
+
+// skipConstantPool skips the constant pool, which starts at r.pos, and
+// returns its Utf8 entries by index.
+func (r *classReader) skipConstantPool() map[int]string {
+	utf8s := make(map[int]string)
+	count := r.u2()
+	for i := 1; i < count && r.err == nil; i++ {
+		switch tag := r.u1(); tag {
+		case 1: // Utf8
+			utf8s[i] = decodeModifiedUtf8(r.bytes(r.u2()))
+		case 7, 8, 16, 19, 20: // Class, String, MethodType, Module, Package
+			r.skip(2)
+		case 15: // MethodHandle
+			r.skip(3)
+		case 3, 4, 9, 10, 11, 12, 17, 18: // Integer, Float, refs, NameAndType, (Invoke)Dynamic
+			r.skip(4)
+		case 5, 6: // Long, Double take two slots
+			r.skip(8)
+			i++
+		default:
+			r.err = fmt.Errorf("bad constant pool tag %d", tag)
+		}
+	}
+	return utf8s
+}
+
+// stripUnknownAttributes returns data with every attribute the library
+// cannot decode removed from the class, field and method attribute
+// tables, so that parsing succeeds. The removed attributes are returned,
+// those of fields and methods first, tagged with their Member. data
+// itself is returned when there is nothing to strip.
+func stripUnknownAttributes(data []byte) ([]byte, []rawAttribute, error) {
+	r := &classReader{b: data}
+	r.skip(8) // magic, minor, major
+	utf8s := r.skipConstantPool()
+	r.skip(6) // access_flags, this_class, super_class
+	r.skip(2 * r.u2())
+
+	var out []byte
+	copied := 0 // data[:copied] is already in out
+	stripped := false
+
+	// table filters the attribute table at r.pos, returning what it drops.
+	table := func() []rawAttribute {
+		countPos := r.pos
+		n := r.u2()
+		var kept []byte
+		var keptCount int
+		var dropped []rawAttribute
+		for i := 0; i < n && r.err == nil; i++ {
+			start := r.pos
+			name := utf8s[r.u2()]
+			info := r.bytes(r.u4())
+			if libraryAttributes[name] {
+				kept = append(kept, data[start:r.pos]...)
+				keptCount++
+			} else {
+				dropped = append(dropped, rawAttribute{Name: name, Data: info})
+			}
+		}
+		if len(dropped) > 0 && r.err == nil {
+			out = append(out, data[copied:countPos]...)
+			out = binary.BigEndian.AppendUint16(out, uint16(keptCount))
+			out = append(out, kept...)
+			copied = r.pos
+			stripped = true
+		}
+		return dropped
+	}
+	var unknown []rawAttribute
+	members := func(kind string) {
+		n := r.u2()
+		for i := 0; i < n && r.err == nil; i++ {
+			r.skip(2) // access_flags
+			member := kind + " " + utf8s[r.u2()]
+			if desc := utf8s[r.u2()]; kind == "method" {
+				member += desc
+			}
+			for _, a := range table() {
+				a.Member = member
+				unknown = append(unknown, a)
+			}
+		}
+	}
+
+	members("field")
+	members("method")
+	unknown = append(unknown, table()...)
+	if r.err != nil {
+		return nil, nil, r.err
+	}
+	if !stripped {
+		return data, nil, nil
+	}
+	return append(out, data[copied:]...), unknown, nil
+}
+
+// scanCodeAttributes returns the attributes nested in each method's Code
+// attribute, indexed like cf.Methods (nil for methods without Code).
+func scanCodeAttributes(data []byte, cp *parser.ConstantPool) ([][]rawAttribute, error) {
+	r := &classReader{b: data}
+	r.skip(8) // magic, minor, major
+	r.skipConstantPool()
+	r.skip(6) // access_flags, this_class, super_class
+	r.skip(2 * r.u2())
+	r.skipMembers(cp)
+
+	methods := make([][]rawAttribute, r.u2())
+	for i := range methods {
+		if r.err != nil {
+			break
+		}
+		r.skip(6)
+		for _, a := range r.attributes(cp) {
+			if a.Name != "Code" {
+				continue
+			}
+			cr := &classReader{b: a.Data}
+			cr.skip(4) // max_stack, max_locals
+			cr.skip(cr.u4())
+			cr.skip(8 * cr.u2())
+			methods[i] = cr.attributes(cp)
+			if cr.err != nil {
+				return nil, cr.err
+			}
+		}
+	}
+	return methods, r.err
+}
+
+// unknownCodeAttributes names the attributes in attrs that are not in
+// knownCodeAttributes, in class file order.
+func unknownCodeAttributes(attrs []rawAttribute) []string {
+	var names []string
+	for _, a := range attrs {
+		if !knownCodeAttributes[a.Name] {
+			names = append(names, a.Name)
+		}
+	}
+	return names
+}
+
+// localVar is one LocalVariableTable entry: Name lives in Slot for PCs
+// in [Start, End).
+type localVar struct {
+	Start, End int
+	Slot       int
+	Name       string
+}
+
+// localVariables decodes the LocalVariableTable among a Code attribute's
+// nested attributes. It returns nil if there is none or it is malformed.
+func localVariables(attrs []rawAttribute, cp *parser.ConstantPool) []localVar {
+	var vars []localVar
+	for _, a := range attrs {
+		if a.Name != "LocalVariableTable" {
+			continue
+		}
+		r := &classReader{b: a.Data}
+		n := r.u2()
+		for i := 0; i < n && r.err == nil; i++ {
+			start, length := r.u2(), r.u2()
+			name, _ := lookupUtf8(cp, uint16(r.u2()))
+			r.skip(2) // descriptor_index
+			slot := r.u2()
+			vars = append(vars, localVar{Start: start, End: start + length, Slot: slot, Name: name})
+		}
+		if r.err != nil {
+			return nil
+		}
+	}
+	return vars
+}
+
+// localName returns the name of the variable in slot at pc, if any.
+// Slots are reused, so the PC range picks between candidates.
+func localName(vars []localVar, slot, pc int) string {
+	for _, v := range vars {
+		if v.Slot == slot && v.Start <= pc && pc < v.End {
+			return v.Name
+		}
+	}
+	return ""
+}
+
+// sourceLineSpan returns the lowest and highest line numbers in the
+// LineNumberTables of all methods' Code attributes, or nil if no method
+// has one. Malformed tables are ignored.
+func sourceLineSpan(codeAttrs [][]rawAttribute) *[2]int {
+	var span *[2]int
+	for _, attrs := range codeAttrs {
+		for _, a := range attrs {
+			if a.Name != "LineNumberTable" {
+				continue
+			}
+			r := &classReader{b: a.Data}
+			n := r.u2()
+			for i := 0; i < n; i++ {
+				r.skip(2) // start_pc
+				line := r.u2()
+				if r.err != nil {
+					break
+				}
+				if span == nil {
+					span = &[2]int{line, line}
+				}
+				span[0], span[1] = min(span[0], line), max(span[1], line)
+			}
+		}
+	}
+	return span
+}
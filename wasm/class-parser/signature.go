@@ -0,0 +1,330 @@
+package main
+
+import (
+	"strings"
+
+	parser "github.com/wreulicke/classfile-parser"
+)
+
+// ---------------------------------------------------------------------------
+// Generic signatures (JVMS 4.7.9.1) -> Java source syntax
+// ---------------------------------------------------------------------------
+
+// sigParser walks a generic signature. Malformed input sets bad rather
+// than panicking; callers then fall back to the plain descriptor.
+type sigParser struct {
+	s   string
+	pos int
+	bad bool
+}
+
+func (p *sigParser) peek() byte {
+	if p.pos >= len(p.s) {
+		p.bad = true
+		return 0
+	}
+	return p.s[p.pos]
+}
+
+func (p *sigParser) expect(c byte) {
+	if p.peek() != c {
+		p.bad = true
+		return
+	}
+	p.pos++
+}
+
+// ident reads up to (not including) the first of the stop bytes.
+func (p *sigParser) ident(stops string) string {
+	start := p.pos
+	for p.pos < len(p.s) && strings.IndexByte(stops, p.s[p.pos]) < 0 {
+		p.pos++
+	}
+	return p.s[start:p.pos]
+}
+
+// typeParams parses an optional <T:bound...> list.
+func (p *sigParser) typeParams() []string {
+	if p.pos >= len(p.s) || p.s[p.pos] != '<' {
+		return nil
+	}
+	p.pos++
+	var params []string
+	for !p.bad && p.peek() != '>' {
+		name := p.ident(":")
+		var bounds []string
+		for !p.bad && p.peek() == ':' {
+			p.pos++
+			if p.peek() == ':' {
+				continue // empty class bound, interface bounds follow
+			}
+			if b := p.typeSig(); b != "Object" {
+				bounds = append(bounds, b)
+			}
+		}
+		if len(bounds) > 0 {
+			name += " extends " + strings.Join(bounds, " & ")
+		}
+		params = append(params, name)
+	}
+	p.expect('>')
+	return params
+}
+
+// typeSig parses a single JavaTypeSignature (or V for void).
+func (p *sigParser) typeSig() string {
+	switch c := p.peek(); c {
+	case 'L':
+		p.pos++
+		return p.classType()
+	case 'T':
+		p.pos++
+		name := p.ident(";")
+		p.expect(';')
+		return name
+	case '[':
+		p.pos++
+		return p.typeSig() + "[]"
+	case 'B', 'C', 'D', 'F', 'I', 'J', 'S', 'Z', 'V':
+		p.pos++
+		pos := 0
+		return parseDescriptorType(string(c), &pos)
+	}
+	p.bad = true
+	return "?"
+}
+
+// classType parses the rest of a ClassTypeSignature after the 'L'.
+func (p *sigParser) classType() string {
+	var sb strings.Builder
+	sb.WriteString(sourceTypeName(strings.ReplaceAll(p.ident("<.;"), "/", ".")))
+	for !p.bad {
+		switch p.peek() {
+		case '<':
+			sb.WriteString(p.typeArgs())
+		case '.':
+			p.pos++
+			sb.WriteString("." + p.ident("<.;"))
+		case ';':
+			p.pos++
+			return sb.String()
+		default:
+			p.bad = true
+		}
+	}
+	return sb.String()
+}
+
+// typeArgs parses <...> type arguments, including wildcards.
+func (p *sigParser) typeArgs() string {
+	p.expect('<')
+	var args []string
+	for !p.bad && p.peek() != '>' {
+		switch p.peek() {
+		case '*':
+			p.pos++
+			args = append(args, "?")
+		case '+':
+			p.pos++
+			args = append(args, "? extends "+p.typeSig())
+		case '-':
+			p.pos++
+			args = append(args, "? super "+p.typeSig())
+		default:
+			args = append(args, p.typeSig())
+		}
+	}
+	p.expect('>')
+	return "<" + strings.Join(args, ", ") + ">"
+}
+
+// methodSignature is a method's generic signature in source syntax.
+type methodSignature struct {
+	TypeParams []string
+	Params     []string
+	Return     string
+	Throws     []string
+}
+
+// parseMethodSignature parses a method Signature attribute value.
+func parseMethodSignature(sig string) (methodSignature, bool) {
+	p := &sigParser{s: sig}
+	var ms methodSignature
+	ms.TypeParams = p.typeParams()
+	p.expect('(')
+	for !p.bad && p.peek() != ')' {
+		ms.Params = append(ms.Params, p.typeSig())
+	}
+	p.expect(')')
+	ms.Return = p.typeSig()
+	for !p.bad && p.pos < len(p.s) {
+		p.expect('^')
+		ms.Throws = append(ms.Throws, p.typeSig())
+	}
+	return ms, !p.bad
+}
+
+// parseFieldSignature parses a field Signature attribute value.
+func parseFieldSignature(sig string) (string, bool) {
+	p := &sigParser{s: sig}
+	t := p.typeSig()
+	return t, !p.bad && p.pos == len(p.s)
+}
+
+// sourceTypeName drops the java.lang. prefix from top-level java.lang
+// types, as source code would.
+func sourceTypeName(t string) string {
+	if rest, ok := strings.CutPrefix(t, "java.lang."); ok && !strings.Contains(rest, ".") {
+		return rest
+	}
+	return t
+}
+
+// ---------------------------------------------------------------------------
+// Source-style declarations
+// ---------------------------------------------------------------------------
+
+// methodDeclaration renders m like a Java source declaration, e.g.
+// "public static <T> List<T> merge(int count, String name) throws java.io.IOException".
+// The generic signature is preferred over the descriptor; paramNames
+// (from MethodParameters, may be nil) are added when they line up.
+func methodDeclaration(owner string, m *MethodInfo, flags parser.AccessFlags, paramNames []string) string {
+	if m.IsStaticInitializer {
+		return "static {}"
+	}
+
+	params := make([]string, len(m.ParamTypes))
+	for i, t := range m.ParamTypes {
+		params[i] = sourceTypeName(t)
+	}
+	ret := sourceTypeName(m.ReturnType)
+	throws := make([]string, len(m.Exceptions))
+	for i, t := range m.Exceptions {
+		throws[i] = sourceTypeName(t)
+	}
+	var typeParams []string
+	if m.Signature != "" {
+		if ms, ok := parseMethodSignature(m.Signature); ok {
+			typeParams = ms.TypeParams
+			ret = ms.Return
+			// Signatures may omit synthetic parameters (outer instance,
+			// enum name/ordinal), so only trust them when counts agree.
+			if len(ms.Params) == len(params) {
+				params = ms.Params
+			}
+			if len(ms.Throws) > 0 {
+				throws = ms.Throws
+			}
+		}
+	}
+
+	var sb strings.Builder
+	for _, mod := range methodModifiers(flags, m.IsDefault) {
+		sb.WriteString(mod + " ")
+	}
+	if len(typeParams) > 0 {
+		sb.WriteString("<" + strings.Join(typeParams, ", ") + "> ")
+	}
+	if m.IsConstructor {
+		simple := owner[strings.LastIndexAny(owner, ".$")+1:]
+		sb.WriteString(simple)
+	} else {
+		sb.WriteString(ret + " " + m.Name)
+	}
+
+	sb.WriteByte('(')
+	for i, t := range params {
+		if i > 0 {
+			sb.WriteString(", ")
+		}
+		if i == len(params)-1 && flags.Is(parser.ACC_VARARGS) && strings.HasSuffix(t, "[]") {
+			t = strings.TrimSuffix(t, "[]") + "..."
+		}
+		sb.WriteString(t)
+		if len(paramNames) == len(params) && paramNames[i] != "" {
+			sb.WriteString(" " + paramNames[i])
+		}
+	}
+	sb.WriteByte(')')
+
+	if len(throws) > 0 {
+		sb.WriteString(" throws " + strings.Join(throws, ", "))
+	}
+	return sb.String()
+}
+
+// fieldDeclaration renders f like a Java source declaration, e.g.
+// "private final int count".
+func fieldDeclaration(f *FieldInfo, flags parser.AccessFlags) string {
+	t := sourceTypeName(f.TypeName)
+	if f.Signature != "" {
+		if st, ok := parseFieldSignature(f.Signature); ok {
+			t = st
+		}
+	}
+
+	var sb strings.Builder
+	for _, mod := range fieldModifiers(flags) {
+		sb.WriteString(mod + " ")
+	}
+	sb.WriteString(t + " " + f.Name)
+	return sb.String()
+}
+
+// methodModifiers lists the source-level method modifiers in the
+// conventional order (bridge, varargs and synthetic are not modifiers).
+func methodModifiers(flags parser.AccessFlags, isDefault bool) []string {
+	mods := accessModifiers(flags)
+	if flags.Is(parser.ACC_ABSTRACT) {
+		mods = append(mods, "abstract")
+	}
+	if isDefault {
+		mods = append(mods, "default")
+	}
+	if flags.Is(parser.ACC_STATIC) {
+		mods = append(mods, "static")
+	}
+	if flags.Is(parser.ACC_FINAL) {
+		mods = append(mods, "final")
+	}
+	if flags.Is(parser.ACC_SYNCHRONIZED) {
+		mods = append(mods, "synchronized")
+	}
+	if flags.Is(parser.ACC_NATIVE) {
+		mods = append(mods, "native")
+	}
+	if flags.Is(parser.ACC_STRICT) {
+		mods = append(mods, "strictfp")
+	}
+	return mods
+}
+
+// fieldModifiers lists the source-level field modifiers.
+func fieldModifiers(flags parser.AccessFlags) []string {
+	mods := accessModifiers(flags)
+	if flags.Is(parser.ACC_STATIC) {
+		mods = append(mods, "static")
+	}
+	if flags.Is(parser.ACC_FINAL) {
+		mods = append(mods, "final")
+	}
+	if flags.Is(parser.ACC_TRANSIENT) {
+		mods = append(mods, "transient")
+	}
+	if flags.Is(parser.ACC_VOLATILE) {
+		mods = append(mods, "volatile")
+	}
+	return mods
+}
+
+func accessModifiers(flags parser.AccessFlags) []string {
+	switch {
+	case flags.Is(parser.ACC_PUBLIC):
+		return []string{"public"}
+	case flags.Is(parser.ACC_PROTECTED):
+		return []string{"protected"}
+	case flags.Is(parser.ACC_PRIVATE):
+		return []string{"private"}
+	}
+	return nil
+}
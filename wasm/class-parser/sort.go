@@ -0,0 +1,66 @@
+package main
+
+import "sort"
+
+// ---------------------------------------------------------------------------
+// Member ordering (sortMembers option)
+// ---------------------------------------------------------------------------
+
+// Member orders accepted by the sortMembers option.
+const (
+	sortDeclared = "declared" // class file order
+	sortName     = "name"     // alphabetical, constructors first
+	sortAccess   = "access"   // public, protected, package, private
+)
+
+// accessRank orders raw access flags public, protected, package-private,
+// private.
+func accessRank(flags int) int {
+	switch {
+	case flags&0x0001 != 0: // ACC_PUBLIC
+		return 0
+	case flags&0x0004 != 0: // ACC_PROTECTED
+		return 1
+	case flags&0x0002 != 0: // ACC_PRIVATE
+		return 3
+	}
+	return 2
+}
+
+// methodRank puts constructors first and the static initializer next
+// when sorting by name.
+func methodRank(m *MethodInfo) int {
+	switch {
+	case m.IsConstructor:
+		return 0
+	case m.IsStaticInitializer:
+		return 1
+	}
+	return 2
+}
+
+// sortMembers reorders fields and methods in place. Sorts are stable, so
+// members that compare equal keep their declared order.
+func sortMembers(fields []FieldInfo, methods []MethodInfo, order string) {
+	switch order {
+	case sortName:
+		sort.SliceStable(fields, func(i, j int) bool { return fields[i].Name < fields[j].Name })
+		sort.SliceStable(methods, func(i, j int) bool {
+			a, b := &methods[i], &methods[j]
+			if ra, rb := methodRank(a), methodRank(b); ra != rb {
+				return ra < rb
+			}
+			if a.Name != b.Name {
+				return a.Name < b.Name
+			}
+			return a.Descriptor < b.Descriptor
+		})
+	case sortAccess:
+		sort.SliceStable(fields, func(i, j int) bool {
+			return accessRank(fields[i].RawAccessFlags) < accessRank(fields[j].RawAccessFlags)
+		})
+		sort.SliceStable(methods, func(i, j int) bool {
+			return accessRank(methods[i].RawAccessFlags) < accessRank(methods[j].RawAccessFlags)
+		})
+	}
+}
@@ -0,0 +1,343 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	parser "github.com/wreulicke/classfile-parser"
+)
+
+// ---------------------------------------------------------------------------
+// Operand stack simulation (simulateStack option)
+// ---------------------------------------------------------------------------
+
+// StackDepth is the operand stack depth, in slots (long and double take
+// two), before and after the instruction at PC. After is negative when
+// the instruction pops more than the stack holds.
+type StackDepth struct {
+	PC     int `json:"pc"`
+	Before int `json:"before"`
+	After  int `json:"after"`
+}
+
+// fixedEffect returns the slots popped and pushed by op when they do not
+// depend on its operands or the constant pool. ok is false for the
+// other opcodes (field access, invokes, ldc2_w, multianewarray, wide)
+// and unknown ones.
+func fixedEffect(op byte) (pop, push int, ok bool) {
+	switch {
+	case op == 0, op == 132, op == 167, op == 169, op == 177, op == 200: // nop, iinc, goto, ret, return, goto_w
+		return 0, 0, true
+	case op >= 1 && op <= 8, op == 11, op == 12, op == 13, op == 16, op == 17, op == 18, op == 19: // aconst_null, iconst, fconst, bipush, sipush, ldc, ldc_w
+		return 0, 1, true
+	case op == 9, op == 10, op == 14, op == 15: // lconst, dconst
+		return 0, 2, true
+	case op == 21, op == 23, op == 25, op >= 26 && op <= 29, op >= 34 && op <= 37, op >= 42 && op <= 45: // iload, fload, aload
+		return 0, 1, true
+	case op == 22, op == 24, op >= 30 && op <= 33, op >= 38 && op <= 41: // lload, dload
+		return 0, 2, true
+	case op == 47, op == 49: // laload, daload
+		return 2, 2, true
+	case op >= 46 && op <= 53: // iaload, faload, aaload, baload, caload, saload
+		return 2, 1, true
+	case op == 54, op == 56, op == 58, op >= 59 && op <= 62, op >= 67 && op <= 70, op >= 75 && op <= 78: // istore, fstore, astore
+		return 1, 0, true
+	case op == 55, op == 57, op >= 63 && op <= 66, op >= 71 && op <= 74: // lstore, dstore
+		return 2, 0, true
+	case op == 80, op == 82: // lastore, dastore
+		return 4, 0, true
+	case op >= 79 && op <= 86: // iastore, fastore, aastore, bastore, castore, sastore
+		return 3, 0, true
+	case op == 87: // pop
+		return 1, 0, true
+	case op == 88: // pop2
+		return 2, 0, true
+	case op == 89: // dup
+		return 1, 2, true
+	case op == 90: // dup_x1
+		return 2, 3, true
+	case op == 91: // dup_x2
+		return 3, 4, true
+	case op == 92: // dup2
+		return 2, 4, true
+	case op == 93: // dup2_x1
+		return 3, 5, true
+	case op == 94: // dup2_x2
+		return 4, 6, true
+	case op == 95: // swap
+		return 2, 2, true
+	case op >= 96 && op <= 115: // add, sub, mul, div, rem: i, l, f, d in turn
+		if op%2 == 1 {
+			return 4, 2, true
+		}
+		return 2, 1, true
+	case op == 116, op == 118: // ineg, fneg
+		return 1, 1, true
+	case op == 117, op == 119: // lneg, dneg
+		return 2, 2, true
+	case op == 121, op == 123, op == 125: // lshl, lshr, lushr
+		return 3, 2, true
+	case op == 127, op == 129, op == 131: // land, lor, lxor
+		return 4, 2, true
+	case op >= 120 && op <= 131: // ishl, ishr, iushr, iand, ior, ixor
+		return 2, 1, true
+	case op == 134, op == 139, op >= 145 && op <= 147: // i2f, f2i, i2b, i2c, i2s
+		return 1, 1, true
+	case op == 133, op == 135, op == 140, op == 141: // i2l, i2d, f2l, f2d
+		return 1, 2, true
+	case op == 136, op == 137, op == 142, op == 144: // l2i, l2f, d2i, d2f
+		return 2, 1, true
+	case op == 138, op == 143: // l2d, d2l
+		return 2, 2, true
+	case op == 148, op == 151, op == 152: // lcmp, dcmpl, dcmpg
+		return 4, 1, true
+	case op == 149, op == 150: // fcmpl, fcmpg
+		return 2, 1, true
+	case op >= 153 && op <= 158, op == 198, op == 199: // if<cond>, ifnull, ifnonnull
+		return 1, 0, true
+	case op >= 159 && op <= 166: // if_icmp<cond>, if_acmp<cond>
+		return 2, 0, true
+	case op == 168, op == 201, op == 187: // jsr, jsr_w, new
+		return 0, 1, true
+	case op == 170, op == 171, op == 172, op == 174, op == 176, op == 191, op == 194, op == 195: // switches, ireturn, freturn, areturn, athrow, monitorenter/exit
+		return 1, 0, true
+	case op == 173, op == 175: // lreturn, dreturn
+		return 2, 0, true
+	case op == 188, op == 189, op == 190, op == 192, op == 193: // newarray, anewarray, arraylength, checkcast, instanceof
+		return 1, 1, true
+	}
+	return 0, 0, false
+}
+
+// typeSlots is the stack size of the field descriptor starting desc:
+// 2 for long and double, 0 for void, otherwise 1.
+func typeSlots(desc string) int {
+	switch {
+	case desc == "":
+		return 1
+	case desc[0] == 'J', desc[0] == 'D':
+		return 2
+	case desc[0] == 'V':
+		return 0
+	}
+	return 1
+}
+
+// methodSlots returns the stack size of a method descriptor's parameters
+// and of its return value.
+func methodSlots(desc string) (args, ret int, ok bool) {
+	if len(desc) == 0 || desc[0] != '(' {
+		return 0, 0, false
+	}
+	i := 1
+	for i < len(desc) && desc[i] != ')' {
+		args += typeSlots(desc[i:])
+		for i < len(desc) && desc[i] == '[' {
+			i++
+		}
+		if i < len(desc) && desc[i] == 'L' {
+			for i < len(desc) && desc[i] != ';' {
+				i++
+			}
+		}
+		i++
+	}
+	if i >= len(desc) {
+		return 0, 0, false
+	}
+	return args, typeSlots(desc[i+1:]), true
+}
+
+// refDescriptor returns the descriptor of the field, method or
+// invokedynamic constant at index.
+func refDescriptor(cp *parser.ConstantPool, index uint16) (string, bool) {
+	if int(index) < 1 || int(index) > len(cp.Constants) {
+		return "", false
+	}
+	var nat uint16
+	switch v := cp.Constants[index-1].(type) {
+	case *parser.ConstantFieldref:
+		nat = v.NameAndTypeIndex
+	case *parser.ConstantMethodref:
+		nat = v.NameAndTypeIndex
+	case *parser.ConstantInterfaceMethodref:
+		nat = v.NameAndTypeIndex
+	case *parser.ConstantInvokeDynamic:
+		nat = v.NameAndTypeIndex
+	default:
+		return "", false
+	}
+	if int(nat) < 1 || int(nat) > len(cp.Constants) {
+		return "", false
+	}
+	n, ok := cp.Constants[nat-1].(*parser.ConstantNameAndType)
+	if !ok {
+		return "", false
+	}
+	return lookupUtf8(cp, n.DescriptorIndex)
+}
+
+// stackEffect returns the slots popped and pushed by the complete
+// instruction at pc. ok is false for unknown opcodes and unresolvable
+// member references.
+func stackEffect(code []byte, pc int, cp *parser.ConstantPool) (pop, push int, ok bool) {
+	op := code[pc]
+	if pop, push, ok := fixedEffect(op); ok {
+		return pop, push, true
+	}
+	switch op {
+	case 20: // ldc2_w
+		return 0, 2, true
+	case 178, 179, 180, 181: // getstatic, putstatic, getfield, putfield
+		desc, ok := refDescriptor(cp, binary.BigEndian.Uint16(code[pc+1:pc+3]))
+		if !ok {
+			return 0, 0, false
+		}
+		size := typeSlots(desc)
+		switch op {
+		case 178:
+			return 0, size, true
+		case 179:
+			return size, 0, true
+		case 180:
+			return 1, size, true
+		}
+		return 1 + size, 0, true
+	case 182, 183, 184, 185, 186: // invokevirtual, invokespecial, invokestatic, invokeinterface, invokedynamic
+		desc, ok := refDescriptor(cp, binary.BigEndian.Uint16(code[pc+1:pc+3]))
+		if !ok {
+			return 0, 0, false
+		}
+		args, ret, ok := methodSlots(desc)
+		if !ok {
+			return 0, 0, false
+		}
+		if op != 184 && op != 186 {
+			args++ // receiver
+		}
+		return args, ret, true
+	case 197: // multianewarray
+		return int(code[pc+3]), 1, true
+	case 196: // wide
+		switch wideOp := code[pc+1]; wideOp {
+		case 21, 23, 25, 22, 24, 54, 55, 56, 57, 58, 132, 169:
+			return fixedEffect(wideOp)
+		}
+	}
+	return 0, 0, false
+}
+
+// stackSuccessors returns the PCs control can reach after the complete
+// instruction at pc, other than exception handlers. jsr falls through
+// as well as jumping, as its subroutine returns there.
+func stackSuccessors(code []byte, pc int) []int {
+	next := pc + insnLength(code, pc)
+	switch op := code[pc]; op {
+	case 153, 154, 155, 156, 157, 158, 159, 160, 161, 162, 163, 164,
+		165, 166, 168, 198, 199: // if*, jsr, ifnull, ifnonnull
+		return []int{next, pc + int(int16(binary.BigEndian.Uint16(code[pc+1:pc+3])))}
+	case 167: // goto
+		return []int{pc + int(int16(binary.BigEndian.Uint16(code[pc+1:pc+3])))}
+	case 200: // goto_w
+		return []int{pc + int(int32(binary.BigEndian.Uint32(code[pc+1:pc+5])))}
+	case 201: // jsr_w
+		return []int{next, pc + int(int32(binary.BigEndian.Uint32(code[pc+1:pc+5])))}
+	case 170, 171: // tableswitch, lookupswitch
+		t, ok := decodeSwitch(code, pc)
+		if !ok {
+			return nil
+		}
+		return append([]int{t.Default}, t.Targets...)
+	case 169, 172, 173, 174, 175, 176, 177, 191: // ret, ?return, athrow
+		return nil
+	case 196: // wide ret
+		if code[pc+1] == 169 {
+			return nil
+		}
+	}
+	return []int{next}
+}
+
+// simulateStack follows control flow through code from pc 0 and each
+// exception handler in handlers (which start with the exception on the
+// stack), tracking the operand stack depth. It returns the depths at
+// every reachable instruction, ascending by PC, and issues describing
+// underflows, depths over maxStack, paths that reach an instruction
+// with different depths and instructions it cannot model. Branches to
+// PCs that do not start an instruction are left to verifyClass.
+func simulateStack(code []byte, cp *parser.ConstantPool, handlers []int, maxStack int) ([]StackDepth, []string) {
+	starts := make(map[int]bool)
+	for i := 0; i < len(code); i += insnLength(code, i) {
+		starts[i] = true
+	}
+
+	var issues []string
+	before := make(map[int]int)
+	mismatched := make(map[int]bool)
+	var work []int
+	reach := func(pc, depth int) {
+		if !starts[pc] {
+			return
+		}
+		if d, seen := before[pc]; seen {
+			if d != depth && !mismatched[pc] {
+				mismatched[pc] = true
+				issues = append(issues, fmt.Sprintf("pc %d: reached with stack depth %d and %d", pc, d, depth))
+			}
+			return
+		}
+		before[pc] = depth
+		work = append(work, pc)
+	}
+	reach(0, 0)
+	for _, h := range handlers {
+		reach(h, 1)
+	}
+
+	after := make(map[int]int)
+	for len(work) > 0 {
+		pc := work[len(work)-1]
+		work = work[:len(work)-1]
+		name := opcodeNames[code[pc]]
+		if pc+insnSize(code, pc) > len(code) {
+			issues = append(issues, fmt.Sprintf("pc %d: %s is truncated", pc, name))
+			continue
+		}
+		pop, push, ok := stackEffect(code, pc, cp)
+		if !ok {
+			if name == "" {
+				name = fmt.Sprintf("0x%02x", code[pc])
+			}
+			issues = append(issues, fmt.Sprintf("pc %d: cannot model the stack effect of %s", pc, name))
+			continue
+		}
+		d := before[pc] - pop + push
+		after[pc] = d
+		if before[pc] < pop {
+			issues = append(issues, fmt.Sprintf("pc %d: %s pops %d slots from a stack of %d", pc, name, pop, before[pc]))
+			continue
+		}
+		if d > maxStack {
+			issues = append(issues, fmt.Sprintf("pc %d: %s leaves stack depth %d, over maxStack %d", pc, name, d, maxStack))
+		}
+		for _, next := range stackSuccessors(code, pc) {
+			if code[pc] == 168 || code[pc] == 201 {
+				if next == pc+insnLength(code, pc) {
+					reach(next, before[pc]) // after the subroutine returns
+				} else {
+					reach(next, d) // return address pushed
+				}
+				continue
+			}
+			reach(next, d)
+		}
+	}
+
+	var depths []StackDepth
+	for i := 0; i < len(code); i += insnLength(code, i) {
+		if d, ok := after[i]; ok {
+			depths = append(depths, StackDepth{PC: i, Before: before[i], After: d})
+		}
+	}
+	return depths, issues
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"encoding/binary"
+	"sort"
+)
+
+// ---------------------------------------------------------------------------
+// Branch target verification
+// ---------------------------------------------------------------------------
+
+// VerifyResult is the __wasm_verifyClass result. Valid is false when any
+// method has a branch or switch target that is not the start of an
+// instruction, which means a malformed class or a disassembler bug.
+type VerifyResult struct {
+	Valid   bool           `json:"valid"`
+	Methods []MethodIssues `json:"methods"`
+}
+
+// MethodIssues lists the bad branch targets of one method.
+type MethodIssues struct {
+	Key           string `json:"key"` // MethodInfo.Key
+	BadTargets    []int  `json:"badTargets"`
+	BranchTargets []int  `json:"branchTargets"`
+}
+
+// branchTargets returns the distinct absolute targets of the branch and
+// switch instructions in code, ascending.
+func branchTargets(code []byte) []int {
+	seen := make(map[int]bool)
+	for i := 0; i < len(code); i += insnLength(code, i) {
+		if i+insnSize(code, i) > len(code) {
+			break // truncated operands
+		}
+		switch op := code[i]; op {
+		case 153, 154, 155, 156, 157, 158, 159, 160, 161, 162, 163, 164,
+			165, 166, 167, 168, 198, 199: // if*, goto, jsr, ifnull, ifnonnull
+			seen[i+int(int16(binary.BigEndian.Uint16(code[i+1:i+3])))] = true
+		case 200, 201: // goto_w, jsr_w
+			seen[i+int(int32(binary.BigEndian.Uint32(code[i+1:i+5])))] = true
+		case 170, 171: // tableswitch, lookupswitch
+			if t, ok := decodeSwitch(code, i); ok {
+				seen[t.Default] = true
+				for _, target := range t.Targets {
+					seen[target] = true
+				}
+			}
+		}
+	}
+
+	targets := make([]int, 0, len(seen))
+	for t := range seen {
+		targets = append(targets, t)
+	}
+	sort.Ints(targets)
+	return targets
+}
+
+// misalignedTargets returns the targets that are not the PC of an
+// instruction in code, including any outside it.
+func misalignedTargets(code []byte, targets []int) []int {
+	starts := make(map[int]bool)
+	for i := 0; i < len(code); i += insnLength(code, i) {
+		starts[i] = true
+	}
+	var bad []int
+	for _, t := range targets {
+		if !starts[t] {
+			bad = append(bad, t)
+		}
+	}
+	return bad
+}
+
+// verifyClass checks the branch targets of every method, or of those
+// opts.MethodFilter names. Only methods with bad targets are listed.
+func verifyClass(data []byte, opts classOptions) (_ *VerifyResult, err error) {
+	defer recoverMalformed(&err)
+
+	cf, _, _, err := parseClassBytes(data)
+	if err != nil {
+		return nil, err
+	}
+
+	cp := cf.ConstantPool
+	result := &VerifyResult{Valid: true, Methods: make([]MethodIssues, 0)}
+	for _, m := range cf.Methods {
+		codeAttr := m.Code()
+		name, _ := lookupUtf8(cp, m.NameIndex)
+		desc, _ := lookupUtf8(cp, m.DescriptorIndex)
+		if codeAttr == nil || !opts.disassembles(name, desc) {
+			continue
+		}
+		targets := branchTargets(codeAttr.Codes)
+		if bad := misalignedTargets(codeAttr.Codes, targets); len(bad) > 0 {
+			result.Valid = false
+			result.Methods = append(result.Methods, MethodIssues{
+				Key:           name + desc,
+				BadTargets:    bad,
+				BranchTargets: targets,
+			})
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,58 @@
+package archive
+
+import (
+	"archive/zip"
+	"path"
+	"strings"
+)
+
+// Archive kinds reported in ParseResult.ArchiveKind for zips with a
+// recognised Android layout.
+const (
+	KindApk = "apk"
+	KindAar = "aar"
+)
+
+// AndroidInfo locates the key entries of an APK or AAR so they can be
+// routed to specialised viewers. Nothing is decoded: an APK's manifest
+// is binary XML, an AAR's is plain text.
+type AndroidInfo struct {
+	Manifest   string   `json:"manifest"`             // "AndroidManifest.xml"
+	DexFiles   []string `json:"dexFiles,omitempty"`   // APK: classes.dex, classes2.dex, ...
+	Resources  string   `json:"resources,omitempty"`  // APK: compiled resources.arsc
+	ClassesJar string   `json:"classesJar,omitempty"` // AAR: classes.jar
+}
+
+// detectAndroid recognises an APK (root AndroidManifest.xml plus dex
+// code or compiled resources) or an AAR (root AndroidManifest.xml plus
+// classes.jar or R.txt). It returns "" and nil for any other zip.
+func detectAndroid(r *zip.Reader) (string, *AndroidInfo) {
+	info := &AndroidInfo{}
+	hasRTxt := false
+	for _, f := range r.File {
+		switch name := f.Name; {
+		case name == "AndroidManifest.xml":
+			info.Manifest = name
+		case name == "resources.arsc":
+			info.Resources = name
+		case name == "classes.jar":
+			info.ClassesJar = name
+		case name == "R.txt":
+			hasRTxt = true
+		case !strings.Contains(name, "/") && path.Ext(name) == ".dex" &&
+			strings.HasPrefix(name, "classes"):
+			info.DexFiles = append(info.DexFiles, name)
+		}
+	}
+
+	switch {
+	case info.Manifest == "":
+		return "", nil
+	case len(info.DexFiles) > 0 || info.Resources != "":
+		info.ClassesJar = ""
+		return KindApk, info
+	case info.ClassesJar != "" || hasRTxt:
+		return KindAar, info
+	}
+	return "", nil
+}
@@ -0,0 +1,360 @@
+package archive
+
+import (
+	"bytes"
+	"path"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	MaxFileContentSize = 512 * 1024        // 512KB: skip content for larger files
+	MaxTotalSize       = 100 * 1024 * 1024 // 100MB: reject archives exceeding this
+	BinaryCheckSize    = 512               // bytes to inspect for binary detection
+
+	// DefaultContentBudget caps the total uncompressed content read from
+	// one zip, so a small archive of highly compressible entries (a zip
+	// bomb) cannot exhaust memory. See Options.ContentBudget.
+	DefaultContentBudget = 256 * 1024 * 1024
+
+	// SchemaVersion is the version of the JSON results (ParseResult, the
+	// class parser's ClassInfo, the tgz index) reported in their
+	// schemaVersion field, so JS can tell what a WASM build provides.
+	// Bump it whenever fields are added or change meaning.
+	// 1: the fields as of its introduction.
+	SchemaVersion = 1
+)
+
+// ParsedFile represents a single file entry extracted from the archive.
+type ParsedFile struct {
+	Path        string `json:"path"`
+	Size        int64  `json:"size"`
+	IsDir       bool   `json:"isDir"`
+	Content     string `json:"content,omitempty"` // omitted when empty (directories, binaries, skipped files)
+	IsBinary    bool   `json:"isBinary"`
+	Truncated   bool   `json:"truncated,omitempty"`   // Content holds only a preview
+	Skipped     bool   `json:"skipped,omitempty"`     // too large to load; IsBinary is also set
+	Language    string `json:"language,omitempty"`    // syntax-highlighting hint, e.g. "go"
+	JavaPackage string `json:"javaPackage,omitempty"` // declared package of .java sources
+	IsMinified  bool   `json:"isMinified,omitempty"`  // .min.js/.min.css, or very long lines
+	IsGenerated bool   `json:"isGenerated,omitempty"` // "Code generated", "DO NOT EDIT" or "@generated" near the top
+	LineEnding  string `json:"lineEnding,omitempty"`  // text only: "lf", "crlf" or "mixed"
+	IndentStyle string `json:"indentStyle,omitempty"` // text only: "spaces", "tabs" or "mixed"
+	IsClassFile bool   `json:"isClassFile,omitempty"` // zip only
+	RawBase64   string `json:"rawBase64,omitempty"`   // zip only: raw .class bytes
+	Sha256      string `json:"sha256,omitempty"`      // hex SHA-256 of the content, under hashContent
+	Charset     string `json:"charset,omitempty"`     // text decoded from a BOM: "utf-8", "utf-16le", "utf-16be"
+
+	// OriginalPath is the path as stored in the archive when
+	// Options.NormalizePaths changed it.
+	OriginalPath string `json:"originalPath,omitempty"`
+
+	// Zip only: where the entry's (compressed) data starts in the original
+	// archive and how long it is, so a caller holding the archive Blob can
+	// re-extract a single entry without parsing the whole archive again.
+	DataOffset     int64 `json:"dataOffset,omitempty"`
+	CompressedSize int64 `json:"compressedSize,omitempty"`
+
+	// Zip only: the entry sets general-purpose flag bit 3, meaning its CRC
+	// and sizes follow the data in a data descriptor because the writer
+	// was streaming and did not know them up front.
+	HasDataDescriptor bool `json:"hasDataDescriptor,omitempty"`
+
+	// Tar only: the entry's type from its typeflag (see TarEntryType),
+	// e.g. "regular", "symlink" or "fifo".
+	EntryType string `json:"entryType,omitempty"`
+
+	// LinkTarget is the target path of a symbolic link entry. Resolved is
+	// set when Options.ResolveSymlinks found the target in the archive and
+	// copied its Content and IsBinary into this entry.
+	LinkTarget string `json:"linkTarget,omitempty"`
+	Resolved   bool   `json:"resolved,omitempty"`
+
+	// Tar only: the entry is a container-layer whiteout file (".wh.name"
+	// or the opaque ".wh..wh..opq"). WhiteoutPath is what it deletes from
+	// lower layers; for an opaque whiteout, the directory whose previous
+	// content it hides. See markWhiteout.
+	IsWhiteout     bool   `json:"isWhiteout,omitempty"`
+	WhiteoutPath   string `json:"whiteoutPath,omitempty"`
+	OpaqueWhiteout bool   `json:"opaqueWhiteout,omitempty"`
+}
+
+// ParseResult is the top-level structure returned to JavaScript.
+type ParseResult struct {
+	SchemaVersion int `json:"schemaVersion"` // see archive.SchemaVersion
+
+	ArchiveType string       `json:"archiveType,omitempty"` // set by ParseBytes/ParseReader
+	Files       []ParsedFile `json:"files"`
+
+	// Sha256 is the hex SHA-256 of the archive as given (still
+	// compressed), for comparing against published checksums.
+	Sha256 string `json:"sha256,omitempty"`
+
+	// DirCount counts directory entries, including those left out of
+	// Files by the skipDirs option.
+	DirCount int `json:"dirCount"`
+
+	// SkippedLargeFiles counts entries whose content was not loaded
+	// because they exceed MaxFileContentSize or the content budget (see
+	// ParsedFile.Skipped).
+	SkippedLargeFiles int `json:"skippedLargeFiles"`
+
+	// ContentBudgetExceeded is set when a zip's entries add up to more
+	// than the content budget; entries from that point on are listed
+	// but skipped.
+	ContentBudgetExceeded bool `json:"contentBudgetExceeded,omitempty"`
+
+	// Gzip header fields (tgz only): the original file name, modification
+	// time (RFC 3339) and comment recorded by the compressor, if any.
+	GzipName    string `json:"gzipName,omitempty"`
+	GzipModTime string `json:"gzipModTime,omitempty"`
+	GzipComment string `json:"gzipComment,omitempty"`
+
+	// Compression reports how well a tgz compressed (tgz only).
+	Compression *CompressionStats `json:"compression,omitempty"`
+
+	// BlockWaste sums the padding that rounds each regular file's data
+	// up to a whole 512-byte tar block (tgz and tar only). Archives of
+	// many small files waste a lot of it.
+	BlockWaste int64 `json:"blockWaste,omitempty"`
+
+	// PrefixBytes counts bytes in front of a zip's first entry, as in
+	// self-extracting archives and launcher-stub jars (HasPrefixData).
+	// PrefixFormat names what they look like ("pe", "elf", "script",
+	// "zip", ...), so polyglot files stand out; "" if unrecognised.
+	HasPrefixData bool   `json:"hasPrefixData,omitempty"`
+	PrefixBytes   int64  `json:"prefixBytes,omitempty"`
+	PrefixFormat  string `json:"prefixFormat,omitempty"`
+
+	// LayerCount is the number of image layers merged into Files by
+	// __wasm_resolveImageLayers (see LayerStack).
+	LayerCount int `json:"layerCount,omitempty"`
+
+	// ArchiveKind is KindApk or KindAar for zips with an Android layout,
+	// and Android locates their manifest, dex code and resources.
+	ArchiveKind string       `json:"archiveKind,omitempty"`
+	Android     *AndroidInfo `json:"android,omitempty"`
+
+	// Manifest holds the main attributes of a jar's META-INF/MANIFEST.MF
+	// (Main-Class, Implementation-Version, ...), with wrapped lines joined.
+	Manifest map[string]string `json:"manifest,omitempty"`
+
+	// IsSigned is set for jars carrying a META-INF/*.SF signature file and
+	// a signature block; SignedEntries lists the manifest entries that
+	// have digests. Modifying any of them invalidates the signature.
+	IsSigned      bool     `json:"isSigned,omitempty"`
+	SignedEntries []string `json:"signedEntries,omitempty"`
+
+	// ByExtension aggregates files (not directories) by lower-cased
+	// extension (".js"); files without one are under "".
+	ByExtension map[string]ExtStats `json:"byExtension,omitempty"`
+
+	// DirSizes sums the uncompressed size of files by directory, keyed
+	// by the first Options.DirSizeDepth path components ("src" or, at
+	// depth 2, "src/main"); files in shallower directories count under
+	// those, files at the root under "".
+	DirSizes map[string]int64 `json:"dirSizes,omitempty"`
+
+	// CaseCollisions groups entries whose paths differ only in case
+	// (README and readme), in archive order. They overwrite each other
+	// when extracted on case-insensitive filesystems (macOS, Windows).
+	CaseCollisions [][]string `json:"caseCollisions,omitempty"`
+
+	// Warnings logs notable events of the parse, such as content skipped
+	// or cut short, under the verbose option.
+	Warnings []string `json:"warnings,omitempty"`
+
+	// DuplicateContent groups non-empty files with identical content
+	// (equal ParsedFile.Sha256), in archive order. Only set under the
+	// hashContent option.
+	DuplicateContent [][]string `json:"duplicateContent,omitempty"`
+
+	dataSize  int64               // sum of tar entry sizes, for Compression
+	casePaths map[string][]string // lowercased path -> distinct paths seen
+	caseGroup map[string]int      // lowercased path -> index in CaseCollisions
+}
+
+// ExtStats is one ParseResult.ByExtension bucket.
+type ExtStats struct {
+	Count     int   `json:"count"`
+	TotalSize int64 `json:"totalSize"`
+	TextCount int   `json:"textCount"`
+}
+
+// CompressionStats compares a tgz's size with the tar data it holds.
+type CompressionStats struct {
+	CompressedSize   int64 `json:"compressedSize"`   // bytes of gzip input
+	UncompressedSize int64 `json:"uncompressedSize"` // sum of tar entry sizes
+
+	// Ratio is UncompressedSize / CompressedSize: 4 means the data shrank
+	// to a quarter; close to 1 suggests already-compressed payloads.
+	Ratio float64 `json:"ratio"`
+}
+
+// add appends entry to the result, keeping the summary counters in step.
+// Directories are counted but left out of Files under opts.SkipDirs.
+func (r *ParseResult) add(entry ParsedFile, opts Options) {
+	if entry.Skipped {
+		r.SkippedLargeFiles++
+	}
+	if opts.Verbose {
+		switch {
+		case entry.Skipped:
+			r.Warnings = append(r.Warnings, entry.Path+": content not loaded ("+Itoa(int(entry.Size))+" bytes)")
+		case entry.Truncated:
+			r.Warnings = append(r.Warnings, entry.Path+": content cut to a preview")
+		case entry.Charset != "":
+			r.Warnings = append(r.Warnings, entry.Path+": decoded from "+entry.Charset)
+		}
+	}
+	if entry.IsDir {
+		r.DirCount++
+	}
+	if !entry.IsDir || !opts.SkipDirs {
+		r.Files = append(r.Files, entry)
+	}
+	r.trackCase(entry.Path)
+
+	if !entry.IsDir {
+		if r.ByExtension == nil {
+			r.ByExtension = make(map[string]ExtStats)
+		}
+		ext := strings.ToLower(path.Ext(entry.Path))
+		st := r.ByExtension[ext]
+		st.Count++
+		st.TotalSize += entry.Size
+		if !entry.IsBinary {
+			st.TextCount++
+		}
+		r.ByExtension[ext] = st
+
+		if r.DirSizes == nil {
+			r.DirSizes = make(map[string]int64)
+		}
+		r.DirSizes[opts.dirKey(entry.Path)] += entry.Size
+	}
+}
+
+// trackCase records path for CaseCollisions. A directory "Foo/" collides
+// with a file "foo" too, so the trailing slash is ignored.
+func (r *ParseResult) trackCase(path string) {
+	if r.casePaths == nil {
+		r.casePaths = make(map[string][]string)
+		r.caseGroup = make(map[string]int)
+	}
+	key := strings.ToLower(strings.TrimSuffix(path, "/"))
+	paths := r.casePaths[key]
+	for _, p := range paths {
+		if p == path {
+			return // a duplicate entry, not a case variant
+		}
+	}
+	paths = append(paths, path)
+	r.casePaths[key] = paths
+	if len(paths) < 2 {
+		return
+	}
+
+	if i, ok := r.caseGroup[key]; ok {
+		r.CaseCollisions[i] = paths
+	} else {
+		r.caseGroup[key] = len(r.CaseCollisions)
+		r.CaseCollisions = append(r.CaseCollisions, paths)
+	}
+}
+
+// findDuplicates fills DuplicateContent from the hashed Files.
+func (r *ParseResult) findDuplicates() {
+	groups := make(map[string]int) // Sha256 -> index in DuplicateContent
+	first := make(map[string]string)
+	for _, f := range r.Files {
+		if f.Sha256 == "" || f.Size == 0 {
+			continue
+		}
+		if i, ok := groups[f.Sha256]; ok {
+			r.DuplicateContent[i] = append(r.DuplicateContent[i], f.Path)
+		} else if p, ok := first[f.Sha256]; ok {
+			groups[f.Sha256] = len(r.DuplicateContent)
+			r.DuplicateContent = append(r.DuplicateContent, []string{p, f.Path})
+		} else {
+			first[f.Sha256] = f.Path
+		}
+	}
+}
+
+// IsBinaryContent detects binary data by checking for null bytes
+// and invalid UTF-8 sequences in the first BinaryCheckSize bytes.
+func IsBinaryContent(data []byte) bool {
+	return binaryWithin(data, BinaryCheckSize)
+}
+
+// binaryWithin is IsBinaryContent over the first peek bytes.
+func binaryWithin(data []byte, peek int) bool {
+	n := min(len(data), peek)
+	for i := 0; i < n; i++ {
+		if data[i] == 0 {
+			return true
+		}
+	}
+	return !utf8.Valid(data[:n])
+}
+
+// binaryExts are extensions whose files are binary whatever their first
+// bytes look like.
+var binaryExts = map[string]bool{
+	".class": true, ".jar": true, ".war": true, ".ear": true, ".aar": true,
+	".apk": true, ".dex": true, ".so": true, ".dll": true, ".dylib": true,
+	".exe": true, ".o": true, ".a": true, ".node": true, ".wasm": true,
+	".png": true, ".jpg": true, ".jpeg": true, ".gif": true, ".webp": true,
+	".ico": true, ".bmp": true, ".pdf": true, ".zip": true, ".gz": true,
+	".tgz": true, ".bz2": true, ".xz": true, ".zst": true, ".7z": true,
+	".woff": true, ".woff2": true, ".ttf": true, ".otf": true, ".eot": true,
+	".mp3": true, ".mp4": true, ".ogg": true, ".wav": true, ".pyc": true,
+}
+
+// IsBinaryFile classifies a file by its name first and its content
+// second. Extensions in binaryExts are always binary. Extensions with a
+// language hint (.json, .md, .txt, ...) are text unless data holds a
+// null byte, so a stray invalid byte does not hide a source file. Other
+// files fall back to IsBinaryContent.
+func IsBinaryFile(name string, data []byte) bool {
+	return IsBinaryFilePeek(name, data, BinaryCheckSize)
+}
+
+// IsBinaryFilePeek is IsBinaryFile inspecting the first peek bytes of
+// data rather than BinaryCheckSize.
+func IsBinaryFilePeek(name string, data []byte, peek int) bool {
+	ext := strings.ToLower(path.Ext(name))
+	if binaryExts[ext] {
+		return true
+	}
+	if _, ok := languageByExt[ext]; ok {
+		n := min(len(data), peek)
+		return bytes.IndexByte(data[:n], 0) >= 0
+	}
+	return binaryWithin(data, peek)
+}
+
+// Itoa is a simple int-to-string without importing strconv (keeps binary small).
+func Itoa(n int) string {
+	if n == 0 {
+		return "0"
+	}
+	buf := [20]byte{}
+	i := len(buf) - 1
+	neg := false
+	if n < 0 {
+		neg = true
+		n = -n
+	}
+	for n > 0 {
+		buf[i] = byte('0' + n%10)
+		i--
+		n /= 10
+	}
+	if neg {
+		buf[i] = '-'
+		i--
+	}
+	return string(buf[i+1:])
+}
@@ -0,0 +1,55 @@
+package archive
+
+import (
+	"bytes"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// Charsets reported in ParsedFile.Charset for text that started with a
+// byte-order mark.
+const (
+	CharsetUTF8    = "utf-8"
+	CharsetUTF16LE = "utf-16le"
+	CharsetUTF16BE = "utf-16be"
+)
+
+var (
+	bomUTF8    = []byte{0xEF, 0xBB, 0xBF}
+	bomUTF16LE = []byte{0xFF, 0xFE}
+	bomUTF16BE = []byte{0xFE, 0xFF}
+)
+
+// DecodeBOM strips a leading byte-order mark from buf and transcodes
+// UTF-16 to UTF-8, returning the text and its original charset. Without
+// a BOM, buf is returned as is with charset "". A trailing odd byte of
+// UTF-16 (as left by a preview cut) is dropped.
+func DecodeBOM(buf []byte) ([]byte, string) {
+	switch {
+	case bytes.HasPrefix(buf, bomUTF8):
+		return buf[len(bomUTF8):], CharsetUTF8
+	case bytes.HasPrefix(buf, bomUTF16LE):
+		return utf16ToUTF8(buf[2:], false), CharsetUTF16LE
+	case bytes.HasPrefix(buf, bomUTF16BE):
+		return utf16ToUTF8(buf[2:], true), CharsetUTF16BE
+	}
+	return buf, ""
+}
+
+// utf16ToUTF8 decodes UTF-16 code units; unpaired surrogates become
+// U+FFFD.
+func utf16ToUTF8(b []byte, bigEndian bool) []byte {
+	units := make([]uint16, len(b)/2)
+	for i := range units {
+		if bigEndian {
+			units[i] = uint16(b[2*i])<<8 | uint16(b[2*i+1])
+		} else {
+			units[i] = uint16(b[2*i+1])<<8 | uint16(b[2*i])
+		}
+	}
+	out := make([]byte, 0, len(units))
+	for _, r := range utf16.Decode(units) {
+		out = utf8.AppendRune(out, r)
+	}
+	return out
+}
@@ -0,0 +1,32 @@
+package archive
+
+import "testing"
+
+func TestDecodeBOM(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		in      string
+		want    string
+		charset string
+	}{
+		{"no BOM", "plain", "plain", ""},
+		{"no BOM, UTF-16 looking", "a\x00b\x00", "a\x00b\x00", ""},
+		{"empty", "", "", ""},
+		{"UTF-8", "\xef\xbb\xbfhé", "hé", CharsetUTF8},
+		{"UTF-8 BOM only", "\xef\xbb\xbf", "", CharsetUTF8},
+		{"UTF-16LE", "\xff\xfeh\x00\xe9\x00", "hé", CharsetUTF16LE},
+		{"UTF-16BE", "\xfe\xff\x00h\x00\xe9", "hé", CharsetUTF16BE},
+		{"UTF-16LE surrogate pair", "\xff\xfe\x3d\xd8\x00\xde", "\U0001F600", CharsetUTF16LE},
+		{"UTF-16BE surrogate pair", "\xfe\xff\xd8\x3d\xde\x00", "\U0001F600", CharsetUTF16BE},
+		{"UTF-16LE lone surrogate", "\xff\xfe\x3d\xd8x\x00", "�x", CharsetUTF16LE},
+		{"UTF-16LE odd length", "\xff\xfeh\x00i\x00\x21", "hi", CharsetUTF16LE},
+		{"UTF-16BE odd length", "\xfe\xff\x00h\x00", "h", CharsetUTF16BE},
+		{"UTF-16 BOM only", "\xff\xfe", "", CharsetUTF16LE},
+		{"UTF-16 BOM and one byte", "\xfe\xff\x00", "", CharsetUTF16BE},
+	} {
+		got, charset := DecodeBOM([]byte(tc.in))
+		if string(got) != tc.want || charset != tc.charset {
+			t.Errorf("%s: DecodeBOM(%q) = %q, %q; want %q, %q", tc.name, tc.in, got, charset, tc.want, tc.charset)
+		}
+	}
+}
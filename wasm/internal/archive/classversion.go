@@ -0,0 +1,58 @@
+package archive
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/binary"
+	"io"
+	"strings"
+)
+
+// majorVersionMap maps class file major versions to Java releases.
+var majorVersionMap = map[int]string{
+	45: "1.1", 46: "1.2", 47: "1.3", 48: "1.4",
+	49: "5", 50: "6", 51: "7", 52: "8",
+	53: "9", 54: "10", 55: "11", 56: "12",
+	57: "13", 58: "14", 59: "15", 60: "16",
+	61: "17", 62: "18", 63: "19", 64: "20",
+	65: "21", 66: "22", 67: "23", 68: "24",
+}
+
+// JavaVersion names the Java release that introduced class file major
+// version major, e.g. "8" for 52, or "unknown (N)".
+func JavaVersion(major int) string {
+	if v, ok := majorVersionMap[major]; ok {
+		return v
+	}
+	return "unknown (" + Itoa(major) + ")"
+}
+
+// JarClassVersions counts the .class entries of a jar by Java release
+// (see JavaVersion). Only each class's 8-byte header is read; entries
+// without the 0xCAFEBABE magic, or failing the path filters of opts,
+// are ignored.
+func JarClassVersions(data []byte, opts Options) (map[string]int, error) {
+	r, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, err
+	}
+
+	counts := make(map[string]int)
+	for _, f := range r.File {
+		if f.FileInfo().IsDir() || !strings.HasSuffix(strings.ToLower(f.Name), ".class") || !opts.Includes(f.Name) {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return nil, err
+		}
+		var hdr [8]byte
+		_, err = io.ReadFull(rc, hdr[:])
+		rc.Close()
+		if err != nil || binary.BigEndian.Uint32(hdr[:4]) != 0xCAFEBABE {
+			continue
+		}
+		counts[JavaVersion(int(binary.BigEndian.Uint16(hdr[6:8])))]++
+	}
+	return counts, nil
+}
@@ -0,0 +1,77 @@
+// Package archive holds the archive handling shared by the WASM modules.
+package archive
+
+import (
+	"bytes"
+	"encoding/binary"
+)
+
+// Archive types reported by Detect.
+const (
+	TypeZip     = "zip"
+	TypeTgz     = "tgz"
+	TypeTar     = "tar"
+	TypeUnknown = "unknown"
+)
+
+const (
+	tarMagicOffset = 257 // offset of the "ustar" magic in a tar header block
+	detectSize     = 512 // bytes needed to recognise every supported format
+
+	// zipEndSize is the fixed part of a zip's end of central directory
+	// record; a comment of up to 65535 bytes may follow it.
+	zipEndSize = 22
+	zipTailMax = zipEndSize + 65535
+)
+
+var (
+	zipMagic    = []byte("PK\x03\x04")
+	zipEndMagic = []byte("PK\x05\x06")
+	gzipMagic   = []byte{0x1f, 0x8b}
+	tarMagic    = []byte("ustar")
+)
+
+// Detect sniffs the magic bytes at the start of an archive and returns one
+// of the Type* constants. Only the first detectSize bytes are inspected.
+func Detect(head []byte) string {
+	switch {
+	case bytes.HasPrefix(head, zipMagic):
+		return TypeZip
+	case bytes.HasPrefix(head, gzipMagic):
+		return TypeTgz
+	case len(head) >= tarMagicOffset+len(tarMagic) &&
+		bytes.Equal(head[tarMagicOffset:tarMagicOffset+len(tarMagic)], tarMagic):
+		return TypeTar
+	}
+	return TypeUnknown
+}
+
+// DetectBytes is Detect for a whole in-memory archive. Besides the
+// leading magic bytes it looks for a zip's end of central directory
+// record, so zips with data in front of them (self-extracting archives,
+// launcher-stub jars) are recognised too.
+func DetectBytes(data []byte) string {
+	if kind := Detect(data); kind != TypeUnknown {
+		return kind
+	}
+	if hasZipEnd(data[max(0, len(data)-zipTailMax):]) {
+		return TypeZip
+	}
+	return TypeUnknown
+}
+
+// hasZipEnd reports whether tail, the end of a file, finishes with a zip
+// end of central directory record and its comment.
+func hasZipEnd(tail []byte) bool {
+	for end := len(tail); ; {
+		i := bytes.LastIndex(tail[:end], zipEndMagic)
+		if i < 0 {
+			return false
+		}
+		if i+zipEndSize <= len(tail) &&
+			i+zipEndSize+int(binary.LittleEndian.Uint16(tail[i+20:])) == len(tail) {
+			return true
+		}
+		end = i
+	}
+}
@@ -0,0 +1,82 @@
+package archive
+
+import (
+	"crypto/sha256"
+	"io"
+	"sort"
+	"strings"
+)
+
+// DiffResult is the JSON returned by __wasm_diffArchives. Paths are
+// sorted; only regular files are compared.
+type DiffResult struct {
+	OldType  string   `json:"oldType"`
+	NewType  string   `json:"newType"`
+	Added    []string `json:"added"`
+	Removed  []string `json:"removed"`
+	Modified []string `json:"modified"`
+}
+
+// DiffBytes compares the regular files of two in-memory archives, which
+// may be of different formats. A file is modified when its content
+// SHA-256 differs; every file is hashed in full whatever its size,
+// because tar has no per-entry checksum to fall back on. A leading "./"
+// is ignored, so a tar built from "." lines up with a zip. Only files
+// passing the path filters of opts are compared.
+func DiffBytes(oldData, newData []byte, opts Options) (*DiffResult, error) {
+	oldKind, oldSums, err := contentSums(oldData, opts)
+	if err != nil {
+		return nil, err
+	}
+	newKind, newSums, err := contentSums(newData, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &DiffResult{
+		OldType:  oldKind,
+		NewType:  newKind,
+		Added:    make([]string, 0),
+		Removed:  make([]string, 0),
+		Modified: make([]string, 0),
+	}
+	for p, sum := range newSums {
+		old, ok := oldSums[p]
+		switch {
+		case !ok:
+			result.Added = append(result.Added, p)
+		case old != sum:
+			result.Modified = append(result.Modified, p)
+		}
+	}
+	for p := range oldSums {
+		if _, ok := newSums[p]; !ok {
+			result.Removed = append(result.Removed, p)
+		}
+	}
+	sort.Strings(result.Added)
+	sort.Strings(result.Removed)
+	sort.Strings(result.Modified)
+	return result, nil
+}
+
+// contentSums maps each regular file of an archive to its content
+// SHA-256. A path that occurs twice keeps its last entry, as it would
+// when extracted. Files failing the path filters of opts are left out.
+func contentSums(data []byte, opts Options) (string, map[string][sha256.Size]byte, error) {
+	sums := make(map[string][sha256.Size]byte)
+	kind, err := walkEntries(data, func(e walkEntry, r io.Reader) error {
+		if !e.Regular || !opts.Includes(e.Name) {
+			return nil
+		}
+		h := sha256.New()
+		if _, err := io.Copy(h, r); err != nil {
+			return err
+		}
+		var sum [sha256.Size]byte
+		h.Sum(sum[:0])
+		sums[strings.TrimPrefix(e.Name, "./")] = sum
+		return nil
+	})
+	return kind, sums, err
+}
@@ -0,0 +1,79 @@
+package archive
+
+import (
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"hash"
+	"io"
+	"sort"
+)
+
+// DigestResult is the JSON returned by __wasm_archiveDigest.
+type DigestResult struct {
+	Algo       string `json:"algo"`
+	Digest     string `json:"digest"` // hex
+	EntryCount int    `json:"entryCount"`
+}
+
+// newHash returns the hash constructor for a supported digest algorithm.
+func newHash(algo string) (func() hash.Hash, error) {
+	switch algo {
+	case "sha256":
+		return sha256.New, nil
+	case "sha1":
+		return sha1.New, nil
+	}
+	return nil, errors.New("unsupported digest algorithm " + algo + " (want sha256 or sha1)")
+}
+
+// DigestBytes computes a content identity for an in-memory archive that
+// does not depend on entry order, timestamps, permissions or compression.
+// Each regular file contributes H(path \0 size \0 hex(H(content))); the
+// per-file hashes are concatenated in path order and hashed again.
+// Directories and links are ignored.
+func DigestBytes(data []byte, algo string) (*DigestResult, error) {
+	newH, err := newHash(algo)
+	if err != nil {
+		return nil, err
+	}
+
+	type record struct {
+		path string
+		sum  []byte
+	}
+	var records []record
+
+	_, err = walkEntries(data, func(e walkEntry, r io.Reader) error {
+		if !e.Regular {
+			return nil
+		}
+		h := newH()
+		n, err := io.Copy(h, r)
+		if err != nil {
+			return err
+		}
+		content := hex.EncodeToString(h.Sum(nil))
+
+		h = newH()
+		io.WriteString(h, e.Name+"\x00"+Itoa(int(n))+"\x00"+content)
+		records = append(records, record{path: e.Name, sum: h.Sum(nil)})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(records, func(i, j int) bool { return records[i].path < records[j].path })
+	h := newH()
+	for _, rec := range records {
+		h.Write(rec.sum)
+	}
+
+	return &DigestResult{
+		Algo:       algo,
+		Digest:     hex.EncodeToString(h.Sum(nil)),
+		EntryCount: len(records),
+	}, nil
+}
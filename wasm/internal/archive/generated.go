@@ -0,0 +1,42 @@
+package archive
+
+import "strings"
+
+const (
+	// minifiedLineLength is the average line length above which text is
+	// taken to be minified.
+	minifiedLineLength = 500
+
+	// generatedHeadSize is how much of a file is searched for
+	// generatedMarkers; tools put them in the leading comment.
+	generatedHeadSize = 2048
+)
+
+// generatedMarkers are the comments code generators leave in their
+// output: Go's "// Code generated ... DO NOT EDIT." and the "@generated"
+// tag used by Facebook tooling and others.
+var generatedMarkers = []string{"Code generated", "DO NOT EDIT", "@generated"}
+
+// isMinified reports whether text entry p looks minified: a ".min.js"
+// or ".min.css" name, or content whose average line length exceeds
+// minifiedLineLength.
+func isMinified(p, content string) bool {
+	lower := strings.ToLower(p)
+	if strings.HasSuffix(lower, ".min.js") || strings.HasSuffix(lower, ".min.css") {
+		return true
+	}
+	lines := strings.Count(strings.TrimSuffix(content, "\n"), "\n") + 1
+	return len(content)/lines > minifiedLineLength
+}
+
+// isGenerated reports whether the head of content carries one of the
+// generatedMarkers.
+func isGenerated(content string) bool {
+	head := content[:min(len(content), generatedHeadSize)]
+	for _, m := range generatedMarkers {
+		if strings.Contains(head, m) {
+			return true
+		}
+	}
+	return false
+}
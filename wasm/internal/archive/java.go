@@ -0,0 +1,91 @@
+package archive
+
+import "strings"
+
+// javaPackage returns the package named by the leading
+// "package x.y.z;" declaration of Java source, skipping whitespace,
+// comments and annotations (package-info.java) that may precede it.
+// It returns "" for the default package or if no declaration is found.
+func javaPackage(src string) string {
+	s := src
+	for {
+		s = strings.TrimLeft(s, " \t\r\n\f\ufeff")
+		switch {
+		case strings.HasPrefix(s, "//"):
+			_, rest, ok := strings.Cut(s, "\n")
+			if !ok {
+				return ""
+			}
+			s = rest
+		case strings.HasPrefix(s, "/*"):
+			end := strings.Index(s[2:], "*/")
+			if end < 0 {
+				return ""
+			}
+			s = s[2+end+2:]
+		case strings.HasPrefix(s, "@"):
+			s = skipAnnotation(s)
+		case strings.HasPrefix(s, "package") && len(s) > len("package") &&
+			!isJavaIdentPart(s[len("package")]):
+			decl, _, ok := strings.Cut(s[len("package"):], ";")
+			if !ok {
+				return ""
+			}
+			// Drop comments and whitespace inside the dotted name.
+			return strings.Join(strings.Fields(stripComments(decl)), "")
+		default:
+			return ""
+		}
+	}
+}
+
+// skipAnnotation skips "@Name" or "@Name(...)" at the start of s.
+func skipAnnotation(s string) string {
+	i := 1
+	for i < len(s) && (isJavaIdentPart(s[i]) || s[i] == '.') {
+		i++
+	}
+	rest := strings.TrimLeft(s[i:], " \t\r\n")
+	if !strings.HasPrefix(rest, "(") {
+		return rest
+	}
+	depth := 0
+	for j := 0; j < len(rest); j++ {
+		switch rest[j] {
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				return rest[j+1:]
+			}
+		}
+	}
+	return ""
+}
+
+// stripComments removes // and /* */ comments from a short fragment.
+func stripComments(s string) string {
+	var sb strings.Builder
+	for len(s) > 0 {
+		switch {
+		case strings.HasPrefix(s, "//"):
+			_, s, _ = strings.Cut(s, "\n")
+		case strings.HasPrefix(s, "/*"):
+			end := strings.Index(s[2:], "*/")
+			if end < 0 {
+				return sb.String()
+			}
+			s = s[2+end+2:]
+		default:
+			sb.WriteByte(s[0])
+			s = s[1:]
+		}
+	}
+	return sb.String()
+}
+
+func isJavaIdentPart(c byte) bool {
+	return c == '_' || c == '$' || c >= 0x80 ||
+		('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z') || ('0' <= c && c <= '9')
+}
@@ -0,0 +1,360 @@
+package archive
+
+import (
+	"encoding/json"
+	"errors"
+	"regexp"
+	"syscall/js"
+)
+
+// DetectResult is the JSON returned by __wasm_detectArchive.
+type DetectResult struct {
+	Type string `json:"type"`
+}
+
+// DetectArchive implements __wasm_detectArchive(Uint8Array, options?) -> Promise<string>.
+// Every module registers it so JS can sniff an archive with whichever
+// module happens to be loaded. Only the leading bytes are copied into Go,
+// plus the trailing ones when those do not match, to find prefixed zips.
+// options is reserved for the uniform (input, options?) signature: no
+// option applies yet, and it is ignored.
+func DetectArchive(_ js.Value, args []js.Value) any {
+	if len(args) < 1 || len(args) > 2 {
+		return JSError("detectArchive requires 1 or 2 arguments (Uint8Array, options?)")
+	}
+
+	jsArr := args[0]
+	n := jsArr.Get("length").Int()
+	if n > detectSize {
+		n = detectSize
+	}
+	head := make([]byte, n)
+	js.CopyBytesToGo(head, jsArr.Call("subarray", 0, n))
+
+	kind := Detect(head)
+	if total := jsArr.Get("length").Int(); kind == TypeUnknown && total > n {
+		tail := make([]byte, min(total, zipTailMax))
+		js.CopyBytesToGo(tail, jsArr.Call("subarray", total-len(tail), total))
+		if hasZipEnd(tail) {
+			kind = TypeZip
+		}
+	}
+
+	jsonBytes, err := json.Marshal(DetectResult{Type: kind})
+	if err != nil {
+		return JSError("Failed to serialize result: " + err.Error())
+	}
+	return js.Global().Get("Promise").Call("resolve", string(jsonBytes))
+}
+
+// OptionsFromJS reads parse Options from a JS options object. An
+// undefined or null object yields the default Options.
+func OptionsFromJS(v js.Value) (Options, error) {
+	var opts Options
+	if v.IsUndefined() || v.IsNull() {
+		return opts, nil
+	}
+
+	if n, ok, err := OptionNumber(v, "previewBytes", false); err != nil {
+		return opts, err
+	} else if ok {
+		opts.PreviewBytes = int(n)
+	}
+
+	if n, ok, err := OptionNumber(v, "previewLines", false); err != nil {
+		return opts, err
+	} else if ok {
+		opts.PreviewLines = int(n)
+	}
+
+	if pr, ok, err := OptionString(v, "pathRegex"); err != nil {
+		return opts, err
+	} else if ok {
+		re, err := regexp.Compile(pr)
+		if err != nil {
+			return opts, errors.New("invalid pathRegex: " + err.Error())
+		}
+		opts.PathRegex = re
+	}
+
+	var err error
+	if opts.Include, _, err = OptionStrings(v, "include"); err != nil {
+		return opts, err
+	}
+	if opts.Exclude, _, err = OptionStrings(v, "exclude"); err != nil {
+		return opts, err
+	}
+	if err := checkGlobs(opts.Include, opts.Exclude); err != nil {
+		return opts, err
+	}
+
+	if m, ok, err := OptionString(v, "method", MethodStore, MethodDeflate); err != nil {
+		return opts, err
+	} else if ok {
+		opts.Method = m
+	}
+
+	opts.JavaPackage = v.Get("javaPackage").Truthy()
+	opts.BinaryAsBase64 = v.Get("binaryAsBase64").Truthy()
+	opts.ResolveSymlinks = v.Get("resolveSymlinks").Truthy()
+	opts.SkipDirs = v.Get("skipDirs").Truthy()
+	opts.HashContent = v.Get("hashContent").Truthy()
+	opts.Verbose = v.Get("verbose").Truthy()
+	opts.NormalizePaths = v.Get("normalizePaths").Truthy()
+	opts.Strict = v.Get("strict").Truthy()
+
+	if n, ok, err := OptionNumber(v, "dirSizeDepth", true); err != nil {
+		return opts, err
+	} else if ok {
+		opts.DirSizeDepth = int(n)
+	}
+
+	if n, ok, err := OptionNumber(v, "contentBudget", false); err != nil {
+		return opts, err
+	} else if ok {
+		opts.ContentBudget = int64(n)
+	}
+
+	return opts, nil
+}
+
+// SearchOptionsFromJS reads SearchOptions from a JS options object
+// ({regex, caseInsensitive, pathGlob, maxMatches}).
+func SearchOptionsFromJS(v js.Value) (SearchOptions, error) {
+	var opts SearchOptions
+	if v.IsUndefined() || v.IsNull() {
+		return opts, nil
+	}
+
+	opts.Regex = v.Get("regex").Truthy()
+	opts.CaseInsensitive = v.Get("caseInsensitive").Truthy()
+	if g, ok, err := OptionString(v, "pathGlob"); err != nil {
+		return opts, err
+	} else if ok {
+		opts.PathGlob = g
+	}
+	if n, ok, err := OptionNumber(v, "maxMatches", false); err != nil {
+		return opts, err
+	} else if ok {
+		opts.MaxMatches = int(n)
+	}
+
+	return opts, nil
+}
+
+// The Option* helpers read one field of a JS options object for the
+// *FromJS readers of every module, so each knob is validated and
+// reported the same way. ok is false when the object is undefined or
+// null or the field is undefined, in which case the caller keeps its
+// default; a field of the wrong type or range is an error naming it.
+
+// OptionNumber reads a number that must be at least 1 when positive is
+// set and at least 0 otherwise.
+func OptionNumber(v js.Value, name string, positive bool) (n float64, ok bool, err error) {
+	f, ok := optionField(v, name)
+	if !ok {
+		return 0, false, nil
+	}
+	if positive {
+		if f.Type() != js.TypeNumber || f.Float() < 1 {
+			return 0, false, errors.New(name + " must be a positive number")
+		}
+	} else if f.Type() != js.TypeNumber || f.Float() < 0 {
+		return 0, false, errors.New(name + " must be a non-negative number")
+	}
+	return f.Float(), true, nil
+}
+
+// OptionString reads a string. If allowed is not empty the string must
+// be one of its values.
+func OptionString(v js.Value, name string, allowed ...string) (s string, ok bool, err error) {
+	f, ok := optionField(v, name)
+	if !ok {
+		return "", false, nil
+	}
+	if f.Type() == js.TypeString {
+		if len(allowed) == 0 {
+			return f.String(), true, nil
+		}
+		for _, a := range allowed {
+			if f.String() == a {
+				return a, true, nil
+			}
+		}
+	}
+	if len(allowed) == 0 {
+		return "", false, errors.New(name + " must be a string")
+	}
+	msg := name + " must be "
+	for i, a := range allowed {
+		switch {
+		case i == 0:
+		case i == len(allowed)-1:
+			msg += " or "
+		default:
+			msg += ", "
+		}
+		msg += `"` + a + `"`
+	}
+	return "", false, errors.New(msg)
+}
+
+// RequiredOptionNumber is OptionNumber for an option that must be given.
+func RequiredOptionNumber(v js.Value, name string, positive bool) (float64, error) {
+	n, ok, err := OptionNumber(v, name, positive)
+	if err == nil && !ok {
+		err = errors.New(name + " is required")
+	}
+	return n, err
+}
+
+// RequiredOptionString is OptionString for an option that must be given.
+func RequiredOptionString(v js.Value, name string) (string, error) {
+	s, ok, err := OptionString(v, name)
+	if err == nil && !ok {
+		err = errors.New(name + " is required")
+	}
+	return s, err
+}
+
+// OptionStrings reads an array of strings.
+func OptionStrings(v js.Value, name string) (s []string, ok bool, err error) {
+	f, ok := optionField(v, name)
+	if !ok {
+		return nil, false, nil
+	}
+	s, err = StringsFromJS(f)
+	if err != nil {
+		return nil, false, errors.New(name + " must be an array of strings")
+	}
+	return s, true, nil
+}
+
+// OptionFunc reads a callback.
+func OptionFunc(v js.Value, name string) (fn js.Value, ok bool, err error) {
+	f, ok := optionField(v, name)
+	if !ok {
+		return js.Undefined(), false, nil
+	}
+	if f.Type() != js.TypeFunction {
+		return js.Undefined(), false, errors.New(name + " must be a function")
+	}
+	return f, true, nil
+}
+
+// optionField returns field name of options object v, or false if v
+// is not an object or the field is undefined.
+func optionField(v js.Value, name string) (js.Value, bool) {
+	if v.Type() != js.TypeObject && v.Type() != js.TypeFunction {
+		return js.Undefined(), false
+	}
+	f := v.Get(name)
+	return f, !f.IsUndefined()
+}
+
+// JSError returns a rejected Promise carrying a JS Error with msg.
+func JSError(msg string) any {
+	return js.Global().Get("Promise").Call("reject",
+		js.Global().Get("Error").New(msg))
+}
+
+// InvokeCallback calls the JS function fn with args and returns what it
+// throws as an error, "name threw: ...". Invoke panics with a js.Error
+// instead, which in a goroutine takes down the whole module and leaves
+// the promise unsettled.
+func InvokeCallback(fn js.Value, name string, args ...any) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			jsErr, ok := r.(js.Error)
+			if !ok {
+				panic(r)
+			}
+			err = errors.New(name + " threw: " + jsErr.Error())
+		}
+	}()
+	fn.Invoke(args...)
+	return nil
+}
+
+// ParseErrorToJS returns the JS Error to reject a parse with: msg and
+// err's text. A *TruncatedArchiveError becomes an Error named
+// "TruncatedArchiveError" that also carries entries (the count read
+// before the cut) and partial (result as JSON), so callers can still
+// show those files.
+func ParseErrorToJS(msg string, err error, result *ParseResult) js.Value {
+	jsErr := js.Global().Get("Error").New(msg + err.Error())
+	var trunc *TruncatedArchiveError
+	if errors.As(err, &trunc) && result != nil {
+		jsErr.Set("name", "TruncatedArchiveError")
+		jsErr.Set("entries", trunc.Entries)
+		if partial, err := json.Marshal(result); err == nil {
+			jsErr.Set("partial", string(partial))
+		}
+	}
+	return jsErr
+}
+
+// ReadBlob copies size bytes at offset out of a JS Blob, using
+// Blob.slice so only that range is materialized, and waits for the
+// asynchronous arrayBuffer() read to finish.
+func ReadBlob(blob js.Value, offset, size int64) ([]byte, error) {
+	// Blob.slice(start, end) returns a new Blob of that range.
+	slice := blob.Call("slice", offset, offset+size)
+
+	// slice.arrayBuffer() returns a Promise<ArrayBuffer>.
+	ch := make(chan struct{})
+	var arrBuf js.Value
+	var readErr error
+
+	thenCb := js.FuncOf(func(_ js.Value, args []js.Value) any {
+		arrBuf = args[0]
+		close(ch)
+		return nil
+	})
+	catchCb := js.FuncOf(func(_ js.Value, args []js.Value) any {
+		readErr = js.Error{Value: args[0]}
+		close(ch)
+		return nil
+	})
+	defer thenCb.Release()
+	defer catchCb.Release()
+
+	slice.Call("arrayBuffer").Call("then", thenCb).Call("catch", catchCb)
+	<-ch
+
+	if readErr != nil {
+		return nil, readErr
+	}
+
+	jsArr := js.Global().Get("Uint8Array").New(arrBuf)
+	data := make([]byte, jsArr.Get("length").Int())
+	js.CopyBytesToGo(data, jsArr)
+	return data, nil
+}
+
+// StringsFromJS converts a JS array of strings to a Go slice. undefined
+// and null give an empty slice.
+func StringsFromJS(v js.Value) ([]string, error) {
+	if v.IsUndefined() || v.IsNull() {
+		return nil, nil
+	}
+	if !v.InstanceOf(js.Global().Get("Array")) {
+		return nil, errors.New("expected an array of strings")
+	}
+	out := make([]string, v.Length())
+	for i := range out {
+		e := v.Index(i)
+		if e.Type() != js.TypeString {
+			return nil, errors.New("expected an array of strings")
+		}
+		out[i] = e.String()
+	}
+	return out, nil
+}
+
+// BytesToJS copies data into a new JS Uint8Array.
+func BytesToJS(data []byte) js.Value {
+	arr := js.Global().Get("Uint8Array").New(len(data))
+	js.CopyBytesToJS(arr, data)
+	return arr
+}
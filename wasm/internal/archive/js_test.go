@@ -0,0 +1,128 @@
+package archive
+
+import (
+	"reflect"
+	"syscall/js"
+	"testing"
+)
+
+func TestOptionsFromJS(t *testing.T) {
+	for _, v := range []js.Value{js.Undefined(), js.Null(), js.ValueOf(map[string]any{})} {
+		opts, err := OptionsFromJS(v)
+		if err != nil || !reflect.DeepEqual(opts, Options{}) {
+			t.Errorf("OptionsFromJS(%v) = %+v, %v; want defaults", v, opts, err)
+		}
+	}
+
+	opts, err := OptionsFromJS(js.ValueOf(map[string]any{
+		"previewBytes":   100,
+		"previewLines":   5,
+		"pathRegex":      `\.go$`,
+		"include":        []any{"*.go", "cmd/*"},
+		"exclude":        []any{"*_test.go"},
+		"method":         "deflate",
+		"skipDirs":       true,
+		"hashContent":    1,
+		"strict":         "yes",
+		"normalizePaths": false,
+		"dirSizeDepth":   2,
+		"contentBudget":  1 << 20,
+		"unknownKnob":    true,
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if opts.PreviewBytes != 100 || opts.PreviewLines != 5 || opts.Method != MethodDeflate ||
+		opts.DirSizeDepth != 2 || opts.ContentBudget != 1<<20 {
+		t.Errorf("numbers and strings: %+v", opts)
+	}
+	if !opts.SkipDirs || !opts.HashContent || !opts.Strict || opts.NormalizePaths || opts.Verbose {
+		t.Errorf("booleans (JS truthiness): %+v", opts)
+	}
+	if !reflect.DeepEqual(opts.Include, []string{"*.go", "cmd/*"}) || !reflect.DeepEqual(opts.Exclude, []string{"*_test.go"}) {
+		t.Errorf("Include %q, Exclude %q", opts.Include, opts.Exclude)
+	}
+	for p, want := range map[string]bool{
+		"main.go":          true,
+		"pkg/util.go":      true,
+		"pkg/util_test.go": false,
+		"cmd/tool":         false, // fails pathRegex
+		"README.md":        false,
+	} {
+		if got := opts.Includes(p); got != want {
+			t.Errorf("Includes(%q) = %v, want %v", p, got, want)
+		}
+	}
+}
+
+func TestOptionsFromJSErrors(t *testing.T) {
+	for _, tc := range []struct {
+		options map[string]any
+		want    string
+	}{
+		{map[string]any{"previewBytes": -1}, "previewBytes must be a non-negative number"},
+		{map[string]any{"previewLines": "10"}, "previewLines must be a non-negative number"},
+		{map[string]any{"dirSizeDepth": 0}, "dirSizeDepth must be a positive number"},
+		{map[string]any{"pathRegex": 7}, "pathRegex must be a string"},
+		{map[string]any{"pathRegex": "("}, "invalid pathRegex: error parsing regexp: missing closing ): `(`"},
+		{map[string]any{"method": "lzma"}, `method must be "store" or "deflate"`},
+		{map[string]any{"include": "*.go"}, "include must be an array of strings"},
+		{map[string]any{"exclude": []any{"a", 1}}, "exclude must be an array of strings"},
+		{map[string]any{"include": []any{"["}}, "invalid pattern [: syntax error in pattern"},
+	} {
+		_, err := OptionsFromJS(js.ValueOf(tc.options))
+		if err == nil || err.Error() != tc.want {
+			t.Errorf("OptionsFromJS(%v): err = %v, want %q", tc.options, err, tc.want)
+		}
+	}
+}
+
+func TestOptionHelpers(t *testing.T) {
+	v := js.ValueOf(map[string]any{"n": 3, "s": "b", "f": js.FuncOf(func(js.Value, []js.Value) any { return nil })})
+
+	if n, ok, err := OptionNumber(v, "n", true); n != 3 || !ok || err != nil {
+		t.Errorf("OptionNumber(n) = %v, %v, %v", n, ok, err)
+	}
+	if _, ok, err := OptionNumber(v, "missing", true); ok || err != nil {
+		t.Errorf("OptionNumber(missing) = %v, %v; want the default", ok, err)
+	}
+	if s, ok, err := OptionString(v, "s", "a", "b", "c"); s != "b" || !ok || err != nil {
+		t.Errorf("OptionString(s) = %q, %v, %v", s, ok, err)
+	}
+	if _, _, err := OptionString(v, "n", "a", "b", "c"); err == nil || err.Error() != `n must be "a", "b" or "c"` {
+		t.Errorf("OptionString(n) err = %v", err)
+	}
+	if _, ok, err := OptionFunc(v, "f"); !ok || err != nil {
+		t.Errorf("OptionFunc(f) = %v, %v", ok, err)
+	}
+	if _, _, err := OptionFunc(v, "s"); err == nil {
+		t.Error("OptionFunc(s) accepted a string")
+	}
+	if _, err := RequiredOptionString(v, "missing"); err == nil || err.Error() != "missing is required" {
+		t.Errorf("RequiredOptionString(missing) err = %v", err)
+	}
+	if n, err := RequiredOptionNumber(v, "n", false); n != 3 || err != nil {
+		t.Errorf("RequiredOptionNumber(n) = %v, %v", n, err)
+	}
+	// Non-object options read as empty
+	if _, ok, err := OptionNumber(js.ValueOf(5), "n", false); ok || err != nil {
+		t.Errorf("OptionNumber on a number = %v, %v", ok, err)
+	}
+}
+
+func TestInvokeCallback(t *testing.T) {
+	var got []any
+	ok := js.FuncOf(func(_ js.Value, args []js.Value) any {
+		got = append(got, args[0].String(), args[1].Int())
+		return nil
+	})
+	defer ok.Release()
+	if err := InvokeCallback(ok.Value, "cb", "a", 1); err != nil || len(got) != 2 || got[0] != "a" || got[1] != 1 {
+		t.Errorf("InvokeCallback = %v, callback saw %v", err, got)
+	}
+
+	throws := js.Global().Get("Function").New("throw new TypeError('bad entry')")
+	if err := InvokeCallback(throws, "onEntry"); err == nil || err.Error() != "onEntry threw: JavaScript error: bad entry" {
+		t.Errorf("throwing callback: err = %v", err)
+	}
+}
@@ -0,0 +1,87 @@
+package archive
+
+import (
+	"path"
+	"strings"
+)
+
+// languageByExt maps lower-case file extensions to language hints.
+var languageByExt = map[string]string{
+	".go": "go", ".java": "java", ".kt": "kotlin", ".kts": "kotlin",
+	".scala": "scala", ".groovy": "groovy", ".gradle": "groovy",
+	".py": "python", ".pyi": "python", ".rb": "ruby", ".rs": "rust",
+	".js": "javascript", ".mjs": "javascript", ".cjs": "javascript",
+	".jsx": "jsx", ".ts": "typescript", ".mts": "typescript",
+	".cts": "typescript", ".tsx": "tsx", ".vue": "vue", ".svelte": "svelte",
+	".c": "c", ".h": "c", ".cc": "cpp", ".cpp": "cpp", ".cxx": "cpp",
+	".hpp": "cpp", ".cs": "csharp", ".swift": "swift", ".m": "objective-c",
+	".php": "php", ".pl": "perl", ".pm": "perl", ".lua": "lua",
+	".sh": "shell", ".bash": "shell", ".zsh": "shell", ".fish": "fish",
+	".ps1": "powershell", ".bat": "batch", ".cmd": "batch",
+	".json": "json", ".json5": "json5", ".yaml": "yaml", ".yml": "yaml",
+	".toml": "toml", ".xml": "xml", ".pom": "xml", ".svg": "xml",
+	".ini": "ini", ".cfg": "ini", ".properties": "properties",
+	".md": "markdown", ".mdx": "mdx", ".rst": "rst", ".txt": "text",
+	".html": "html", ".htm": "html", ".css": "css", ".scss": "scss",
+	".sass": "sass", ".less": "less", ".sql": "sql", ".proto": "protobuf",
+	".graphql": "graphql", ".gql": "graphql", ".diff": "diff",
+	".patch": "diff", ".cmake": "cmake",
+}
+
+// languageByName covers well-known extensionless (or dot) file names.
+var languageByName = map[string]string{
+	"makefile": "makefile", "gnumakefile": "makefile",
+	"dockerfile": "dockerfile", "containerfile": "dockerfile",
+	"gemfile": "ruby", "rakefile": "ruby", "jenkinsfile": "groovy",
+	"cmakelists.txt": "cmake", "go.mod": "go-mod", ".bashrc": "shell",
+	".profile": "shell",
+}
+
+// languageByInterpreter maps shebang interpreters (version suffix
+// stripped) to language hints.
+var languageByInterpreter = map[string]string{
+	"sh": "shell", "bash": "shell", "zsh": "shell", "dash": "shell",
+	"ksh": "shell", "fish": "fish", "python": "python", "ruby": "ruby",
+	"perl": "perl", "php": "php", "node": "javascript", "deno": "typescript",
+	"lua": "lua",
+}
+
+// detectLanguage guesses a syntax-highlighting language for an entry,
+// primarily from its name and falling back to the shebang line of
+// content for extensionless scripts. It returns "" when unknown.
+func detectLanguage(p, content string) string {
+	base := strings.ToLower(path.Base(p))
+	if lang, ok := languageByName[base]; ok {
+		return lang
+	}
+	if lang, ok := languageByExt[path.Ext(base)]; ok {
+		return lang
+	}
+	return shebangLanguage(content)
+}
+
+// shebangLanguage maps a "#!" first line such as "#!/usr/bin/env python3"
+// to a language hint.
+func shebangLanguage(content string) string {
+	if !strings.HasPrefix(content, "#!") {
+		return ""
+	}
+	line, _, _ := strings.Cut(content[2:], "\n")
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return ""
+	}
+	interp := path.Base(fields[0])
+	if interp == "env" {
+		// Skip env flags such as "-S".
+		interp = ""
+		for _, f := range fields[1:] {
+			if !strings.HasPrefix(f, "-") {
+				interp = f
+				break
+			}
+		}
+	}
+	interp = strings.TrimRight(interp, "0123456789.")
+	return languageByInterpreter[interp]
+}
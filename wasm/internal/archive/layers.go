@@ -0,0 +1,113 @@
+package archive
+
+import (
+	"path"
+	"strings"
+)
+
+// LayerStack merges container image layers into the file system view
+// of the final image: entries of later layers replace earlier ones, and
+// whiteouts (see markWhiteout) delete what lower layers put there. Apply
+// layers bottom first; each is folded in as soon as it is parsed, so
+// replaced entries can be garbage collected. Parse layers with the
+// options' ForLayer: a whiteout or a file replacing a directory counts
+// whether or not its path passes the filters, which Result applies to
+// the merged file system.
+type LayerStack struct {
+	files  map[string]ParsedFile // path.Clean(Path) -> current entry
+	order  []string              // keys in order of first appearance
+	layers int
+}
+
+// ForLayer returns o for parsing a layer to Apply: entries failing the
+// path filters are kept, without content, and so are directories, as
+// LayerStack needs every path, whiteout and directory. Result applies
+// the filters and SkipDirs of o.
+func (o Options) ForLayer() Options {
+	o.keepFiltered = true
+	o.SkipDirs = false
+	return o
+}
+
+// NewLayerStack returns an empty LayerStack.
+func NewLayerStack() *LayerStack {
+	return &LayerStack{files: make(map[string]ParsedFile)}
+}
+
+// Apply stacks layer on top of the layers applied so far. Its whiteouts
+// only affect the lower layers, never its own entries.
+func (s *LayerStack) Apply(layer *ParseResult) {
+	s.layers++
+	for _, f := range layer.Files {
+		if !f.IsWhiteout || (f.WhiteoutPath == "" && !f.OpaqueWhiteout) {
+			continue
+		}
+		target := path.Clean(f.WhiteoutPath)
+		if !f.OpaqueWhiteout {
+			delete(s.files, target)
+		}
+		s.deleteBelow(target)
+	}
+
+	for _, f := range layer.Files {
+		if f.IsWhiteout {
+			continue
+		}
+		key := path.Clean(f.Path)
+		prev, ok := s.files[key]
+		if !ok {
+			s.order = append(s.order, key)
+		} else if prev.IsDir && !f.IsDir {
+			s.deleteBelow(key) // a file replaces a whole directory
+		}
+		s.files[key] = f
+	}
+}
+
+// deleteBelow removes every entry inside directory dir ("." for the
+// root).
+func (s *LayerStack) deleteBelow(dir string) {
+	prefix := dir + "/"
+	for key := range s.files {
+		if dir == "." || strings.HasPrefix(key, prefix) {
+			if key != dir {
+				delete(s.files, key)
+			}
+		}
+	}
+}
+
+// Result returns the merged file system as a ParseResult, with entries
+// in the order their paths first appeared. Only entries passing the path
+// filters of opts are included.
+func (s *LayerStack) Result(opts Options) *ParseResult {
+	result := &ParseResult{
+		SchemaVersion: SchemaVersion,
+		ArchiveType:   TypeTgz,
+		Files:         make([]ParsedFile, 0, len(s.files)),
+		LayerCount:    s.layers,
+	}
+	seen := make(map[string]bool, len(s.files))
+	for _, key := range s.order {
+		f, ok := s.files[key]
+		if !ok || seen[key] {
+			continue
+		}
+		seen[key] = true
+		name := f.Path
+		if f.OriginalPath != "" {
+			name = f.OriginalPath
+		}
+		if opts.Includes(name) {
+			result.add(f, opts)
+		}
+	}
+
+	if opts.ResolveSymlinks {
+		result.resolveSymlinks()
+	}
+	if opts.HashContent {
+		result.findDuplicates()
+	}
+	return result
+}
@@ -0,0 +1,57 @@
+package archive
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+// Whiteouts and directory replacements apply even when their paths fail
+// the filters, which only narrow the merged result.
+func TestLayerStackFilters(t *testing.T) {
+	layers := [][]byte{
+		rawTar(
+			[]string{"app/main.go", "0", "package main"},
+			[]string{"app/old.go", "0", "package main"},
+			[]string{"app/README.md", "0", "# app"},
+			[]string{"lib/x.go", "0", "package lib"},
+			[]string{"gen/", "5", ""},
+			[]string{"gen/a.go", "0", "package gen"},
+		),
+		rawTar(
+			[]string{"app/.wh.old.go", "0", ""},
+			[]string{"lib/.wh..wh..opq", "0", ""},
+			[]string{"gen", "0", "now a file"},
+			[]string{"app/new.go", "0", "package main"},
+		),
+	}
+
+	for _, tc := range []struct {
+		opts Options
+		want []string
+	}{
+		{Options{SkipDirs: true}, []string{"app/main.go", "app/README.md", "gen", "app/new.go"}},
+		{Options{Include: []string{"*.go"}}, []string{"app/main.go", "app/new.go"}},
+		{Options{Exclude: []string{"*.go"}, SkipDirs: true}, []string{"app/README.md", "gen"}},
+	} {
+		stack := NewLayerStack()
+		for _, layer := range layers {
+			result, err := ParseTar(bytes.NewReader(layer), tc.opts.ForLayer())
+			if err != nil {
+				t.Fatal(err)
+			}
+			stack.Apply(result)
+		}
+
+		var got []string
+		for _, f := range stack.Result(tc.opts).Files {
+			if f.Content == "" && !f.IsDir {
+				t.Errorf("%+v: %s has no content", tc.opts, f.Path)
+			}
+			got = append(got, f.Path)
+		}
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%+v: merged files %q, want %q", tc.opts, got, tc.want)
+		}
+	}
+}
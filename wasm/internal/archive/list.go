@@ -0,0 +1,55 @@
+package archive
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"io"
+)
+
+// ListZipPaths returns the names of a zip's entries that pass the path
+// filters of opts, in archive order. Only the central directory is
+// read; no entry is opened.
+func ListZipPaths(data []byte, opts Options) ([]string, error) {
+	r, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, err
+	}
+	paths := make([]string, 0, len(r.File))
+	for _, f := range r.File {
+		if opts.Includes(f.Name) {
+			paths = append(paths, f.Name)
+		}
+	}
+	return paths, nil
+}
+
+// ListTgzPaths returns the entry names of a tgz streamed from r.
+func ListTgzPaths(r io.Reader) ([]string, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+	defer gz.Close()
+	return ListTarPaths(gz)
+}
+
+// ListTarPaths returns the entry names of an uncompressed tar streamed
+// from r. Entry data is skipped by tar.Reader without being inspected.
+func ListTarPaths(r io.Reader) ([]string, error) {
+	tr := tar.NewReader(r)
+	paths := make([]string, 0, 64)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return paths, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if hdr.Name != "" {
+			paths = append(paths, hdr.Name)
+		}
+	}
+}
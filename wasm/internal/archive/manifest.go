@@ -0,0 +1,119 @@
+package archive
+
+import (
+	"archive/zip"
+	"io"
+	"path"
+	"sort"
+	"strings"
+)
+
+const manifestPath = "META-INF/MANIFEST.MF"
+
+// manifest is a parsed META-INF/MANIFEST.MF: the main section plus one
+// attribute map per "Name:" (per-entry) section.
+type manifest struct {
+	Main    map[string]string
+	Entries []map[string]string
+}
+
+// readZipManifest parses a jar's META-INF/MANIFEST.MF, returning nil if
+// the archive has none or it cannot be read.
+func readZipManifest(r *zip.Reader) *manifest {
+	for _, f := range r.File {
+		if !strings.EqualFold(f.Name, manifestPath) {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return nil
+		}
+		data, err := io.ReadAll(io.LimitReader(rc, MaxFileContentSize))
+		rc.Close()
+		if err != nil {
+			return nil
+		}
+		return parseManifest(string(data))
+	}
+	return nil
+}
+
+// parseManifest parses "Name: value" headers into sections separated by
+// blank lines. Lines are wrapped at 72 bytes, with each continuation line
+// starting with a single space.
+func parseManifest(text string) *manifest {
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	text = strings.ReplaceAll(text, "\r", "\n")
+
+	m := &manifest{}
+	var section map[string]string
+	last := ""
+	for _, line := range strings.Split(text, "\n") {
+		if line == "" {
+			section, last = nil, ""
+			continue
+		}
+		if line[0] == ' ' {
+			if section != nil && last != "" {
+				section[last] += line[1:]
+			}
+			continue
+		}
+		name, value, ok := strings.Cut(line, ":")
+		if !ok {
+			last = ""
+			continue
+		}
+		if section == nil {
+			section = make(map[string]string)
+			if m.Main == nil {
+				m.Main = section
+			} else {
+				m.Entries = append(m.Entries, section)
+			}
+		}
+		last = name
+		section[name] = strings.TrimPrefix(value, " ")
+	}
+	return m
+}
+
+// digestedEntries returns the sorted names of per-entry sections that
+// carry a digest attribute (e.g. "SHA-256-Digest"), i.e. signed entries.
+func (m *manifest) digestedEntries() []string {
+	var names []string
+	for _, sec := range m.Entries {
+		name, ok := sec["Name"]
+		if !ok {
+			continue
+		}
+		for attr := range sec {
+			if strings.HasSuffix(strings.ToUpper(attr), "-DIGEST") {
+				names = append(names, name)
+				break
+			}
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// isSignedJar reports whether the archive has a signature file
+// (META-INF/*.SF) together with a signature block (.RSA, .DSA or .EC).
+// The signature itself is not verified.
+func isSignedJar(r *zip.Reader) bool {
+	var hasSF, hasBlock bool
+	for _, f := range r.File {
+		dir, name := path.Split(f.Name)
+		if !strings.EqualFold(dir, "META-INF/") {
+			continue
+		}
+		switch strings.ToUpper(path.Ext(name)) {
+		case ".SF":
+			hasSF = true
+		case ".RSA", ".DSA", ".EC":
+			hasBlock = true
+		}
+	}
+	return hasSF && hasBlock
+}
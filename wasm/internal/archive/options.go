@@ -0,0 +1,259 @@
+package archive
+
+import (
+	"archive/zip"
+	"crypto/sha256"
+	"encoding/hex"
+	"path"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// Options tunes how archive entries are parsed. The zero value keeps the
+// default behavior.
+type Options struct {
+	// PreviewBytes, when > 0, keeps only the first PreviewBytes bytes of
+	// each text entry in Content and marks longer entries Truncated. Files
+	// over MaxFileContentSize then get a preview instead of no content.
+	PreviewBytes int
+
+	// PreviewLines, when > 0, keeps only the first PreviewLines lines of
+	// each text entry in Content (after any PreviewBytes cut) and marks
+	// longer entries Truncated. Unlike PreviewBytes it does not make
+	// files over MaxFileContentSize readable.
+	PreviewLines int
+
+	// PathRegex, when set, limits the result to entries whose path it
+	// matches. Filtering happens before any content is read.
+	PathRegex *regexp.Regexp
+
+	// Include and Exclude filter entry paths like PathRegex, by glob:
+	// an entry must match one of the Include patterns (any, when empty)
+	// and none of the Exclude ones. Patterns without "/" match the base
+	// name ("*.md"); see matchGlob.
+	Include, Exclude []string
+
+	// Method, when set, limits a zip's entries to those stored with that
+	// compression method: MethodStore or MethodDeflate. Ignored for tar.
+	Method string
+
+	// JavaPackage records the declared package of .java text entries
+	// in ParsedFile.JavaPackage.
+	JavaPackage bool
+
+	// BinaryAsBase64 puts the base64-encoded bytes of binary entries into
+	// Content (IsBinary stays true). Only entries that were read in full,
+	// i.e. not skipped for size or cut short by PreviewBytes, get content.
+	// Zip .class files keep using RawBase64 instead.
+	BinaryAsBase64 bool
+
+	// ResolveSymlinks fills symlink entries with the content of the entry
+	// they point to, when that is in the archive (ParsedFile.Resolved).
+	// Not applied by WalkZipBytes, which never holds the full entry list.
+	ResolveSymlinks bool
+
+	// ContentBudget caps the uncompressed bytes ParseZipBytes reads for
+	// content across all entries; 0 means DefaultContentBudget. Once an
+	// entry would exceed it, that and every later entry is Skipped.
+	ContentBudget int64
+
+	// SkipDirs leaves directory entries out of ParseResult.Files; they
+	// are still counted in DirCount.
+	SkipDirs bool
+
+	// HashContent sets ParsedFile.Sha256 for entries read in full and
+	// groups identical ones in ParseResult.DuplicateContent. Entries
+	// skipped for size or cut short by PreviewBytes are not hashed.
+	HashContent bool
+
+	// Verbose logs skipped and truncated content and similar events in
+	// ParseResult.Warnings.
+	Verbose bool
+
+	// Strict fails the parse on entries it cannot fully represent, which
+	// are otherwise listed as best it can: tar entries of an unknown type
+	// and encrypted zip entries. The error names the entry.
+	Strict bool
+
+	// NormalizePaths cleans entry paths with path.Clean, so "./foo",
+	// "foo" and "dir/../foo" all become "foo"; directories keep their
+	// trailing slash. A changed path's original is kept in
+	// ParsedFile.OriginalPath. Filters still see the original path.
+	NormalizePaths bool
+
+	// DirSizeDepth is how many leading path components ParseResult.DirSizes
+	// groups files by; 0 means 1, the top-level directories.
+	DirSizeDepth int
+
+	// OnProgress, when set, is called while a tar or tgz is parsed with
+	// the number of entries read so far and the sum of their sizes: at
+	// most once per progressInterval, and once more at the end. An error
+	// from it stops the parse and is returned.
+	OnProgress func(entries int, bytes int64) error
+
+	// keepFiltered makes tar parsing return the entries that fail the
+	// path filters too, without content; see ForLayer.
+	keepFiltered bool
+
+	// rawData makes tar walking leave the data of regular files unread
+	// for its callback instead of reading Content; see walkEntries.
+	rawData bool
+}
+
+// Includes reports whether the entry at path p passes the path filters:
+// PathRegex, Include and Exclude.
+func (o Options) Includes(p string) bool {
+	if o.PathRegex != nil && !o.PathRegex.MatchString(p) {
+		return false
+	}
+	return keepPath(p, o.Include, o.Exclude)
+}
+
+// dirKey returns the ParseResult.DirSizes key of the file at p: its
+// first DirSizeDepth directories (fewer if it is not nested that deep),
+// or "" for a file at the archive root.
+func (o Options) dirKey(p string) string {
+	depth := max(o.DirSizeDepth, 1)
+	dir := path.Dir(strings.TrimPrefix(p, "./"))
+	if dir == "." || dir == "/" {
+		return ""
+	}
+	parts := strings.SplitN(strings.TrimPrefix(dir, "/"), "/", depth+1)
+	return strings.Join(parts[:min(depth, len(parts))], "/")
+}
+
+// Compression method names accepted by Options.Method.
+const (
+	MethodStore   = "store"
+	MethodDeflate = "deflate"
+)
+
+// includesMethod reports whether a zip entry compressed with method
+// passes Options.Method.
+func (o Options) includesMethod(method uint16) bool {
+	switch o.Method {
+	case MethodStore:
+		return method == zip.Store
+	case MethodDeflate:
+		return method == zip.Deflate
+	}
+	return true
+}
+
+// annotate fills in the hints derived from an entry's name and (possibly
+// truncated) Content once the content has been read.
+func (o Options) annotate(entry *ParsedFile) {
+	entry.Language = detectLanguage(entry.Path, entry.Content)
+	if entry.Content != "" && !entry.IsBinary {
+		entry.IsMinified = isMinified(entry.Path, entry.Content)
+		entry.IsGenerated = isGenerated(entry.Content)
+		entry.LineEnding = lineEnding(entry.Content)
+		entry.IndentStyle = indentStyle(entry.Content)
+	}
+	if o.JavaPackage && entry.Content != "" &&
+		strings.HasSuffix(strings.ToLower(entry.Path), ".java") {
+		entry.JavaPackage = javaPackage(entry.Content)
+	}
+}
+
+// normalizePath cleans entry.Path under NormalizePaths. The archive
+// root ("./") is left alone.
+func (o Options) normalizePath(entry *ParsedFile) {
+	if !o.NormalizePaths {
+		return
+	}
+	p := path.Clean(entry.Path)
+	if p == "." {
+		return
+	}
+	if strings.HasSuffix(entry.Path, "/") && p != "/" {
+		p += "/"
+	}
+	if p != entry.Path {
+		entry.OriginalPath = entry.Path
+		entry.Path = p
+	}
+}
+
+// hash sets entry.Sha256 from its full content buf under HashContent.
+func (o Options) hash(entry *ParsedFile, buf []byte) {
+	if o.HashContent {
+		sum := sha256.Sum256(buf)
+		entry.Sha256 = hex.EncodeToString(sum[:])
+	}
+}
+
+// contentBudget tracks the uncompressed bytes left for entry content.
+// A nil *contentBudget is unlimited.
+type contentBudget struct {
+	left     int64
+	exceeded bool
+}
+
+// newContentBudget returns the budget for one archive under o.
+func (o Options) newContentBudget() *contentBudget {
+	if o.ContentBudget > 0 {
+		return &contentBudget{left: o.ContentBudget}
+	}
+	return &contentBudget{left: DefaultContentBudget}
+}
+
+// take reserves n bytes, reporting false (for good) once the budget is
+// exhausted.
+func (b *contentBudget) take(n int64) bool {
+	if b == nil {
+		return true
+	}
+	if b.exceeded || n > b.left {
+		b.exceeded = true
+		return false
+	}
+	b.left -= n
+	return true
+}
+
+// readLimit returns how many bytes of a size-byte entry should be read
+// for content extraction, and whether that cuts the entry short. A
+// negative limit means the entry is too large to read at all.
+func (o Options) readLimit(size int64) (int64, bool) {
+	if o.PreviewBytes > 0 && size > int64(o.PreviewBytes) {
+		// Read at least BinaryCheckSize so binary detection sees
+		// the same bytes as for a full read.
+		n := int64(max(o.PreviewBytes, BinaryCheckSize))
+		return min(n, size), true
+	}
+	if size > MaxFileContentSize {
+		return -1, false
+	}
+	return size, false
+}
+
+// previewText converts text entry bytes to Content, cutting them to
+// PreviewBytes without splitting a multi-byte UTF-8 sequence and then
+// to PreviewLines lines. truncated says buf is already short of the
+// entry; the result reports whether Content is.
+func (o Options) previewText(buf []byte, truncated bool) (string, bool) {
+	if o.PreviewBytes > 0 && len(buf) > o.PreviewBytes {
+		n := o.PreviewBytes
+		for n > 0 && !utf8.RuneStart(buf[n]) {
+			n--
+		}
+		buf, truncated = buf[:n], true
+	}
+	if o.PreviewLines > 0 {
+		lines := 0
+		for i, c := range buf {
+			if c != '\n' {
+				continue
+			}
+			if lines++; lines == o.PreviewLines {
+				if i+1 < len(buf) {
+					buf, truncated = buf[:i+1], true
+				}
+				break
+			}
+		}
+	}
+	return string(buf), truncated
+}
@@ -0,0 +1,74 @@
+package archive
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+)
+
+var (
+	ErrUnknownFormat = errors.New("unrecognized archive format")
+	ErrTooLarge      = errors.New("archive too large (>100MB)")
+)
+
+// ParseBytes sniffs the archive format of an in-memory archive (see
+// DetectBytes) and dispatches to the matching parser.
+func ParseBytes(data []byte, opts Options) (*ParseResult, error) {
+	var (
+		result *ParseResult
+		err    error
+	)
+	kind := DetectBytes(data)
+	switch kind {
+	case TypeZip:
+		result, err = ParseZipBytes(data, opts)
+	case TypeTgz:
+		result, err = ParseTgzBytes(data, opts)
+	case TypeTar:
+		result, err = ParseTar(bytes.NewReader(data), opts)
+	default:
+		return nil, ErrUnknownFormat
+	}
+	if result == nil {
+		return nil, err
+	}
+	result.ArchiveType = kind
+	return result, err // a *TruncatedArchiveError comes with a partial result
+}
+
+// ParseReader is the streaming counterpart of ParseBytes. tgz and tar are
+// parsed as they stream in; zip needs random access to its central
+// directory, so it is buffered (up to MaxTotalSize) before parsing.
+func ParseReader(r io.Reader, opts Options) (*ParseResult, error) {
+	br := bufio.NewReaderSize(r, detectSize)
+	head, err := br.Peek(detectSize)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+
+	var result *ParseResult
+	kind := Detect(head)
+	switch kind {
+	case TypeZip:
+		var data []byte
+		data, err = io.ReadAll(io.LimitReader(br, MaxTotalSize+1))
+		if err == nil && len(data) > MaxTotalSize {
+			err = ErrTooLarge
+		}
+		if err == nil {
+			result, err = ParseZipBytes(data, opts)
+		}
+	case TypeTgz:
+		result, err = ParseTgzStream(br, opts)
+	case TypeTar:
+		result, err = ParseTar(br, opts)
+	default:
+		return nil, ErrUnknownFormat
+	}
+	if result == nil {
+		return nil, err
+	}
+	result.ArchiveType = kind
+	return result, err // a *TruncatedArchiveError comes with a partial result
+}
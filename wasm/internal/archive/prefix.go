@@ -0,0 +1,70 @@
+package archive
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/binary"
+)
+
+// prefixMagics identifies what sits in front of a zip's first entry.
+// Self-extracting archives and launcher-stub jars are the usual cases;
+// anything else makes the file a polyglot worth a closer look.
+var prefixMagics = []struct {
+	magic  []byte
+	format string
+}{
+	{[]byte("MZ"), "pe"},
+	{[]byte("\x7fELF"), "elf"},
+	{[]byte{0xcf, 0xfa, 0xed, 0xfe}, "mach-o"},
+	{[]byte{0xca, 0xfe, 0xba, 0xbe}, "mach-o"}, // fat binary (same magic as .class)
+	{[]byte("#!"), "script"},
+	{[]byte("%PDF"), "pdf"},
+	{[]byte("\x89PNG"), "png"},
+	{[]byte("GIF8"), "gif"},
+	{[]byte{0xff, 0xd8, 0xff}, "jpeg"},
+	{[]byte("PK\x03\x04"), "zip"}, // another zip concatenated in front
+}
+
+// zipPrefix returns the number of bytes before the first local file
+// header of the zip in data, and the sniffed format of those bytes ("" if
+// there are none or they are not recognised).
+func zipPrefix(data []byte, r *zip.Reader) (int64, string) {
+	// The entry whose data comes first has the first local header.
+	var first *zip.File
+	var firstData int64 = -1
+	for _, f := range r.File {
+		off, err := f.DataOffset()
+		if err != nil {
+			continue
+		}
+		if firstData < 0 || off < firstData {
+			first, firstData = f, off
+		}
+	}
+	if first == nil || firstData > int64(len(data)) {
+		return 0, ""
+	}
+
+	// Its header is the last signature at least 30 bytes before the
+	// data; check that its name and extra lengths lead to firstData.
+	end := firstData - 30 - int64(len(first.Name)) + int64(len(zipMagic))
+	if end < int64(len(zipMagic)) || end > int64(len(data)) {
+		return 0, ""
+	}
+	start := int64(bytes.LastIndex(data[:end], zipMagic))
+	if start <= 0 {
+		return 0, ""
+	}
+	nameLen := int64(binary.LittleEndian.Uint16(data[start+26:]))
+	extraLen := int64(binary.LittleEndian.Uint16(data[start+28:]))
+	if start+30+nameLen+extraLen != firstData {
+		return 0, ""
+	}
+
+	for _, m := range prefixMagics {
+		if bytes.HasPrefix(data, m.magic) {
+			return start, m.format
+		}
+	}
+	return start, ""
+}
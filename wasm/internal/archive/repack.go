@@ -0,0 +1,179 @@
+package archive
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"io/fs"
+	"path"
+	"strings"
+	"time"
+)
+
+// RepackTgz copies the entries of a .tgz that pass the path filters of
+// opts (PathRegex, Include and Exclude) into a new .tgz. Headers are
+// copied as-is, so mode, modtime, owner and typeflag are preserved;
+// sparse files are written out as regular files.
+func RepackTgz(data []byte, opts Options) ([]byte, error) {
+	if err := checkGlobs(opts.Include, opts.Exclude); err != nil {
+		return nil, err
+	}
+
+	gz, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer gz.Close()
+	tr := tar.NewReader(gz)
+
+	var out bytes.Buffer
+	gw := gzip.NewWriter(&out)
+	tw := tar.NewWriter(gw)
+
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if hdr.Name == "" || !opts.Includes(hdr.Name) {
+			continue
+		}
+
+		if TarIsSparse(hdr) {
+			hdr.Typeflag = tar.TypeReg
+			for k := range hdr.PAXRecords {
+				if strings.HasPrefix(k, "GNU.sparse.") {
+					delete(hdr.PAXRecords, k)
+				}
+			}
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return nil, err
+		}
+		if TarIsRegular(hdr) {
+			if _, err := io.Copy(tw, tr); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return nil, err
+	}
+	if err := gw.Close(); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+// keepPath applies the Include/Exclude rules of Options to p: it must
+// match some include pattern (or include is empty) and no exclude one.
+func keepPath(p string, include, exclude []string) bool {
+	for _, pat := range exclude {
+		if matchGlob(pat, p) {
+			return false
+		}
+	}
+	if len(include) == 0 {
+		return true
+	}
+	for _, pat := range include {
+		if matchGlob(pat, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkGlobs reports the first malformed pattern in the given lists.
+func checkGlobs(lists ...[]string) error {
+	for _, pats := range lists {
+		for _, p := range pats {
+			if _, err := path.Match(p, ""); err != nil {
+				return errors.New("invalid pattern " + p + ": " + err.Error())
+			}
+		}
+	}
+	return nil
+}
+
+// ZipToTgz converts a zip archive into an equivalent .tgz. Paths, sizes,
+// permission bits and modification times carry over; zip symlinks become
+// tar symlinks. Only entries passing the path filters of opts are kept.
+func ZipToTgz(data []byte, opts Options) ([]byte, error) {
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, err
+	}
+
+	var out bytes.Buffer
+	gw := gzip.NewWriter(&out)
+	tw := tar.NewWriter(gw)
+
+	for _, f := range zr.File {
+		if !opts.Includes(f.Name) {
+			continue
+		}
+		fi := f.FileInfo()
+		var link string
+		if fi.Mode()&fs.ModeSymlink != 0 {
+			target, err := readZipFile(f)
+			if err != nil {
+				return nil, err
+			}
+			link = string(target)
+		}
+
+		hdr, err := tar.FileInfoHeader(fi, link)
+		if err != nil {
+			return nil, err
+		}
+		hdr.Name = f.Name
+		if fi.IsDir() && !strings.HasSuffix(hdr.Name, "/") {
+			hdr.Name += "/"
+		}
+		if hdr.ModTime.IsZero() {
+			hdr.ModTime = time.Unix(0, 0)
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return nil, err
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+
+		rc, err := f.Open()
+		if err != nil {
+			return nil, err
+		}
+		_, err = io.Copy(tw, rc)
+		rc.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return nil, err
+	}
+	if err := gw.Close(); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+// readZipFile reads a whole (small) zip entry.
+func readZipFile(f *zip.File) ([]byte, error) {
+	rc, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	return io.ReadAll(io.LimitReader(rc, MaxFileContentSize))
+}
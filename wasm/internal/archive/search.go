@@ -0,0 +1,133 @@
+package archive
+
+import (
+	"errors"
+	"path"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	defaultMaxMatches = 1000 // cap on hits when SearchOptions.MaxMatches is 0
+	maxHitTextLen     = 240  // longer matching lines are clipped in SearchHit.Text
+)
+
+// SearchOptions controls SearchBytes.
+type SearchOptions struct {
+	Regex           bool   // treat the query as a regular expression
+	CaseInsensitive bool   // match regardless of case
+	PathGlob        string // only search paths matching this path.Match pattern
+	MaxMatches      int    // stop after this many hits (0 = defaultMaxMatches)
+}
+
+// SearchHit is one matching line in a file.
+type SearchHit struct {
+	Line int    `json:"line"` // 1-based line number
+	Text string `json:"text"`
+}
+
+// SearchMatch groups the hits found in a single file.
+type SearchMatch struct {
+	Path string      `json:"path"`
+	Hits []SearchHit `json:"hits"`
+}
+
+// SearchResult is returned by __wasm_searchArchive.
+type SearchResult struct {
+	Matches []SearchMatch `json:"matches"`
+	// LimitReached is set when the search stopped at MaxMatches hits.
+	LimitReached bool `json:"limitReached,omitempty"`
+}
+
+// SearchBytes parses an archive of any supported format and greps the
+// content of its text files line by line. Binary and oversized files
+// (which carry no Content) are skipped.
+func SearchBytes(data []byte, query string, opts SearchOptions) (*SearchResult, error) {
+	if query == "" {
+		return nil, errors.New("empty search query")
+	}
+
+	pattern := query
+	if !opts.Regex {
+		pattern = regexp.QuoteMeta(query)
+	}
+	if opts.CaseInsensitive {
+		pattern = "(?i)" + pattern
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+	if opts.PathGlob != "" {
+		if _, err := path.Match(opts.PathGlob, ""); err != nil {
+			return nil, err
+		}
+	}
+
+	maxMatches := opts.MaxMatches
+	if maxMatches <= 0 {
+		maxMatches = defaultMaxMatches
+	}
+
+	parsed, err := ParseBytes(data, Options{})
+	if err != nil {
+		return nil, err
+	}
+
+	result := &SearchResult{Matches: make([]SearchMatch, 0)}
+	total := 0
+	for _, f := range parsed.Files {
+		if f.IsDir || f.IsBinary || f.Content == "" {
+			continue
+		}
+		if opts.PathGlob != "" && !matchGlob(opts.PathGlob, f.Path) {
+			continue
+		}
+
+		var hits []SearchHit
+		for i, line := range strings.Split(f.Content, "\n") {
+			if !re.MatchString(line) {
+				continue
+			}
+			if total == maxMatches {
+				result.LimitReached = true
+				break
+			}
+			hits = append(hits, SearchHit{Line: i + 1, Text: clipLine(line)})
+			total++
+		}
+		if len(hits) > 0 {
+			result.Matches = append(result.Matches, SearchMatch{Path: f.Path, Hits: hits})
+		}
+		if result.LimitReached {
+			break
+		}
+	}
+
+	return result, nil
+}
+
+// matchGlob matches p against the full entry path, or against just the
+// base name when the pattern has no slash (so "*.go" finds nested files).
+func matchGlob(pattern, p string) bool {
+	if !strings.Contains(pattern, "/") {
+		p = path.Base(p)
+	}
+	ok, _ := path.Match(pattern, p)
+	return ok
+}
+
+// clipLine trims a trailing CR and shortens very long lines (minified
+// code) to maxHitTextLen bytes without splitting a UTF-8 sequence.
+func clipLine(line string) string {
+	line = strings.TrimSuffix(line, "\r")
+	if len(line) <= maxHitTextLen {
+		return line
+	}
+	n := maxHitTextLen
+	for n > 0 && !utf8.RuneStart(line[n]) {
+		n--
+	}
+	return line[:n] + "…"
+}
@@ -0,0 +1,100 @@
+package archive
+
+import (
+	"io"
+	"path"
+	"strings"
+)
+
+// SummaryFile identifies a single entry in a Summary.
+type SummaryFile struct {
+	Path string `json:"path"`
+	Size int64  `json:"size"`
+}
+
+// Summary holds the aggregate statistics returned by __wasm_archiveSummary.
+type Summary struct {
+	ArchiveType       string `json:"archiveType"`
+	FileCount         int    `json:"fileCount"`
+	DirCount          int    `json:"dirCount"`
+	TotalUncompressed int64  `json:"totalUncompressed"`
+
+	// TotalCompressed is the sum of the entries' compressed sizes for zip.
+	// tgz compresses the tar stream as a whole, so for tgz and tar it is
+	// the size of the archive itself.
+	TotalCompressed int64 `json:"totalCompressed"`
+
+	BinaryCount int          `json:"binaryCount"`
+	TextCount   int          `json:"textCount"`
+	LargestFile *SummaryFile `json:"largestFile,omitempty"`
+
+	// ByExtension counts files per lower-cased extension (".js"); files
+	// without one are counted under "".
+	ByExtension map[string]int `json:"byExtension"`
+}
+
+// SummarizeBytes computes a Summary of an in-memory archive of any
+// supported format in a single pass. Only the first BinaryCheckSize bytes
+// of each file are read, to tell binary from text. Entries failing the
+// path filters of opts are left out of every count.
+func SummarizeBytes(data []byte, opts Options) (*Summary, error) {
+	s := &Summary{ByExtension: make(map[string]int)}
+
+	kind, err := walkEntries(data, func(e walkEntry, r io.Reader) error {
+		switch {
+		case !opts.Includes(e.Name):
+			// Filtered out
+		case e.IsDir:
+			s.DirCount++
+		case e.Regular:
+			binary, err := sniffBinary(e.Name, r)
+			if err != nil {
+				return err
+			}
+			s.addFile(e.Name, e.Size, binary)
+			s.TotalCompressed += e.CompressedSize
+		default:
+			// Links and other special tar entries count as files but
+			// are neither text nor binary.
+			s.FileCount++
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.ArchiveType = kind
+	if kind != TypeZip {
+		s.TotalCompressed = int64(len(data))
+	}
+	return s, nil
+}
+
+// addFile records a regular file.
+func (s *Summary) addFile(p string, size int64, binary bool) {
+	s.FileCount++
+	s.TotalUncompressed += size
+	if binary {
+		s.BinaryCount++
+	} else {
+		s.TextCount++
+	}
+	if s.LargestFile == nil || size > s.LargestFile.Size {
+		s.LargestFile = &SummaryFile{Path: p, Size: size}
+	}
+	s.ByExtension[strings.ToLower(path.Ext(p))]++
+}
+
+// sniffBinary reads up to BinaryCheckSize bytes from r and reports
+// whether the file name looks binary (see IsBinaryFile). Text with a
+// UTF-16 byte-order mark counts as text.
+func sniffBinary(name string, r io.Reader) (bool, error) {
+	buf := make([]byte, BinaryCheckSize)
+	n, err := io.ReadFull(r, buf)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return false, err
+	}
+	text, _ := DecodeBOM(buf[:n])
+	return IsBinaryFile(name, text), nil
+}
@@ -0,0 +1,60 @@
+package archive
+
+import (
+	"path"
+	"strings"
+)
+
+// resolveSymlinks copies the content of each symlink's target entry into
+// the symlink (see Options.ResolveSymlinks). Chains of links are
+// followed; links that are absolute, escape the archive, dangle, point
+// at a directory or form a cycle are left unresolved.
+func (r *ParseResult) resolveSymlinks() {
+	index := make(map[string]int, len(r.Files))
+	for i, f := range r.Files {
+		index[path.Clean(f.Path)] = i
+	}
+
+	for i := range r.Files {
+		f := &r.Files[i]
+		if f.LinkTarget == "" {
+			continue
+		}
+		t, ok := r.symlinkTarget(i, index)
+		if !ok {
+			continue
+		}
+		target := r.Files[t]
+		f.Content = target.Content
+		f.IsBinary = target.IsBinary
+		f.Truncated = target.Truncated
+		f.Resolved = true
+	}
+}
+
+// symlinkTarget follows the link at r.Files[i] to the non-link entry it
+// ends up at.
+func (r *ParseResult) symlinkTarget(i int, index map[string]int) (int, bool) {
+	seen := make(map[int]bool)
+	for r.Files[i].LinkTarget != "" {
+		if seen[i] {
+			return 0, false // cycle
+		}
+		seen[i] = true
+
+		link := r.Files[i].LinkTarget
+		if path.IsAbs(link) {
+			return 0, false
+		}
+		p := path.Join(path.Dir(path.Clean(r.Files[i].Path)), link)
+		if p == ".." || strings.HasPrefix(p, "../") {
+			return 0, false
+		}
+		j, ok := index[p]
+		if !ok {
+			return 0, false
+		}
+		i = j
+	}
+	return i, !r.Files[i].IsDir
+}
@@ -0,0 +1,341 @@
+package archive
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"io"
+	"strings"
+	"time"
+)
+
+// ParseTgzBytes decompresses a .tgz archive from an in-memory byte slice.
+func ParseTgzBytes(data []byte, opts Options) (*ParseResult, error) {
+	return ParseTgzStream(bytes.NewReader(data), opts)
+}
+
+// ParseTgzStream decompresses a .tgz archive from a streaming reader.
+func ParseTgzStream(r io.Reader, opts Options) (*ParseResult, error) {
+	h := sha256.New()
+	var compressed byteCounter
+	tee := io.TeeReader(r, io.MultiWriter(h, &compressed))
+	gz, err := gzip.NewReader(tee)
+	if err != nil {
+		return nil, err
+	}
+	defer gz.Close()
+	// Take the header before reading: the result describes the first
+	// member, whatever later members of a multistream gzip carry.
+	header := gz.Header
+
+	result, err := parseTarEntries(gz, opts)
+	if err != nil {
+		if result != nil { // truncated: the hash and sizes would be wrong
+			fillGzipHeader(result, header)
+		}
+		return result, err
+	}
+
+	// The tar reader stops at the end-of-archive marker; hash the rest.
+	if _, err := io.Copy(io.Discard, tee); err != nil {
+		return nil, err
+	}
+	result.Sha256 = hex.EncodeToString(h.Sum(nil))
+
+	result.Compression = &CompressionStats{
+		CompressedSize:   int64(compressed),
+		UncompressedSize: result.dataSize,
+	}
+	if compressed > 0 {
+		result.Compression.Ratio = float64(result.dataSize) / float64(compressed)
+	}
+
+	fillGzipHeader(result, header)
+	return result, nil
+}
+
+// fillGzipHeader copies the gzip header fields into result.
+func fillGzipHeader(result *ParseResult, header gzip.Header) {
+	result.GzipName = header.Name
+	result.GzipComment = header.Comment
+	if !header.ModTime.IsZero() {
+		result.GzipModTime = header.ModTime.UTC().Format(time.RFC3339)
+	}
+}
+
+// ParseTar extracts all entries from an uncompressed tar stream.
+func ParseTar(r io.Reader, opts Options) (*ParseResult, error) {
+	h := sha256.New()
+	tee := io.TeeReader(r, h)
+	result, err := parseTarEntries(tee, opts)
+	if err != nil {
+		return result, err
+	}
+	if _, err := io.Copy(io.Discard, tee); err != nil {
+		return nil, err
+	}
+	result.Sha256 = hex.EncodeToString(h.Sum(nil))
+	return result, nil
+}
+
+// byteCounter is an io.Writer that only counts what is written to it.
+type byteCounter int64
+
+func (c *byteCounter) Write(p []byte) (int, error) {
+	*c += byteCounter(len(p))
+	return len(p), nil
+}
+
+// TruncatedArchiveError reports a tgz or tar that ends in the middle of
+// an entry. The parse functions return it together with a partial
+// ParseResult holding the Entries files read before the cut.
+type TruncatedArchiveError struct {
+	Entries int
+	Err     error // usually io.ErrUnexpectedEOF
+}
+
+func (e *TruncatedArchiveError) Error() string {
+	return "archive is truncated after " + Itoa(e.Entries) + " entries: " + e.Err.Error()
+}
+
+func (e *TruncatedArchiveError) Unwrap() error { return e.Err }
+
+// tarBlockSize is the unit tar headers and entry data are padded to.
+const tarBlockSize = 512
+
+// progressInterval throttles Options.OnProgress, which usually crosses
+// into JS.
+const progressInterval = 100 * time.Millisecond
+
+// parseTarEntries does the work of ParseTar, without hashing r. If r
+// ends mid-entry it returns what it read with a *TruncatedArchiveError.
+func parseTarEntries(r io.Reader, opts Options) (*ParseResult, error) {
+	result := &ParseResult{
+		SchemaVersion: SchemaVersion,
+		Files:         make([]ParsedFile, 0, 64),
+	}
+
+	var totals tarTotals
+	cut := walkTarEntries(r, opts, &totals, func(entry ParsedFile, _ io.Reader) error {
+		result.add(entry, opts)
+		return nil
+	})
+	if cut != nil && !errors.Is(cut, io.ErrUnexpectedEOF) {
+		return nil, cut
+	}
+	result.dataSize, result.BlockWaste = totals.dataSize, totals.blockWaste
+
+	if opts.ResolveSymlinks {
+		result.resolveSymlinks()
+	}
+	if opts.HashContent {
+		result.findDuplicates()
+	}
+	if cut != nil {
+		err := &TruncatedArchiveError{Entries: len(result.Files), Err: cut}
+		if opts.Verbose {
+			result.Warnings = append(result.Warnings, err.Error())
+		}
+		return result, err
+	}
+	return result, nil
+}
+
+// tarTotals accumulates what walkTarEntries sees besides the entries.
+type tarTotals struct {
+	dataSize   int64 // sum of regular file sizes
+	blockWaste int64 // see ParseResult.BlockWaste
+}
+
+// walkTarEntries reads the tar stream r and calls fn with each entry
+// (directories included) as it is read, so only one entry's content is
+// held at a time. Under opts.rawData, data streams a regular file's
+// unread content instead, for files passing the filters; it is nil
+// otherwise. It returns io.ErrUnexpectedEOF if r ends mid-entry, and the
+// first error from fn or opts.OnProgress, which stops the walk.
+func walkTarEntries(r io.Reader, opts Options, totals *tarTotals, fn func(entry ParsedFile, data io.Reader) error) (err error) {
+	tr := tar.NewReader(r)
+	var entries int
+	var lastProgress time.Time
+	progressFailed := false
+	defer func() {
+		if opts.OnProgress != nil && !progressFailed {
+			if perr := opts.OnProgress(entries, totals.dataSize); err == nil {
+				err = perr
+			}
+		}
+	}()
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if errors.Is(err, io.ErrUnexpectedEOF) {
+			return io.ErrUnexpectedEOF
+		}
+		if err != nil {
+			return err
+		}
+		if TarIsRegular(hdr) {
+			totals.dataSize += hdr.Size
+			totals.blockWaste += (tarBlockSize - hdr.Size%tarBlockSize) % tarBlockSize
+		}
+		entries++
+		if opts.OnProgress != nil && time.Since(lastProgress) >= progressInterval {
+			if err := opts.OnProgress(entries, totals.dataSize); err != nil {
+				progressFailed = true
+				return err
+			}
+			lastProgress = time.Now()
+		}
+		filtered := !opts.Includes(hdr.Name)
+		if hdr.Name == "" || filtered && !opts.keepFiltered {
+			continue // tar.Reader skips the unread data on Next
+		}
+
+		entry := ParsedFile{
+			Path:      hdr.Name,
+			Size:      hdr.Size,
+			IsDir:     TarIsDir(hdr),
+			EntryType: TarEntryType(hdr),
+		}
+		if hdr.Typeflag == tar.TypeSymlink {
+			entry.LinkTarget = hdr.Linkname
+		}
+		opts.normalizePath(&entry)
+		if opts.Strict && !filtered && entry.EntryType == "other" {
+			return errors.New("strict: unsupported typeflag " + Itoa(int(hdr.Typeflag)) + " of entry " + hdr.Name)
+		}
+		if !entry.IsDir {
+			markWhiteout(&entry)
+		}
+
+		regular := !entry.IsDir && TarIsRegular(hdr) && !filtered
+		var data io.Reader
+		if regular && opts.rawData {
+			data = tr
+		} else if regular {
+			limit, truncated := opts.readLimit(hdr.Size)
+			if limit < 0 {
+				entry.IsBinary = true
+				entry.Skipped = true
+				io.Copy(io.Discard, tr)
+			} else {
+				buf := make([]byte, limit)
+				if _, err := io.ReadFull(tr, buf); err != nil {
+					if errors.Is(err, io.ErrUnexpectedEOF) || err == io.EOF {
+						return io.ErrUnexpectedEOF
+					}
+					return err
+				}
+				if !truncated {
+					opts.hash(&entry, buf)
+				}
+				text, charset := DecodeBOM(buf)
+				if IsBinaryFile(entry.Path, text) {
+					entry.IsBinary = true
+					if opts.BinaryAsBase64 && !truncated {
+						entry.Content = base64.StdEncoding.EncodeToString(buf)
+					}
+				} else {
+					entry.Content, entry.Truncated = opts.previewText(text, truncated)
+					entry.Charset = charset
+				}
+			}
+			opts.annotate(&entry)
+		}
+
+		if err := fn(entry, data); err != nil {
+			return err
+		}
+	}
+}
+
+// WalkTgzStream decompresses a .tgz from r like ParseTgzStream but hands
+// each entry to fn as soon as it is read instead of accumulating them:
+// memory use is bounded by one entry's content however large the
+// archive. Directories are left out under SkipDirs; ResolveSymlinks and
+// the duplicate grouping of HashContent, which need every entry, do not
+// apply. A non-nil error from fn stops the walk and is returned; an
+// archive that ends mid-entry gives a *TruncatedArchiveError counting
+// the entries delivered.
+func WalkTgzStream(r io.Reader, opts Options, fn func(ParsedFile) error) error {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return err
+	}
+	defer gz.Close()
+	return WalkTar(gz, opts, fn)
+}
+
+// WalkTar is WalkTgzStream for an uncompressed tar stream.
+func WalkTar(r io.Reader, opts Options, fn func(ParsedFile) error) error {
+	delivered := 0
+	err := walkTarEntries(r, opts, &tarTotals{}, func(entry ParsedFile, _ io.Reader) error {
+		if entry.IsDir && opts.SkipDirs {
+			return nil
+		}
+		delivered++
+		return fn(entry)
+	})
+	if errors.Is(err, io.ErrUnexpectedEOF) {
+		return &TruncatedArchiveError{Entries: delivered, Err: err}
+	}
+	return err
+}
+
+// TarIsDir reports whether hdr describes a directory. Besides TypeDir,
+// some archivers write directories as entries whose name ends in "/".
+func TarIsDir(hdr *tar.Header) bool {
+	return hdr.Typeflag == tar.TypeDir || strings.HasSuffix(hdr.Name, "/")
+}
+
+// TarIsRegular reports whether hdr is a file with data. That includes
+// old-style GNU sparse files, which tar.Reader expands to their logical
+// content.
+func TarIsRegular(hdr *tar.Header) bool {
+	return hdr.Typeflag == tar.TypeReg || hdr.Typeflag == tar.TypeGNUSparse
+}
+
+// TarIsSparse reports whether hdr is a GNU sparse file, either old-style
+// (TypeGNUSparse) or PAX (GNU.sparse.* records). The data stored in the
+// archive is smaller than hdr.Size, so it is not one contiguous range.
+func TarIsSparse(hdr *tar.Header) bool {
+	if hdr.Typeflag == tar.TypeGNUSparse {
+		return true
+	}
+	for k := range hdr.PAXRecords {
+		if strings.HasPrefix(k, "GNU.sparse.") {
+			return true
+		}
+	}
+	return false
+}
+
+// TarEntryType names the kind of entry hdr describes: "regular", "dir",
+// "symlink", "hardlink", "char", "block", "fifo", or "other" for
+// typeflags without a file system counterpart.
+func TarEntryType(hdr *tar.Header) string {
+	if TarIsDir(hdr) {
+		return "dir"
+	}
+	switch hdr.Typeflag {
+	case tar.TypeReg, tar.TypeGNUSparse, tar.TypeCont:
+		return "regular"
+	case tar.TypeSymlink:
+		return "symlink"
+	case tar.TypeLink:
+		return "hardlink"
+	case tar.TypeChar:
+		return "char"
+	case tar.TypeBlock:
+		return "block"
+	case tar.TypeFifo:
+		return "fifo"
+	}
+	return "other"
+}
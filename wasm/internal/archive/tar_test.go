@@ -0,0 +1,141 @@
+package archive
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"testing"
+	"time"
+)
+
+// rawTarHeader encodes a ustar header block by hand, for names
+// tar.Writer refuses to write.
+func rawTarHeader(name string, typeflag byte, size int) []byte {
+	b := make([]byte, tarBlockSize)
+	copy(b, name)
+	copy(b[100:], "0000644\x00")
+	copy(b[124:], fmt.Sprintf("%011o\x00", size))
+	copy(b[136:], "00000000000\x00")
+	b[156] = typeflag
+	copy(b[257:], "ustar\x0000")
+	copy(b[148:], "        ")
+	sum := 0
+	for _, c := range b {
+		sum += int(c)
+	}
+	copy(b[148:], fmt.Sprintf("%06o\x00 ", sum))
+	return b
+}
+
+// rawTar joins rawTarHeader entries, each followed by its data padded
+// to a block, and the end-of-archive marker.
+func rawTar(entries ...[]string) []byte {
+	var b []byte
+	for _, e := range entries {
+		name, typeflag, data := e[0], e[1][0], e[2]
+		b = append(b, rawTarHeader(name, typeflag, len(data))...)
+		b = append(b, data...)
+		b = append(b, make([]byte, (tarBlockSize-len(data)%tarBlockSize)%tarBlockSize)...)
+	}
+	return append(b, make([]byte, 2*tarBlockSize)...)
+}
+
+// walkAll collects the entries walkTarEntries delivers from data.
+func walkAll(t *testing.T, data []byte, opts Options) []ParsedFile {
+	t.Helper()
+	var files []ParsedFile
+	err := walkTarEntries(bytes.NewReader(data), opts, &tarTotals{}, func(e ParsedFile, _ io.Reader) error {
+		files = append(files, e)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return files
+}
+
+// An entry without a name is skipped together with its data, and a
+// regular entry whose name ends in "/" is a directory.
+func TestWalkTarNames(t *testing.T) {
+	data := rawTar(
+		[]string{"", "0", "orphaned data"},
+		[]string{"lib/", "0", ""},
+		[]string{"lib/a.txt", "0", "hello"},
+	)
+
+	files := walkAll(t, data, Options{})
+	if len(files) != 2 {
+		t.Fatalf("got %d entries %+v, want lib/ and lib/a.txt", len(files), files)
+	}
+	if d := files[0]; d.Path != "lib/" || !d.IsDir || d.EntryType != "dir" || d.Content != "" {
+		t.Errorf("trailing-slash entry = %+v, want a directory", d)
+	}
+	if f := files[1]; f.Path != "lib/a.txt" || f.IsDir || f.Content != "hello" {
+		t.Errorf("entry after the unnamed one = %+v", f)
+	}
+
+	var paths []string
+	err := WalkTar(bytes.NewReader(data), Options{SkipDirs: true}, func(e ParsedFile) error {
+		paths = append(paths, e.Path)
+		return nil
+	})
+	if err != nil || len(paths) != 1 || paths[0] != "lib/a.txt" {
+		t.Errorf("WalkTar with SkipDirs = %q, %v; want only lib/a.txt", paths, err)
+	}
+}
+
+// The gzip header reported is the first member's, even when the tar
+// continues in later members of a multistream gzip.
+func TestTgzMultistreamHeader(t *testing.T) {
+	data := rawTar([]string{"a.txt", "0", "first member"}, []string{"b.txt", "0", "second member"})
+	modTime := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	// Members split the tar after a.txt; the second one is cut short
+	// in b.txt's data for a truncated archive.
+	for _, end := range []int{len(data), 1540} {
+		var tgz bytes.Buffer
+		for i, part := range [][]byte{data[:1024], data[1024:end]} {
+			zw := gzip.NewWriter(&tgz)
+			zw.Name, zw.Comment, zw.ModTime = "member"+Itoa(i)+".tar", "comment "+Itoa(i), modTime.AddDate(i, 0, 0)
+			zw.Write(part)
+			zw.Close()
+		}
+
+		result, err := ParseTgzBytes(tgz.Bytes(), Options{})
+		if result == nil || (err != nil) != (end < len(data)) {
+			t.Fatalf("tar of %d bytes: %v", end, err)
+		}
+		if len(result.Files) == 0 || result.GzipName != "member0.tar" || result.GzipComment != "comment 0" ||
+			result.GzipModTime != "2024-05-01T12:00:00Z" {
+			t.Errorf("%d files, gzip name %q, comment %q, modtime %q; want member 0's (err %v)",
+				len(result.Files), result.GzipName, result.GzipComment, result.GzipModTime, err)
+		}
+	}
+}
+
+// An error from OnProgress stops the parse, and is not followed by the
+// final progress call.
+func TestTarProgressError(t *testing.T) {
+	data := rawTar([]string{"a.txt", "0", "one"}, []string{"b.txt", "0", "two"})
+
+	var calls [][2]int64
+	result, err := ParseTar(bytes.NewReader(data), Options{OnProgress: func(entries int, n int64) error {
+		calls = append(calls, [2]int64{int64(entries), n})
+		return nil
+	}})
+	if err != nil || len(result.Files) != 2 || calls[len(calls)-1] != [2]int64{2, 6} {
+		t.Errorf("progress calls %v, err %v; want a last call (2, 6)", calls, err)
+	}
+
+	stop := errors.New("onProgress threw: stop")
+	calls = nil
+	_, err = ParseTar(bytes.NewReader(data), Options{OnProgress: func(entries int, n int64) error {
+		calls = append(calls, [2]int64{int64(entries), n})
+		return stop
+	}})
+	if err != stop || len(calls) != 1 {
+		t.Errorf("failing callback: err %v after %d calls, want %v after 1", err, len(calls), stop)
+	}
+}
@@ -0,0 +1,57 @@
+package archive
+
+import "strings"
+
+// Line ending and indentation styles reported in ParsedFile.LineEnding
+// and IndentStyle.
+const (
+	StyleLF     = "lf"
+	StyleCRLF   = "crlf"
+	StyleSpaces = "spaces"
+	StyleTabs   = "tabs"
+	StyleMixed  = "mixed"
+)
+
+// lineEnding classifies the line breaks of content as StyleLF, StyleCRLF
+// or StyleMixed; "" if it has none.
+func lineEnding(content string) string {
+	crlf := strings.Count(content, "\r\n")
+	lf := strings.Count(content, "\n") - crlf
+	switch {
+	case crlf > 0 && lf > 0:
+		return StyleMixed
+	case crlf > 0:
+		return StyleCRLF
+	case lf > 0:
+		return StyleLF
+	}
+	return ""
+}
+
+// indentStyle classifies the indentation of content's lines by their
+// first character as StyleSpaces, StyleTabs or StyleMixed; "" if no
+// line is indented. Spaces aligning after a leading tab count as tabs.
+func indentStyle(content string) string {
+	spaces, tabs := false, false
+	for line := range strings.Lines(content) {
+		switch line[0] {
+		case ' ':
+			if strings.TrimLeft(line, " \r\n") != "" { // ignore blank lines
+				spaces = true
+			}
+		case '\t':
+			if strings.TrimLeft(line, "\t \r\n") != "" {
+				tabs = true
+			}
+		}
+	}
+	switch {
+	case spaces && tabs:
+		return StyleMixed
+	case spaces:
+		return StyleSpaces
+	case tabs:
+		return StyleTabs
+	}
+	return ""
+}
@@ -0,0 +1,80 @@
+package archive
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"io"
+)
+
+// walkEntry describes one archive entry handed to a walkEntries callback.
+type walkEntry struct {
+	Name           string
+	Size           int64
+	CompressedSize int64 // zip only
+	IsDir          bool
+	Regular        bool // a regular file whose data can be read
+}
+
+// walkEntries sniffs the format of an in-memory archive and calls fn for
+// every entry in archive order. For regular files r streams the
+// (decompressed) data; fn may read as much or as little of it as it
+// needs. It returns the detected archive type.
+func walkEntries(data []byte, fn func(e walkEntry, r io.Reader) error) (string, error) {
+	kind := DetectBytes(data)
+	var err error
+	switch kind {
+	case TypeZip:
+		err = walkZip(data, fn)
+	case TypeTgz, TypeTar:
+		var r io.Reader = bytes.NewReader(data)
+		if kind == TypeTgz {
+			gz, err := gzip.NewReader(r)
+			if err != nil {
+				return kind, err
+			}
+			defer gz.Close()
+			r = gz
+		}
+		err = walkTarEntries(r, Options{rawData: true}, &tarTotals{}, func(entry ParsedFile, content io.Reader) error {
+			return fn(walkEntry{Name: entry.Path, Size: entry.Size, IsDir: entry.IsDir, Regular: content != nil}, content)
+		})
+	default:
+		return kind, ErrUnknownFormat
+	}
+	return kind, err
+}
+
+func walkZip(data []byte, fn func(walkEntry, io.Reader) error) error {
+	r, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return err
+	}
+
+	for _, f := range r.File {
+		e := walkEntry{
+			Name:           f.Name,
+			Size:           int64(f.UncompressedSize64),
+			CompressedSize: int64(f.CompressedSize64),
+			IsDir:          f.FileInfo().IsDir(),
+		}
+		if e.IsDir {
+			if err := fn(e, nil); err != nil {
+				return err
+			}
+			continue
+		}
+
+		e.Regular = true
+		rc, err := f.Open()
+		if err != nil {
+			return err
+		}
+		err = fn(e, rc)
+		rc.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
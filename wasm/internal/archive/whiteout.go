@@ -0,0 +1,38 @@
+package archive
+
+import "strings"
+
+// Whiteout file names used by container image layers (OCI image spec,
+// Docker's AUFS and OverlayFS drivers) to record deletions.
+const (
+	whiteoutPrefix = ".wh."
+	whiteoutMeta   = ".wh..wh."     // AUFS bookkeeping, e.g. .wh..wh.plnk
+	whiteoutOpaque = ".wh..wh..opq" // hides everything below its directory
+)
+
+// markWhiteout flags a tar entry that is a whiteout file. A ".wh.name"
+// entry deletes the sibling "name" from lower layers; an opaque
+// whiteout hides the whole content of its directory, WhiteoutPath being
+// that directory with a trailing "/" ("" for the layer root). Other
+// ".wh..wh." entries are AUFS metadata and delete nothing.
+func markWhiteout(entry *ParsedFile) {
+	dir, base := splitBase(entry.Path)
+	if !strings.HasPrefix(base, whiteoutPrefix) {
+		return
+	}
+	entry.IsWhiteout = true
+	switch {
+	case base == whiteoutOpaque:
+		entry.OpaqueWhiteout = true
+		entry.WhiteoutPath = dir
+	case strings.HasPrefix(base, whiteoutMeta):
+	default:
+		entry.WhiteoutPath = dir + strings.TrimPrefix(base, whiteoutPrefix)
+	}
+}
+
+// splitBase splits p after its last "/", so dir keeps the slash.
+func splitBase(p string) (dir, base string) {
+	i := strings.LastIndexByte(p, '/')
+	return p[:i+1], p[i+1:]
+}
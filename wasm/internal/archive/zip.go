@@ -0,0 +1,253 @@
+package archive
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"hash/crc32"
+	"io"
+	"io/fs"
+	"strings"
+)
+
+// zipFlagDataDescriptor is general-purpose bit 3: CRC-32 and sizes are
+// stored in a data descriptor after the entry's data.
+const zipFlagDataDescriptor = 0x8
+
+// zipFlagEncrypted is general-purpose bit 0: the entry's data is
+// encrypted, which this package cannot decrypt.
+const zipFlagEncrypted = 0x1
+
+// ParseZipBytes parses a zip archive from an in-memory byte slice.
+func ParseZipBytes(data []byte, opts Options) (*ParseResult, error) {
+	r, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, err
+	}
+
+	sum := sha256.Sum256(data)
+	result := &ParseResult{
+		SchemaVersion: SchemaVersion,
+		Files:         make([]ParsedFile, 0, len(r.File)),
+		Sha256:        hex.EncodeToString(sum[:]),
+	}
+
+	budget := opts.newContentBudget()
+	for _, f := range r.File {
+		if !opts.Includes(f.Name) || !opts.includesMethod(f.Method) {
+			continue
+		}
+		entry, err := parseZipEntry(f, opts, budget)
+		if err != nil {
+			return nil, err
+		}
+		result.add(entry, opts)
+	}
+	result.ContentBudgetExceeded = budget.exceeded
+	if budget.exceeded && opts.Verbose {
+		result.Warnings = append(result.Warnings, "content budget exceeded: later entries were not loaded")
+	}
+
+	result.PrefixBytes, result.PrefixFormat = zipPrefix(data, r)
+	result.HasPrefixData = result.PrefixBytes > 0
+
+	if m := readZipManifest(r); m != nil {
+		result.Manifest = m.Main
+		result.SignedEntries = m.digestedEntries()
+	}
+	result.IsSigned = isSignedJar(r)
+	result.ArchiveKind, result.Android = detectAndroid(r)
+
+	if opts.ResolveSymlinks {
+		result.resolveSymlinks()
+	}
+	if opts.HashContent {
+		result.findDuplicates()
+	}
+	return result, nil
+}
+
+// WalkZipBytes parses a zip archive like ParseZipBytes but hands each
+// entry to fn as soon as it is read instead of accumulating them, so
+// only one entry's content is held in memory at a time. A non-nil error
+// from fn stops the walk and is returned.
+func WalkZipBytes(data []byte, opts Options, fn func(ParsedFile) error) error {
+	r, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return err
+	}
+
+	for _, f := range r.File {
+		if !opts.Includes(f.Name) || !opts.includesMethod(f.Method) {
+			continue
+		}
+		entry, err := parseZipEntry(f, opts, nil)
+		if err != nil {
+			return err
+		}
+		if entry.IsDir && opts.SkipDirs {
+			continue
+		}
+		if err := fn(entry); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// parseZipEntry reads a single zip entry's metadata and (small) content.
+// Content is charged to budget (nil for none); an entry that does not
+// fit is Skipped like an oversized one.
+func parseZipEntry(f *zip.File, opts Options, budget *contentBudget) (ParsedFile, error) {
+	dataOffset, err := f.DataOffset()
+	if err != nil {
+		return ParsedFile{}, err
+	}
+
+	entry := ParsedFile{
+		Path:           f.Name,
+		Size:           int64(f.UncompressedSize64),
+		IsDir:          f.FileInfo().IsDir(),
+		DataOffset:     dataOffset,
+		CompressedSize: int64(f.CompressedSize64),
+
+		HasDataDescriptor: f.Flags&zipFlagDataDescriptor != 0,
+	}
+	opts.normalizePath(&entry)
+	if opts.Strict && f.Flags&zipFlagEncrypted != 0 {
+		return ParsedFile{}, errors.New("strict: encrypted entry " + f.Name)
+	}
+
+	if entry.IsDir {
+		return entry, nil
+	}
+
+	isClass := strings.HasSuffix(strings.ToLower(f.Name), ".class")
+	isSymlink := f.Mode()&fs.ModeSymlink != 0
+	limit, truncated := opts.readLimit(entry.Size)
+	if isClass || isSymlink {
+		// .class files and link targets are read in full whatever the
+		// preview: class-parser needs every byte, and a cut target
+		// names another file. Content is still previewed below.
+		limit, truncated = Options{}.readLimit(entry.Size)
+	}
+	if limit < 0 || !budget.take(limit) {
+		entry.IsBinary = true
+		entry.Skipped = true
+		opts.annotate(&entry)
+		return entry, nil
+	}
+
+	rc, err := f.Open()
+	if err != nil {
+		return ParsedFile{}, err
+	}
+
+	buf, err := io.ReadAll(io.LimitReader(rc, limit))
+	rc.Close()
+	if err != nil {
+		return ParsedFile{}, err
+	}
+
+	if !truncated {
+		opts.hash(&entry, buf)
+	}
+
+	// A zip symlink's data is its target path.
+	if isSymlink {
+		entry.LinkTarget = string(buf)
+	}
+
+	// Special handling for .class files: pass raw bytes as base64
+	if isClass {
+		entry.IsBinary = true
+		entry.IsClassFile = true
+		entry.RawBase64 = base64.StdEncoding.EncodeToString(buf)
+	} else if text, charset := DecodeBOM(buf); IsBinaryFile(f.Name, text) {
+		entry.IsBinary = true
+		if opts.BinaryAsBase64 && !truncated {
+			entry.Content = base64.StdEncoding.EncodeToString(buf)
+		}
+	} else {
+		entry.Content, entry.Truncated = opts.previewText(text, truncated)
+		entry.Charset = charset
+	}
+	opts.annotate(&entry)
+
+	return entry, nil
+}
+
+// CorruptEntry names a zip entry that failed CheckZipBytes and why.
+type CorruptEntry struct {
+	Path  string `json:"path"`
+	Error string `json:"error"`
+}
+
+// ZipCheckResult is the JSON returned by __wasm_checkZip.
+type ZipCheckResult struct {
+	OK             bool           `json:"ok"`
+	CorruptEntries []CorruptEntry `json:"corruptEntries"`
+	TotalEntries   int            `json:"totalEntries"`
+}
+
+// CheckZipBytes decompresses every entry of a zip archive and compares
+// the CRC-32 of its data with the one stored in the central directory.
+// Entries are streamed through the hasher, so files of any size are
+// checked. Entries that fail to decompress are reported as corrupt too.
+// Only entries passing the path filters of opts are checked;
+// TotalEntries still counts every entry.
+func CheckZipBytes(data []byte, opts Options) (*ZipCheckResult, error) {
+	r, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, err
+	}
+
+	result := &ZipCheckResult{
+		CorruptEntries: make([]CorruptEntry, 0),
+		TotalEntries:   len(r.File),
+	}
+	for _, f := range r.File {
+		if f.FileInfo().IsDir() || !opts.Includes(f.Name) {
+			continue
+		}
+		if err := checkZipEntry(f); err != nil {
+			result.CorruptEntries = append(result.CorruptEntries, CorruptEntry{Path: f.Name, Error: err.Error()})
+		}
+	}
+	result.OK = len(result.CorruptEntries) == 0
+
+	return result, nil
+}
+
+// checkZipEntry streams one entry through a CRC-32 hasher.
+func checkZipEntry(f *zip.File) error {
+	rc, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+
+	h := crc32.NewIEEE()
+	if _, err := io.Copy(h, rc); err != nil && err != zip.ErrChecksum {
+		return err
+	}
+	if h.Sum32() != f.CRC32 {
+		return errors.New("CRC-32 mismatch: stored " + hex32(f.CRC32) + ", computed " + hex32(h.Sum32()))
+	}
+	return nil
+}
+
+// hex32 formats a CRC-32 as 8 hex digits.
+func hex32(v uint32) string {
+	const digits = "0123456789abcdef"
+	var b [8]byte
+	for i := 7; i >= 0; i-- {
+		b[i] = digits[v&0xf]
+		v >>= 4
+	}
+	return string(b[:])
+}
@@ -0,0 +1,177 @@
+package archive
+
+import (
+	"archive/zip"
+	"bytes"
+	"io/fs"
+	"strings"
+	"testing"
+)
+
+// zipEntry is a file for makeZip.
+type zipEntry struct {
+	name, data string
+}
+
+// makeZip deflates entries into a zip archive.
+func makeZip(t *testing.T, entries ...zipEntry) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, e := range entries {
+		w, err := zw.Create(e.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte(e.data)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// Entries past the content budget are listed but Skipped, even small
+// ones that would still fit.
+func TestContentBudget(t *testing.T) {
+	chunk := strings.Repeat("a", 400*1024) // a few hundred bytes deflated
+	data := makeZip(t,
+		zipEntry{"1.txt", chunk},
+		zipEntry{"2.txt", chunk},
+		zipEntry{"3.txt", chunk},
+		zipEntry{"4.txt", "small"},
+	)
+	if len(data) > 16*1024 {
+		t.Fatalf("test zip is %d bytes, want it highly compressed", len(data))
+	}
+
+	result, err := ParseZipBytes(data, Options{ContentBudget: 1024 * 1024, Verbose: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.ContentBudgetExceeded {
+		t.Error("ContentBudgetExceeded not set")
+	}
+	if len(result.Files) != 4 {
+		t.Fatalf("%d files listed, want all 4", len(result.Files))
+	}
+	for i, f := range result.Files {
+		loaded := i < 2
+		if f.Skipped == loaded || (f.Content != "") != loaded {
+			t.Errorf("%s: Skipped %v with %d bytes of content; want loaded %v", f.Path, f.Skipped, len(f.Content), loaded)
+		}
+	}
+	if !strings.Contains(strings.Join(result.Warnings, "\n"), "content budget exceeded") {
+		t.Errorf("warnings %q do not mention the budget", result.Warnings)
+	}
+
+	result, err = ParseZipBytes(data, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.ContentBudgetExceeded || result.Files[3].Content != "small" {
+		t.Errorf("default budget: exceeded %v, 4.txt %+v", result.ContentBudgetExceeded, result.Files[3])
+	}
+}
+
+// storedZip writes a zip of stored entries whose offsets assume offset
+// bytes in front of it, as zip -A leaves a self-extractor (0: none).
+func storedZip(t *testing.T, offset int64, entries ...zipEntry) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	zw.SetOffset(offset)
+	for _, e := range entries {
+		w, err := zw.CreateHeader(&zip.FileHeader{Name: e.name, Method: zip.Store})
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write([]byte(e.data))
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// Data in front of the first entry is measured and sniffed, and entry
+// data offsets count it, whether or not the zip's own offsets were
+// adjusted for it.
+func TestZipPrefix(t *testing.T) {
+	entries := []zipEntry{{"META-INF/MANIFEST.MF", "Manifest-Version: 1.0\n"}, {"a.txt", "hello"}}
+	for _, tc := range []struct {
+		prefix string
+		format string
+	}{
+		{"", ""},
+		{"#!/bin/sh\nexec java -jar \"$0\" \"$@\"\n", "script"},
+		{"MZ\x90\x00" + strings.Repeat("\x00", 508), "pe"},
+		{"\x7fELF\x02\x01\x01", "elf"},
+		{"some unknown header\n", ""},
+	} {
+		for _, adjusted := range []bool{false, true} {
+			var offset int64
+			if adjusted {
+				offset = int64(len(tc.prefix))
+			}
+			data := append([]byte(tc.prefix), storedZip(t, offset, entries...)...)
+
+			result, err := ParseZipBytes(data, Options{})
+			if err != nil {
+				t.Fatalf("%q (adjusted %v): %v", tc.prefix, adjusted, err)
+			}
+			if result.PrefixBytes != int64(len(tc.prefix)) || result.PrefixFormat != tc.format || result.HasPrefixData != (tc.prefix != "") {
+				t.Errorf("%q (adjusted %v): prefix %d bytes, %q, %v", tc.prefix, adjusted, result.PrefixBytes, result.PrefixFormat, result.HasPrefixData)
+			}
+			if len(result.Files) != len(entries) {
+				t.Fatalf("%q (adjusted %v): %d files", tc.prefix, adjusted, len(result.Files))
+			}
+			for i, f := range result.Files {
+				if got := string(data[f.DataOffset : f.DataOffset+f.Size]); got != entries[i].data {
+					t.Errorf("%q (adjusted %v): %s at %d reads %q", tc.prefix, adjusted, f.Path, f.DataOffset, got)
+				}
+			}
+		}
+	}
+}
+
+// A symlink's target is read in full however small the preview.
+func TestZipSymlinkTarget(t *testing.T) {
+	target := strings.Repeat("deep/", 200) + "target.txt"
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, e := range []zipEntry{{"link", target}, {target, "content"}} {
+		fh := &zip.FileHeader{Name: e.name, Method: zip.Deflate}
+		if e.name == "link" {
+			fh.SetMode(fs.ModeSymlink | 0o777)
+		}
+		w, err := zw.CreateHeader(fh)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write([]byte(e.data))
+	}
+	zw.Close()
+
+	result, err := ParseZipBytes(buf.Bytes(), Options{PreviewBytes: 16})
+	if err != nil {
+		t.Fatal(err)
+	}
+	link := result.Files[0]
+	if link.LinkTarget != target {
+		t.Errorf("LinkTarget is %d bytes, want %d", len(link.LinkTarget), len(target))
+	}
+	if link.Content != target[:16] || !link.Truncated {
+		t.Errorf("symlink Content %q, Truncated %v; want the 16-byte preview", link.Content, link.Truncated)
+	}
+
+	result, err = ParseZipBytes(buf.Bytes(), Options{PreviewBytes: 16, ResolveSymlinks: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if link := result.Files[0]; !link.Resolved || link.Content != "content" {
+		t.Errorf("resolved symlink = %+v", link)
+	}
+}
@@ -0,0 +1,3 @@
+module pkg-inspector/wasm/internal
+
+go 1.25.0
@@ -2,34 +2,16 @@ package main
 
 import (
 	"archive/tar"
-	"bytes"
 	"compress/gzip"
 	"encoding/json"
+	"errors"
 	"io"
+	"strings"
 	"syscall/js"
-	"unicode/utf8"
-)
 
-const (
-	maxFileContentSize = 512 * 1024        // 512KB: skip content for larger files
-	maxTotalSize       = 100 * 1024 * 1024 // 100MB: reject archives exceeding this
-	binaryCheckSize    = 512               // bytes to inspect for binary detection
+	"pkg-inspector/wasm/internal/archive"
 )
 
-// ParsedFile represents a single file entry extracted from the archive.
-type ParsedFile struct {
-	Path     string `json:"path"`
-	Size     int64  `json:"size"`
-	IsDir    bool   `json:"isDir"`
-	Content  string `json:"content"`
-	IsBinary bool   `json:"isBinary"`
-}
-
-// ParseResult is the top-level structure returned to JavaScript.
-type ParseResult struct {
-	Files []ParsedFile `json:"files"`
-}
-
 // FileIndexEntry is a lightweight entry for lazy-loading mode.
 // It records the byte offset within the uncompressed tar where the
 // file's data block begins, so we can read it on demand via Blob.slice().
@@ -39,26 +21,34 @@ type FileIndexEntry struct {
 	IsDir    bool   `json:"isDir"`
 	IsBinary bool   `json:"isBinary"`
 	Offset   int64  `json:"offset"`
+
+	// Sparse files are stored with their holes removed, so Offset/Size
+	// do not describe a Blob range and the entry cannot be read lazily.
+	// Text sparse files up to MaxFileContentSize are read eagerly into
+	// Content instead.
+	Sparse  bool   `json:"sparse,omitempty"`
+	Content string `json:"content,omitempty"`
 }
 
 // IndexResult is returned by the indexing pass.
 type IndexResult struct {
-	Files []FileIndexEntry `json:"files"`
+	SchemaVersion int              `json:"schemaVersion"` // see archive.SchemaVersion
+	Files         []FileIndexEntry `json:"files"`
+
+	// Resume is set by __wasm_indexTgzResume when the segment ended
+	// before the archive did; pass it back to index the next segment.
+	Resume *ResumeToken `json:"resume,omitempty"`
 }
 
-// isBinaryContent detects binary data by checking for null bytes
-// and invalid UTF-8 sequences in the first binaryCheckSize bytes.
-func isBinaryContent(data []byte) bool {
-	n := len(data)
-	if n > binaryCheckSize {
-		n = binaryCheckSize
-	}
-	for i := 0; i < n; i++ {
-		if data[i] == 0 {
-			return true
-		}
-	}
-	return !utf8.Valid(data[:n])
+// ResumeToken records where a segment of __wasm_indexTgzResume stopped:
+// Offset is the position in the uncompressed tar just after the last
+// indexed entry's data (and the end of the chunks sent so far), Entries
+// the number of entries indexed by all segments up to here. No header
+// state carries over: segments end after a whole entry, never between
+// a PAX or GNU long-name header and the entry it describes.
+type ResumeToken struct {
+	Offset  int64 `json:"offset"`
+	Entries int   `json:"entries"`
 }
 
 // ---------------------------------------------------------------------------
@@ -143,7 +133,14 @@ func (sr *streamReader) Close() error {
 // Pass nil/undefined/null for options to use default fetch behavior.
 // ---------------------------------------------------------------------------
 
-func jsFetch(url string, options js.Value) (io.ReadCloser, int, error) {
+// fetchInfo carries the response headers callers care about.
+type fetchInfo struct {
+	contentLength int
+	contentType   string // media type only, lowercased, parameters stripped
+	partial       bool   // 206 Partial Content: a Range request was honoured
+}
+
+func jsFetch(url string, options js.Value) (io.ReadCloser, fetchInfo, error) {
 	ch := make(chan struct{})
 	var response js.Value
 	var fetchErr error
@@ -171,28 +168,34 @@ func jsFetch(url string, options js.Value) (io.ReadCloser, int, error) {
 	<-ch
 
 	if fetchErr != nil {
-		return nil, 0, fetchErr
+		return nil, fetchInfo{}, fetchErr
 	}
 
 	status := response.Get("status").Int()
 	if !response.Get("ok").Bool() {
 		statusText := response.Get("statusText").String()
-		return nil, status, &fetchError{status: status, statusText: statusText}
+		return nil, fetchInfo{}, &fetchError{status: status, statusText: statusText}
 	}
 
 	body := response.Get("body")
-	contentLength := 0
-	clHeader := response.Get("headers").Call("get", "content-length")
+	info := fetchInfo{partial: status == 206}
+	headers := response.Get("headers")
+	clHeader := headers.Call("get", "content-length")
 	if !clHeader.IsNull() && !clHeader.IsUndefined() {
 		cl := clHeader.String()
 		for _, c := range cl {
 			if c >= '0' && c <= '9' {
-				contentLength = contentLength*10 + int(c-'0')
+				info.contentLength = info.contentLength*10 + int(c-'0')
 			}
 		}
 	}
+	ctHeader := headers.Call("get", "content-type")
+	if !ctHeader.IsNull() && !ctHeader.IsUndefined() {
+		ct, _, _ := strings.Cut(ctHeader.String(), ";")
+		info.contentType = strings.ToLower(strings.TrimSpace(ct))
+	}
 
-	return newStreamReader(body), contentLength, nil
+	return newStreamReader(body), info, nil
 }
 
 type fetchError struct {
@@ -201,103 +204,30 @@ type fetchError struct {
 }
 
 func (e *fetchError) Error() string {
-	return "HTTP " + itoa(e.status) + " " + e.statusText
-}
-
-// Simple int-to-string without importing strconv (keeps binary small).
-func itoa(n int) string {
-	if n == 0 {
-		return "0"
-	}
-	buf := [20]byte{}
-	i := len(buf) - 1
-	neg := false
-	if n < 0 {
-		neg = true
-		n = -n
-	}
-	for n > 0 {
-		buf[i] = byte('0' + n%10)
-		i--
-		n /= 10
-	}
-	if neg {
-		buf[i] = '-'
-		i--
-	}
-	return string(buf[i+1:])
+	return "HTTP " + archive.Itoa(e.status) + " " + e.statusText
 }
 
-// ---------------------------------------------------------------------------
-// parseTgzBytes: decompress a .tgz archive from an in-memory byte slice.
-// This is the original eager-loading path.
-// ---------------------------------------------------------------------------
-
-func parseTgzBytes(data []byte) (*ParseResult, error) {
-	gz, err := gzip.NewReader(bytes.NewReader(data))
-	if err != nil {
-		return nil, err
-	}
-	defer gz.Close()
-
-	return parseTar(gz)
+// isPlainTar reports whether a response Content-Type says the body is an
+// uncompressed tar, as some servers send for .tgz URLs whose content they
+// have already gunzipped. Anything else (application/gzip, x-gzip,
+// octet-stream, none) is treated as gzip.
+func isPlainTar(contentType string) bool {
+	return contentType == "application/x-tar" || contentType == "application/tar"
 }
 
-// parseTgzStream: decompress a .tgz archive from a streaming reader.
-// Used by fetchAndParseTgz (Phase 1).
-func parseTgzStream(r io.Reader) (*ParseResult, error) {
-	gz, err := gzip.NewReader(r)
+// fetchLayer fetches and parses one image layer. Layers are usually
+// gzipped, but uncompressed ones (application/x-tar) occur too.
+func fetchLayer(url string, options js.Value, opts archive.Options) (*archive.ParseResult, error) {
+	body, info, err := jsFetch(url, options)
 	if err != nil {
 		return nil, err
 	}
-	defer gz.Close()
-
-	return parseTar(gz)
-}
-
-// parseTar extracts all entries from an uncompressed tar stream.
-func parseTar(r io.Reader) (*ParseResult, error) {
-	tr := tar.NewReader(r)
-	result := &ParseResult{
-		Files: make([]ParsedFile, 0, 64),
-	}
-
-	for {
-		hdr, err := tr.Next()
-		if err == io.EOF {
-			break
-		}
-		if err != nil {
-			return nil, err
-		}
-
-		entry := ParsedFile{
-			Path:  hdr.Name,
-			Size:  hdr.Size,
-			IsDir: hdr.Typeflag == tar.TypeDir,
-		}
-
-		if !entry.IsDir && hdr.Typeflag == tar.TypeReg {
-			if hdr.Size > maxFileContentSize {
-				entry.IsBinary = true
-				io.Copy(io.Discard, tr)
-			} else {
-				buf := make([]byte, hdr.Size)
-				if _, err := io.ReadFull(tr, buf); err != nil {
-					return nil, err
-				}
-				if isBinaryContent(buf) {
-					entry.IsBinary = true
-				} else {
-					entry.Content = string(buf)
-				}
-			}
-		}
+	defer body.Close()
 
-		result.Files = append(result.Files, entry)
+	if isPlainTar(info.contentType) {
+		return archive.ParseTar(body, opts)
 	}
-
-	return result, nil
+	return archive.ParseTgzStream(body, opts)
 }
 
 // ---------------------------------------------------------------------------
@@ -334,7 +264,34 @@ func (w *jsChunkWriter) Write(p []byte) (int, error) {
 	return len(p), nil
 }
 
-func indexTgzStream(r io.Reader, onChunk js.Value) (*IndexResult, error) {
+// indexPeekSize returns the peekBytes option of __wasm_indexTgz: how
+// many bytes of each file binary detection inspects. It defaults to
+// archive.BinaryCheckSize and is capped at archive.MaxFileContentSize.
+func indexPeekSize(options js.Value) (int, error) {
+	n, ok, err := archive.OptionNumber(options, "peekBytes", true)
+	if err != nil || !ok {
+		return archive.BinaryCheckSize, err
+	}
+	return min(int(n), archive.MaxFileContentSize), nil
+}
+
+// progressCallback returns the onProgress option of __wasm_parseTgz as
+// an archive.Options.OnProgress, or nil when it is not given. A throw
+// from onProgress fails the parse.
+func progressCallback(options js.Value) (func(int, int64) error, error) {
+	cb, ok, err := archive.OptionFunc(options, "onProgress")
+	if err != nil || !ok {
+		return nil, err
+	}
+	return func(entries int, bytes int64) error {
+		return archive.InvokeCallback(cb, "onProgress", entries, bytes)
+	}, nil
+}
+
+// indexTgzStream indexes the tgz read from r. Binary detection inspects
+// the first peek bytes of each file; the rest is drained through the
+// tee either way, so offsets do not depend on peek.
+func indexTgzStream(r io.Reader, onChunk js.Value, peek int) (*IndexResult, error) {
 	gz, err := gzip.NewReader(r)
 	if err != nil {
 		return nil, err
@@ -348,9 +305,17 @@ func indexTgzStream(r io.Reader, onChunk js.Value) (*IndexResult, error) {
 	cw := &countingWriter{w: chunkW, count: 0}
 	tee := io.TeeReader(gz, cw)
 
+	return indexTar(tee, cw, peek, 0)
+}
+
+// indexTar indexes the tar read from tee, whose output cw counts from
+// the tar offset tee starts at. With limit > 0 it stops after the entry
+// that takes cw.count to limit or beyond, and sets result.Resume.
+func indexTar(tee io.Reader, cw *countingWriter, peek int, limit int64) (*IndexResult, error) {
 	tr := tar.NewReader(tee)
 	result := &IndexResult{
-		Files: make([]FileIndexEntry, 0, 64),
+		SchemaVersion: archive.SchemaVersion,
+		Files:         make([]FileIndexEntry, 0, 64),
 	}
 
 	for {
@@ -362,86 +327,195 @@ func indexTgzStream(r io.Reader, onChunk js.Value) (*IndexResult, error) {
 			return nil, err
 		}
 
+		if hdr.Name == "" {
+			continue
+		}
+
 		entry := FileIndexEntry{
 			Path:  hdr.Name,
 			Size:  hdr.Size,
-			IsDir: hdr.Typeflag == tar.TypeDir,
+			IsDir: archive.TarIsDir(hdr),
 		}
 
-		if !entry.IsDir && hdr.Typeflag == tar.TypeReg {
+		if !entry.IsDir && archive.TarIsSparse(hdr) {
+			entry.Sparse = true
+			if hdr.Size > archive.MaxFileContentSize {
+				entry.IsBinary = true
+				// Drain so the tee still writes the stored data to JS.
+				io.Copy(io.Discard, tr)
+			} else {
+				buf, err := io.ReadAll(tr)
+				if err != nil {
+					return nil, err
+				}
+				text, _ := archive.DecodeBOM(buf)
+				entry.IsBinary = archive.IsBinaryFile(entry.Path, text)
+				if !entry.IsBinary {
+					entry.Content = string(text)
+				}
+			}
+		} else if !entry.IsDir && hdr.Typeflag == tar.TypeReg {
 			// The current offset in the uncompressed tar is where
 			// the file's data block starts (tar.Reader has just
 			// consumed the header, tee has written it out).
 			entry.Offset = cw.count
 
-			if hdr.Size > maxFileContentSize {
+			if hdr.Size > archive.MaxFileContentSize {
 				entry.IsBinary = true
 				// Must drain data so the tee writes it to JS and offsets stay correct.
 				io.Copy(io.Discard, tr)
 			} else {
-				// Read the first binaryCheckSize bytes to detect binary.
-				checkSize := hdr.Size
-				if checkSize > binaryCheckSize {
-					checkSize = binaryCheckSize
-				}
-				peek := make([]byte, checkSize)
-				if _, err := io.ReadFull(tr, peek); err != nil {
+				// Read the first peek bytes to detect binary.
+				head := make([]byte, min(hdr.Size, int64(peek)))
+				if _, err := io.ReadFull(tr, head); err != nil {
 					return nil, err
 				}
-				entry.IsBinary = isBinaryContent(peek)
+				text, _ := archive.DecodeBOM(head)
+				entry.IsBinary = archive.IsBinaryFilePeek(entry.Path, text, peek)
 				// Drain remaining bytes so the tee writes them to JS.
 				io.Copy(io.Discard, tr)
 			}
 		}
 
 		result.Files = append(result.Files, entry)
+
+		// A PAX global header's records belong with the entries after
+		// it, so a segment never ends on one. Extended headers and GNU
+		// long names and links need no such care: tar.Reader returns
+		// them as part of their entry.
+		if limit > 0 && cw.count >= limit && hdr.Typeflag != tar.TypeXGlobalHeader {
+			io.Copy(io.Discard, tr) // data of entry types not read above
+			result.Resume = &ResumeToken{Offset: cw.count}
+			break
+		}
 	}
 
 	return result, nil
 }
 
-// ---------------------------------------------------------------------------
-// readFileContent reads a single file's bytes from a JS Blob at the
-// given offset and size. Used for on-demand file loading in Phase 2.
-// ---------------------------------------------------------------------------
+// indexSegmentSize is the default segmentBytes of __wasm_indexTgzResume.
+const indexSegmentSize = 256 * 1024 * 1024
+
+// indexSegment indexes the segment of the tgz (or plain tar) at url that
+// starts where token left off (nil: at the beginning) and spans about
+// segment uncompressed bytes. A plain tar is fetched from the token's
+// offset with a Range request. gzip cannot be entered mid-stream, so a
+// tgz is fetched and inflated from the start and everything before the
+// offset is dropped without being sent to onChunk.
+func indexSegment(url string, options, onChunk js.Value, token *ResumeToken, peek int, segment int64) (*IndexResult, error) {
+	var start int64
+	var entries int
+	if token != nil {
+		start, entries = token.Offset, token.Entries
+	}
 
-func readFileContent(blob js.Value, offset, size int64) (string, bool, error) {
-	// Blob.slice(start, end) returns a new Blob of that range.
-	slice := blob.Call("slice", offset, offset+size)
+	body, info, err := jsFetch(url, options)
+	if err != nil {
+		return nil, err
+	}
+	if isPlainTar(info.contentType) && start > 0 {
+		body.Close()
+		if body, info, err = jsFetch(url, withRange(options, start)); err != nil {
+			return nil, err
+		}
+	}
+	defer body.Close()
 
-	// slice.arrayBuffer() returns a Promise<ArrayBuffer>.
-	ch := make(chan struct{})
-	var arrBuf js.Value
-	var readErr error
+	var r io.Reader = body
+	skip := start
+	if !isPlainTar(info.contentType) {
+		gz, err := gzip.NewReader(body)
+		if err != nil {
+			return nil, err
+		}
+		defer gz.Close()
+		r = gz
+	} else if info.partial {
+		skip = 0 // the server honoured the Range
+	}
+	if _, err := io.CopyN(io.Discard, r, skip); err != nil {
+		return nil, err
+	}
 
-	thenCb := js.FuncOf(func(_ js.Value, args []js.Value) any {
-		arrBuf = args[0]
-		close(ch)
-		return nil
-	})
-	catchCb := js.FuncOf(func(_ js.Value, args []js.Value) any {
-		readErr = js.Error{Value: args[0]}
-		close(ch)
-		return nil
-	})
-	defer thenCb.Release()
-	defer catchCb.Release()
+	return indexTarFrom(r, &jsChunkWriter{onChunk: onChunk}, start, entries, peek, segment)
+}
 
-	slice.Call("arrayBuffer").Call("then", thenCb).Call("catch", catchCb)
-	<-ch
+// indexTarFrom indexes the segment of about segment bytes of the tar read
+// from r, which is positioned at offset start, where a segment indexing
+// entries entries before it stopped. Everything read is written to w.
+func indexTarFrom(r io.Reader, w io.Writer, start int64, entries, peek int, segment int64) (*IndexResult, error) {
+	cw := &countingWriter{w: w, count: start}
+	tee := io.TeeReader(r, cw)
+
+	// The previous segment stopped at the end of an entry's data. Send
+	// the block padding after it, so that the chunks of all segments
+	// concatenate to the whole tar, and start reading at the next header.
+	if pad := (512 - start%512) % 512; pad > 0 {
+		if _, err := io.CopyN(io.Discard, tee, pad); err != nil {
+			return nil, err
+		}
+	}
 
-	if readErr != nil {
-		return "", false, readErr
+	result, err := indexTar(tee, cw, peek, start+segment)
+	if err != nil {
+		return nil, err
+	}
+	if result.Resume != nil {
+		result.Resume.Entries = entries + len(result.Files)
+	}
+	return result, nil
+}
+
+// withRange returns a copy of the fetch init options with a Range header
+// requesting the bytes from offset on.
+func withRange(options js.Value, offset int64) js.Value {
+	init := js.Global().Get("Object").Call("assign", js.Global().Get("Object").New(), options)
+	headers := js.Global().Get("Headers").New(init.Get("headers"))
+	headers.Call("set", "Range", "bytes="+archive.Itoa(int(offset))+"-")
+	init.Set("headers", headers)
+	return init
+}
+
+// resumeTokenFromJS reads the resumeToken argument of
+// __wasm_indexTgzResume; undefined and null mean "start at the
+// beginning".
+func resumeTokenFromJS(v js.Value) (*ResumeToken, error) {
+	if v.IsUndefined() || v.IsNull() {
+		return nil, nil
+	}
+	off, n := v.Get("offset"), v.Get("entries")
+	if v.Type() != js.TypeObject || off.Type() != js.TypeNumber || n.Type() != js.TypeNumber || off.Float() < 0 {
+		return nil, errors.New("expected the resume object of a previous result")
+	}
+	return &ResumeToken{Offset: int64(off.Float()), Entries: n.Int()}, nil
+}
+
+// segmentSize returns the segmentBytes option of __wasm_indexTgzResume.
+func segmentSize(options js.Value) (int64, error) {
+	n, ok, err := archive.OptionNumber(options, "segmentBytes", true)
+	if err != nil || !ok {
+		return indexSegmentSize, err
 	}
+	return int64(n), nil
+}
+
+// ---------------------------------------------------------------------------
+// readFileContent reads a single file's bytes from a JS Blob at the
+// given offset and size. Used for on-demand file loading in Phase 2.
+// Text behind a byte-order mark comes back as UTF-8 without the BOM.
+// ---------------------------------------------------------------------------
 
-	jsArr := js.Global().Get("Uint8Array").New(arrBuf)
-	data := make([]byte, jsArr.Get("length").Int())
-	js.CopyBytesToGo(data, jsArr)
+func readFileContent(blob js.Value, offset, size int64) (string, bool, error) {
+	data, err := archive.ReadBlob(blob, offset, size)
+	if err != nil {
+		return "", false, err
+	}
 
-	if isBinaryContent(data) {
+	text, _ := archive.DecodeBOM(data)
+	if archive.IsBinaryContent(text) {
 		return "", true, nil
 	}
-	return string(data), false, nil
+	return string(text), false, nil
 }
 
 // ---------------------------------------------------------------------------
@@ -450,13 +524,16 @@ func readFileContent(blob js.Value, offset, size int64) (string, bool, error) {
 
 func main() {
 	// -----------------------------------------------------------------------
-	// __wasm_parseTgz(Uint8Array) -> Promise<string>
+	// __wasm_parseTgz(Uint8Array, options?: object) -> Promise<string>
 	// Original eager-loading from in-memory bytes. Kept for backward compat
 	// and for future use cases like local file / drag-and-drop.
+	// options: see archive.OptionsFromJS (WasmParseOptions in src/wasm.d.ts),
+	// plus onProgress(entriesProcessed, bytesUncompressed), called from
+	// the parse at most every 100ms and once when it is done.
 	// -----------------------------------------------------------------------
 	js.Global().Set("__wasm_parseTgz", js.FuncOf(func(_ js.Value, args []js.Value) any {
-		if len(args) != 1 {
-			return jsError("parseTgz requires exactly 1 argument (Uint8Array)")
+		if len(args) < 1 || len(args) > 2 {
+			return archive.JSError("parseTgz requires 1 or 2 arguments (Uint8Array, options?)")
 		}
 
 		handler := js.FuncOf(func(_ js.Value, promise []js.Value) any {
@@ -466,8 +543,21 @@ func main() {
 			go func() {
 				jsArr := args[0]
 				length := jsArr.Get("length").Int()
+				var options js.Value
+				if len(args) == 2 {
+					options = args[1]
+				}
+
+				opts, err := archive.OptionsFromJS(options)
+				if err == nil {
+					opts.OnProgress, err = progressCallback(options)
+				}
+				if err != nil {
+					reject.Invoke(js.Global().Get("Error").New("Invalid options: " + err.Error()))
+					return
+				}
 
-				if length > maxTotalSize {
+				if length > archive.MaxTotalSize {
 					reject.Invoke(js.Global().Get("Error").New("Archive too large (>100MB)"))
 					return
 				}
@@ -475,9 +565,9 @@ func main() {
 				data := make([]byte, length)
 				js.CopyBytesToGo(data, jsArr)
 
-				result, err := parseTgzBytes(data)
+				result, err := archive.ParseTgzBytes(data, opts)
 				if err != nil {
-					reject.Invoke(js.Global().Get("Error").New("Failed to parse tgz: " + err.Error()))
+					reject.Invoke(archive.ParseErrorToJS("Failed to parse tgz: ", err, result))
 					return
 				}
 
@@ -501,10 +591,14 @@ func main() {
 	// Phase 1: fetch via streaming, decompress, parse — no JS-side
 	// ArrayBuffer copy. Returns JSON ParseResult.
 	// options: { headers?: Record<string, string>, credentials?: string, ... }
+	// The same object also carries the parseTgz options (previewBytes, ...);
+	// fetch() ignores keys it does not know.
+	// A response with Content-Type application/x-tar is parsed as a plain
+	// tar instead of being gunzipped.
 	// -----------------------------------------------------------------------
 	js.Global().Set("__wasm_fetchAndParseTgz", js.FuncOf(func(_ js.Value, args []js.Value) any {
 		if len(args) < 1 || len(args) > 2 {
-			return jsError("fetchAndParseTgz requires 1 or 2 arguments (url, options?)")
+			return archive.JSError("fetchAndParseTgz requires 1 or 2 arguments (url, options?)")
 		}
 
 		handler := js.FuncOf(func(_ js.Value, promise []js.Value) any {
@@ -518,16 +612,27 @@ func main() {
 					options = args[1]
 				}
 
-				body, _, err := jsFetch(url, options)
+				opts, err := archive.OptionsFromJS(options)
+				if err != nil {
+					reject.Invoke(js.Global().Get("Error").New("Invalid options: " + err.Error()))
+					return
+				}
+
+				body, info, err := jsFetch(url, options)
 				if err != nil {
 					reject.Invoke(js.Global().Get("Error").New("Fetch failed: " + err.Error()))
 					return
 				}
 				defer body.Close()
 
-				result, err := parseTgzStream(body)
+				var result *archive.ParseResult
+				if isPlainTar(info.contentType) {
+					result, err = archive.ParseTar(body, opts)
+				} else {
+					result, err = archive.ParseTgzStream(body, opts)
+				}
 				if err != nil {
-					reject.Invoke(js.Global().Get("Error").New("Failed to parse tgz: " + err.Error()))
+					reject.Invoke(archive.ParseErrorToJS("Failed to parse tgz: ", err, result))
 					return
 				}
 
@@ -547,15 +652,24 @@ func main() {
 	}))
 
 	// -----------------------------------------------------------------------
-	// __wasm_indexTgz(url: string, onChunk: Function, options?: object) -> Promise<string>
-	// Phase 2 lazy-loading: fetch, decompress, stream uncompressed tar
-	// chunks to JS via onChunk(Uint8Array), build a file index with
-	// byte offsets. Returns JSON IndexResult (no file content).
-	// options: { headers?: Record<string, string>, credentials?: string, ... }
+	// __wasm_fetchAndStreamTgz(url: string, onEntry: Function, options?: object) -> Promise<number>
+	// Memory-bounded fetchAndParseTgz: calls onEntry(jsonString) with each
+	// ParsedFile as it is decompressed and resolves with the number of
+	// entries delivered. Nothing accumulates on the Go side -- no archive
+	// copy, no Files slice, no result JSON -- so peak memory is one
+	// entry's content (capped by previewBytes/maxFileSize) plus the
+	// decompressor window, however large the archive; what onEntry keeps
+	// is up to the caller. onEntry runs synchronously and in archive
+	// order; the next entry is not read until it returns, and a throw
+	// rejects the promise and stops the download. Directories are left
+	// out under skipDirs; resolveSymlinks and the duplicateContent
+	// grouping of hashContent need the whole archive and do not apply,
+	// and there is no archive sha256 or compression summary.
+	// options: same as fetchAndParseTgz
 	// -----------------------------------------------------------------------
-	js.Global().Set("__wasm_indexTgz", js.FuncOf(func(_ js.Value, args []js.Value) any {
+	js.Global().Set("__wasm_fetchAndStreamTgz", js.FuncOf(func(_ js.Value, args []js.Value) any {
 		if len(args) < 2 || len(args) > 3 {
-			return jsError("indexTgz requires 2 or 3 arguments (url, onChunk, options?)")
+			return archive.JSError("fetchAndStreamTgz requires 2 or 3 arguments (url, onEntry, options?)")
 		}
 
 		handler := js.FuncOf(func(_ js.Value, promise []js.Value) any {
@@ -564,28 +678,104 @@ func main() {
 
 			go func() {
 				url := args[0].String()
-				onChunk := args[1]
+				onEntry := args[1]
 				var options js.Value
 				if len(args) == 3 && !args[2].IsUndefined() && !args[2].IsNull() {
 					options = args[2]
 				}
 
-				body, _, err := jsFetch(url, options)
+				if onEntry.Type() != js.TypeFunction {
+					reject.Invoke(js.Global().Get("Error").New("onEntry must be a function"))
+					return
+				}
+				opts, err := archive.OptionsFromJS(options)
+				if err != nil {
+					reject.Invoke(js.Global().Get("Error").New("Invalid options: " + err.Error()))
+					return
+				}
+
+				body, info, err := jsFetch(url, options)
 				if err != nil {
 					reject.Invoke(js.Global().Get("Error").New("Fetch failed: " + err.Error()))
 					return
 				}
 				defer body.Close()
 
-				result, err := indexTgzStream(body, onChunk)
+				count := 0
+				deliver := func(entry archive.ParsedFile) error {
+					jsonBytes, err := json.Marshal(entry)
+					if err != nil {
+						return err
+					}
+					if err := archive.InvokeCallback(onEntry, "onEntry", string(jsonBytes)); err != nil {
+						return err
+					}
+					count++
+					return nil
+				}
+				if isPlainTar(info.contentType) {
+					err = archive.WalkTar(body, opts, deliver)
+				} else {
+					err = archive.WalkTgzStream(body, opts, deliver)
+				}
 				if err != nil {
-					reject.Invoke(js.Global().Get("Error").New("Failed to index tgz: " + err.Error()))
+					reject.Invoke(js.Global().Get("Error").New("Failed to parse tgz: " + err.Error()))
 					return
 				}
 
-				jsonBytes, err := json.Marshal(result)
+				resolve.Invoke(count)
+			}()
+
+			return nil
+		})
+
+		return js.Global().Get("Promise").New(handler)
+	}))
+
+	// -----------------------------------------------------------------------
+	// __wasm_fetchAndListTgz(url: string, options?: object) -> Promise<string>
+	// Stream a .tgz and collect only its entry names; file data is
+	// decompressed and dropped without inspection. options are passed to
+	// fetch(). A response with Content-Type application/x-tar is read as
+	// a plain tar. Returns a JSON array of path strings.
+	// -----------------------------------------------------------------------
+	js.Global().Set("__wasm_fetchAndListTgz", js.FuncOf(func(_ js.Value, args []js.Value) any {
+		if len(args) < 1 || len(args) > 2 {
+			return archive.JSError("fetchAndListTgz requires 1 or 2 arguments (url, options?)")
+		}
+
+		handler := js.FuncOf(func(_ js.Value, promise []js.Value) any {
+			resolve := promise[0]
+			reject := promise[1]
+
+			go func() {
+				url := args[0].String()
+				var options js.Value
+				if len(args) == 2 && !args[1].IsUndefined() && !args[1].IsNull() {
+					options = args[1]
+				}
+
+				body, info, err := jsFetch(url, options)
 				if err != nil {
-					reject.Invoke(js.Global().Get("Error").New("Failed to serialize index: " + err.Error()))
+					reject.Invoke(js.Global().Get("Error").New("Fetch failed: " + err.Error()))
+					return
+				}
+				defer body.Close()
+
+				var paths []string
+				if isPlainTar(info.contentType) {
+					paths, err = archive.ListTarPaths(body)
+				} else {
+					paths, err = archive.ListTgzPaths(body)
+				}
+				if err != nil {
+					reject.Invoke(js.Global().Get("Error").New("Failed to list tgz: " + err.Error()))
+					return
+				}
+
+				jsonBytes, err := json.Marshal(paths)
+				if err != nil {
+					reject.Invoke(js.Global().Get("Error").New("Failed to serialize result: " + err.Error()))
 					return
 				}
 
@@ -599,13 +789,16 @@ func main() {
 	}))
 
 	// -----------------------------------------------------------------------
-	// __wasm_readFileFromTar(blob: Blob, offset: number, size: number) -> Promise<string>
-	// Phase 2: read a single file from the uncompressed tar Blob.
-	// Returns JSON {content: string, isBinary: bool}.
+	// __wasm_resolveImageLayers(layerUrls: string[], options?: object) -> Promise<string>
+	// Fetch and parse container image layer tgzs bottom first, one at a
+	// time, and merge them into the final image's file system: later
+	// layers override earlier ones and whiteouts delete entries. options
+	// doubles as the fetch() init and the parseTgz options, as for
+	// fetchAndParseTgz. Returns JSON ParseResult with layerCount set.
 	// -----------------------------------------------------------------------
-	js.Global().Set("__wasm_readFileFromTar", js.FuncOf(func(_ js.Value, args []js.Value) any {
-		if len(args) != 3 {
-			return jsError("readFileFromTar requires 3 arguments (blob, offset, size)")
+	js.Global().Set("__wasm_resolveImageLayers", js.FuncOf(func(_ js.Value, args []js.Value) any {
+		if len(args) < 1 || len(args) > 2 {
+			return archive.JSError("resolveImageLayers requires 1 or 2 arguments (layerUrls, options?)")
 		}
 
 		handler := js.FuncOf(func(_ js.Value, promise []js.Value) any {
@@ -613,24 +806,35 @@ func main() {
 			reject := promise[1]
 
 			go func() {
-				blob := args[0]
-				offset := int64(args[1].Float())
-				size := int64(args[2].Float())
+				urls, err := archive.StringsFromJS(args[0])
+				if err != nil {
+					reject.Invoke(js.Global().Get("Error").New("Invalid layerUrls: " + err.Error()))
+					return
+				}
+				var options js.Value
+				if len(args) == 2 && !args[1].IsUndefined() && !args[1].IsNull() {
+					options = args[1]
+				}
 
-				content, binary, err := readFileContent(blob, offset, size)
+				opts, err := archive.OptionsFromJS(options)
 				if err != nil {
-					reject.Invoke(js.Global().Get("Error").New("Failed to read file: " + err.Error()))
+					reject.Invoke(js.Global().Get("Error").New("Invalid options: " + err.Error()))
 					return
 				}
 
-				// Return as JSON: {content, isBinary}
-				result := map[string]any{
-					"content":  content,
-					"isBinary": binary,
+				stack := archive.NewLayerStack()
+				for i, url := range urls {
+					layer, err := fetchLayer(url, options, opts.ForLayer())
+					if err != nil {
+						reject.Invoke(js.Global().Get("Error").New("Failed to read layer " + archive.Itoa(i) + ": " + err.Error()))
+						return
+					}
+					stack.Apply(layer)
 				}
-				jsonBytes, err := json.Marshal(result)
+
+				jsonBytes, err := json.Marshal(stack.Result(opts))
 				if err != nil {
-					reject.Invoke(js.Global().Get("Error").New("Failed to serialize: " + err.Error()))
+					reject.Invoke(js.Global().Get("Error").New("Failed to serialize result: " + err.Error()))
 					return
 				}
 
@@ -643,11 +847,597 @@ func main() {
 		return js.Global().Get("Promise").New(handler)
 	}))
 
-	// Block forever — WASM instance must stay alive to serve calls.
-	select {}
-}
+	// -----------------------------------------------------------------------
+	// __wasm_indexTgz(url: string, onChunk: Function, options?: object) -> Promise<string>
+	// Phase 2 lazy-loading: fetch, decompress, stream uncompressed tar
+	// chunks to JS via onChunk(Uint8Array), build a file index with
+	// byte offsets. Returns JSON IndexResult (no file content).
+	// options: { headers?: Record<string, string>, credentials?: string, ...,
+	//            peekBytes?: number }
+	// -----------------------------------------------------------------------
+	js.Global().Set("__wasm_indexTgz", js.FuncOf(func(_ js.Value, args []js.Value) any {
+		if len(args) < 2 || len(args) > 3 {
+			return archive.JSError("indexTgz requires 2 or 3 arguments (url, onChunk, options?)")
+		}
 
-func jsError(msg string) any {
-	return js.Global().Get("Promise").Call("reject",
-		js.Global().Get("Error").New(msg))
+		handler := js.FuncOf(func(_ js.Value, promise []js.Value) any {
+			resolve := promise[0]
+			reject := promise[1]
+
+			go func() {
+				url := args[0].String()
+				onChunk := args[1]
+				var options js.Value
+				if len(args) == 3 && !args[2].IsUndefined() && !args[2].IsNull() {
+					options = args[2]
+				}
+
+				peek, err := indexPeekSize(options)
+				if err != nil {
+					reject.Invoke(js.Global().Get("Error").New("Invalid options: " + err.Error()))
+					return
+				}
+
+				body, _, err := jsFetch(url, options)
+				if err != nil {
+					reject.Invoke(js.Global().Get("Error").New("Fetch failed: " + err.Error()))
+					return
+				}
+				defer body.Close()
+
+				result, err := indexTgzStream(body, onChunk, peek)
+				if err != nil {
+					reject.Invoke(js.Global().Get("Error").New("Failed to index tgz: " + err.Error()))
+					return
+				}
+
+				jsonBytes, err := json.Marshal(result)
+				if err != nil {
+					reject.Invoke(js.Global().Get("Error").New("Failed to serialize index: " + err.Error()))
+					return
+				}
+
+				resolve.Invoke(string(jsonBytes))
+			}()
+
+			return nil
+		})
+
+		return js.Global().Get("Promise").New(handler)
+	}))
+
+	// -----------------------------------------------------------------------
+	// __wasm_indexTgzResume(url: string, onChunk: Function, resumeToken?: object, options?: object) -> Promise<string>
+	// indexTgz in segments of about options.segmentBytes (default 256MB)
+	// of uncompressed tar, so a multi-GB archive can be indexed across
+	// calls. Each call sends only its segment's chunks to onChunk; the
+	// chunks of all segments concatenate to the whole tar, and offsets
+	// are from its start. Returns JSON IndexResult; its resume token,
+	// absent after the last segment, is the resumeToken for the next call.
+	// options: as for indexTgz, plus segmentBytes?: number
+	// -----------------------------------------------------------------------
+	js.Global().Set("__wasm_indexTgzResume", js.FuncOf(func(_ js.Value, args []js.Value) any {
+		if len(args) < 2 || len(args) > 4 {
+			return archive.JSError("indexTgzResume requires 2 to 4 arguments (url, onChunk, resumeToken?, options?)")
+		}
+
+		handler := js.FuncOf(func(_ js.Value, promise []js.Value) any {
+			resolve := promise[0]
+			reject := promise[1]
+
+			go func() {
+				url := args[0].String()
+				onChunk := args[1]
+				var tokenArg, options js.Value
+				if len(args) >= 3 {
+					tokenArg = args[2]
+				}
+				if len(args) == 4 && !args[3].IsUndefined() && !args[3].IsNull() {
+					options = args[3]
+				}
+
+				token, err := resumeTokenFromJS(tokenArg)
+				if err != nil {
+					reject.Invoke(js.Global().Get("Error").New("Invalid resumeToken: " + err.Error()))
+					return
+				}
+				peek, err := indexPeekSize(options)
+				var segment int64
+				if err == nil {
+					segment, err = segmentSize(options)
+				}
+				if err != nil {
+					reject.Invoke(js.Global().Get("Error").New("Invalid options: " + err.Error()))
+					return
+				}
+
+				result, err := indexSegment(url, options, onChunk, token, peek, segment)
+				if err != nil {
+					reject.Invoke(js.Global().Get("Error").New("Failed to index tgz: " + err.Error()))
+					return
+				}
+
+				jsonBytes, err := json.Marshal(result)
+				if err != nil {
+					reject.Invoke(js.Global().Get("Error").New("Failed to serialize index: " + err.Error()))
+					return
+				}
+
+				resolve.Invoke(string(jsonBytes))
+			}()
+
+			return nil
+		})
+
+		return js.Global().Get("Promise").New(handler)
+	}))
+
+	// -----------------------------------------------------------------------
+	// __wasm_readFileFromTar(blob: Blob, options: object) -> Promise<string>
+	// Phase 2: read a single file from the uncompressed tar Blob.
+	// Returns JSON {content: string, isBinary: bool}.
+	// options: { offset: number, size: number }, an entry's from the index
+	// -----------------------------------------------------------------------
+	js.Global().Set("__wasm_readFileFromTar", js.FuncOf(func(_ js.Value, args []js.Value) any {
+		if len(args) != 2 {
+			return archive.JSError("readFileFromTar requires 2 arguments (blob, options)")
+		}
+
+		handler := js.FuncOf(func(_ js.Value, promise []js.Value) any {
+			resolve := promise[0]
+			reject := promise[1]
+
+			go func() {
+				blob := args[0]
+				offset, err := archive.RequiredOptionNumber(args[1], "offset", false)
+				var size float64
+				if err == nil {
+					size, err = archive.RequiredOptionNumber(args[1], "size", false)
+				}
+				if err != nil {
+					reject.Invoke(js.Global().Get("Error").New("Invalid options: " + err.Error()))
+					return
+				}
+
+				content, binary, err := readFileContent(blob, int64(offset), int64(size))
+				if err != nil {
+					reject.Invoke(js.Global().Get("Error").New("Failed to read file: " + err.Error()))
+					return
+				}
+
+				// Return as JSON: {content, isBinary}
+				result := map[string]any{
+					"content":  content,
+					"isBinary": binary,
+				}
+				jsonBytes, err := json.Marshal(result)
+				if err != nil {
+					reject.Invoke(js.Global().Get("Error").New("Failed to serialize: " + err.Error()))
+					return
+				}
+
+				resolve.Invoke(string(jsonBytes))
+			}()
+
+			return nil
+		})
+
+		return js.Global().Get("Promise").New(handler)
+	}))
+
+	// -----------------------------------------------------------------------
+	// __wasm_parseArchive(Uint8Array, options?: object) -> Promise<string>
+	// Sniff the archive format (zip, tgz or tar) and parse with the
+	// matching parser. Returns JSON ParseResult with archiveType set.
+	// options: same as parseTgz
+	// -----------------------------------------------------------------------
+	js.Global().Set("__wasm_parseArchive", js.FuncOf(func(_ js.Value, args []js.Value) any {
+		if len(args) < 1 || len(args) > 2 {
+			return archive.JSError("parseArchive requires 1 or 2 arguments (Uint8Array, options?)")
+		}
+
+		handler := js.FuncOf(func(_ js.Value, promise []js.Value) any {
+			resolve := promise[0]
+			reject := promise[1]
+
+			go func() {
+				jsArr := args[0]
+				length := jsArr.Get("length").Int()
+				var options js.Value
+				if len(args) == 2 {
+					options = args[1]
+				}
+
+				opts, err := archive.OptionsFromJS(options)
+				if err != nil {
+					reject.Invoke(js.Global().Get("Error").New("Invalid options: " + err.Error()))
+					return
+				}
+
+				if length > archive.MaxTotalSize {
+					reject.Invoke(js.Global().Get("Error").New("Archive too large (>100MB)"))
+					return
+				}
+
+				data := make([]byte, length)
+				js.CopyBytesToGo(data, jsArr)
+
+				result, err := archive.ParseBytes(data, opts)
+				if err != nil {
+					reject.Invoke(archive.ParseErrorToJS("Failed to parse archive: ", err, result))
+					return
+				}
+
+				jsonBytes, err := json.Marshal(result)
+				if err != nil {
+					reject.Invoke(js.Global().Get("Error").New("Failed to serialize result: " + err.Error()))
+					return
+				}
+
+				resolve.Invoke(string(jsonBytes))
+			}()
+
+			return nil
+		})
+
+		return js.Global().Get("Promise").New(handler)
+	}))
+
+	// -----------------------------------------------------------------------
+	// __wasm_fetchAndParseArchive(url: string, options?: object) -> Promise<string>
+	// Streaming variant of parseArchive. tgz/tar are parsed as the body
+	// streams in; zip is buffered first (it needs its central directory).
+	// options: same as fetchAndParseTgz
+	// -----------------------------------------------------------------------
+	js.Global().Set("__wasm_fetchAndParseArchive", js.FuncOf(func(_ js.Value, args []js.Value) any {
+		if len(args) < 1 || len(args) > 2 {
+			return archive.JSError("fetchAndParseArchive requires 1 or 2 arguments (url, options?)")
+		}
+
+		handler := js.FuncOf(func(_ js.Value, promise []js.Value) any {
+			resolve := promise[0]
+			reject := promise[1]
+
+			go func() {
+				url := args[0].String()
+				var options js.Value
+				if len(args) == 2 && !args[1].IsUndefined() && !args[1].IsNull() {
+					options = args[1]
+				}
+
+				opts, err := archive.OptionsFromJS(options)
+				if err != nil {
+					reject.Invoke(js.Global().Get("Error").New("Invalid options: " + err.Error()))
+					return
+				}
+
+				body, _, err := jsFetch(url, options)
+				if err != nil {
+					reject.Invoke(js.Global().Get("Error").New("Fetch failed: " + err.Error()))
+					return
+				}
+				defer body.Close()
+
+				result, err := archive.ParseReader(body, opts)
+				if err != nil {
+					reject.Invoke(archive.ParseErrorToJS("Failed to parse archive: ", err, result))
+					return
+				}
+
+				jsonBytes, err := json.Marshal(result)
+				if err != nil {
+					reject.Invoke(js.Global().Get("Error").New("Failed to serialize result: " + err.Error()))
+					return
+				}
+
+				resolve.Invoke(string(jsonBytes))
+			}()
+
+			return nil
+		})
+
+		return js.Global().Get("Promise").New(handler)
+	}))
+
+	// -----------------------------------------------------------------------
+	// __wasm_searchArchive(Uint8Array, query: string, options?: object) -> Promise<string>
+	// Grep the text files of a zip/tgz/tar archive. Binary and oversized
+	// files are skipped. Returns JSON SearchResult.
+	// options: { regex?: bool, caseInsensitive?: bool, pathGlob?: string, maxMatches?: number }
+	// -----------------------------------------------------------------------
+	js.Global().Set("__wasm_searchArchive", js.FuncOf(func(_ js.Value, args []js.Value) any {
+		if len(args) < 2 || len(args) > 3 {
+			return archive.JSError("searchArchive requires 2 or 3 arguments (Uint8Array, query, options?)")
+		}
+
+		handler := js.FuncOf(func(_ js.Value, promise []js.Value) any {
+			resolve := promise[0]
+			reject := promise[1]
+
+			go func() {
+				jsArr := args[0]
+				query := args[1].String()
+				length := jsArr.Get("length").Int()
+				var options js.Value
+				if len(args) == 3 {
+					options = args[2]
+				}
+
+				opts, err := archive.SearchOptionsFromJS(options)
+				if err != nil {
+					reject.Invoke(js.Global().Get("Error").New("Invalid options: " + err.Error()))
+					return
+				}
+
+				if length > archive.MaxTotalSize {
+					reject.Invoke(js.Global().Get("Error").New("Archive too large (>100MB)"))
+					return
+				}
+
+				data := make([]byte, length)
+				js.CopyBytesToGo(data, jsArr)
+
+				result, err := archive.SearchBytes(data, query, opts)
+				if err != nil {
+					reject.Invoke(js.Global().Get("Error").New("Failed to search archive: " + err.Error()))
+					return
+				}
+
+				jsonBytes, err := json.Marshal(result)
+				if err != nil {
+					reject.Invoke(js.Global().Get("Error").New("Failed to serialize result: " + err.Error()))
+					return
+				}
+
+				resolve.Invoke(string(jsonBytes))
+			}()
+
+			return nil
+		})
+
+		return js.Global().Get("Promise").New(handler)
+	}))
+
+	// -----------------------------------------------------------------------
+	// __wasm_archiveSummary(Uint8Array, options?: object) -> Promise<string>
+	// Aggregate stats for a zip/tgz/tar archive (file/dir counts, sizes,
+	// binary vs text, largest file, counts per extension) computed in one
+	// pass without returning file content. Returns JSON Summary.
+	// options: the path filters of parseTgz (pathRegex, include, exclude)
+	// -----------------------------------------------------------------------
+	js.Global().Set("__wasm_archiveSummary", js.FuncOf(func(_ js.Value, args []js.Value) any {
+		if len(args) < 1 || len(args) > 2 {
+			return archive.JSError("archiveSummary requires 1 or 2 arguments (Uint8Array, options?)")
+		}
+
+		handler := js.FuncOf(func(_ js.Value, promise []js.Value) any {
+			resolve := promise[0]
+			reject := promise[1]
+
+			go func() {
+				jsArr := args[0]
+				length := jsArr.Get("length").Int()
+				var options js.Value
+				if len(args) == 2 {
+					options = args[1]
+				}
+				opts, err := archive.OptionsFromJS(options)
+				if err != nil {
+					reject.Invoke(js.Global().Get("Error").New("Invalid options: " + err.Error()))
+					return
+				}
+
+				if length > archive.MaxTotalSize {
+					reject.Invoke(js.Global().Get("Error").New("Archive too large (>100MB)"))
+					return
+				}
+
+				data := make([]byte, length)
+				js.CopyBytesToGo(data, jsArr)
+
+				result, err := archive.SummarizeBytes(data, opts)
+				if err != nil {
+					reject.Invoke(js.Global().Get("Error").New("Failed to summarize archive: " + err.Error()))
+					return
+				}
+
+				jsonBytes, err := json.Marshal(result)
+				if err != nil {
+					reject.Invoke(js.Global().Get("Error").New("Failed to serialize result: " + err.Error()))
+					return
+				}
+
+				resolve.Invoke(string(jsonBytes))
+			}()
+
+			return nil
+		})
+
+		return js.Global().Get("Promise").New(handler)
+	}))
+
+	// -----------------------------------------------------------------------
+	// __wasm_archiveDigest(Uint8Array, options?: object) -> Promise<string>
+	// Order- and timestamp-independent hash of a zip/tgz/tar archive's
+	// file paths, sizes and contents, so two differently built archives
+	// can be compared.
+	// Returns JSON DigestResult {algo, digest, entryCount}.
+	// options: { algo?: "sha256" (default) | "sha1" }
+	// -----------------------------------------------------------------------
+	js.Global().Set("__wasm_archiveDigest", js.FuncOf(func(_ js.Value, args []js.Value) any {
+		if len(args) < 1 || len(args) > 2 {
+			return archive.JSError("archiveDigest requires 1 or 2 arguments (Uint8Array, options?)")
+		}
+
+		handler := js.FuncOf(func(_ js.Value, promise []js.Value) any {
+			resolve := promise[0]
+			reject := promise[1]
+
+			go func() {
+				jsArr := args[0]
+				length := jsArr.Get("length").Int()
+				var options js.Value
+				if len(args) == 2 {
+					options = args[1]
+				}
+				algo, ok, err := archive.OptionString(options, "algo", "sha256", "sha1")
+				if err != nil {
+					reject.Invoke(js.Global().Get("Error").New("Invalid options: " + err.Error()))
+					return
+				}
+				if !ok {
+					algo = "sha256"
+				}
+
+				if length > archive.MaxTotalSize {
+					reject.Invoke(js.Global().Get("Error").New("Archive too large (>100MB)"))
+					return
+				}
+
+				data := make([]byte, length)
+				js.CopyBytesToGo(data, jsArr)
+
+				result, err := archive.DigestBytes(data, algo)
+				if err != nil {
+					reject.Invoke(js.Global().Get("Error").New("Failed to digest archive: " + err.Error()))
+					return
+				}
+
+				jsonBytes, err := json.Marshal(result)
+				if err != nil {
+					reject.Invoke(js.Global().Get("Error").New("Failed to serialize result: " + err.Error()))
+					return
+				}
+
+				resolve.Invoke(string(jsonBytes))
+			}()
+
+			return nil
+		})
+
+		return js.Global().Get("Promise").New(handler)
+	}))
+
+	// -----------------------------------------------------------------------
+	// __wasm_diffArchives(oldBytes: Uint8Array, newBytes: Uint8Array, options?: object) -> Promise<string>
+	// Compare the files of two zip/tgz/tar archives, which may be of
+	// different formats, by content SHA-256.
+	// Returns JSON DiffResult {oldType, newType, added, removed, modified}.
+	// options: the path filters of parseTgz (pathRegex, include, exclude)
+	// -----------------------------------------------------------------------
+	js.Global().Set("__wasm_diffArchives", js.FuncOf(func(_ js.Value, args []js.Value) any {
+		if len(args) < 2 || len(args) > 3 {
+			return archive.JSError("diffArchives requires 2 or 3 arguments (oldBytes, newBytes, options?)")
+		}
+
+		handler := js.FuncOf(func(_ js.Value, promise []js.Value) any {
+			resolve := promise[0]
+			reject := promise[1]
+
+			go func() {
+				var options js.Value
+				if len(args) == 3 {
+					options = args[2]
+				}
+				opts, err := archive.OptionsFromJS(options)
+				if err != nil {
+					reject.Invoke(js.Global().Get("Error").New("Invalid options: " + err.Error()))
+					return
+				}
+				oldLen := args[0].Get("length").Int()
+				newLen := args[1].Get("length").Int()
+
+				if oldLen > archive.MaxTotalSize || newLen > archive.MaxTotalSize {
+					reject.Invoke(js.Global().Get("Error").New("Archive too large (>100MB)"))
+					return
+				}
+
+				oldData := make([]byte, oldLen)
+				js.CopyBytesToGo(oldData, args[0])
+				newData := make([]byte, newLen)
+				js.CopyBytesToGo(newData, args[1])
+
+				result, err := archive.DiffBytes(oldData, newData, opts)
+				if err != nil {
+					reject.Invoke(js.Global().Get("Error").New("Failed to diff archives: " + err.Error()))
+					return
+				}
+
+				jsonBytes, err := json.Marshal(result)
+				if err != nil {
+					reject.Invoke(js.Global().Get("Error").New("Failed to serialize result: " + err.Error()))
+					return
+				}
+
+				resolve.Invoke(string(jsonBytes))
+			}()
+
+			return nil
+		})
+
+		return js.Global().Get("Promise").New(handler)
+	}))
+
+	// -----------------------------------------------------------------------
+	// __wasm_repackTgz(Uint8Array, options?: object) -> Promise<Uint8Array>
+	// Write a new .tgz holding only the entries that pass the path
+	// filters: options.include globs (all entries when empty), none of
+	// options.exclude, and options.pathRegex. Patterns without "/" match
+	// the base name ("*.md"). Returns the new archive bytes.
+	// -----------------------------------------------------------------------
+	js.Global().Set("__wasm_repackTgz", js.FuncOf(func(_ js.Value, args []js.Value) any {
+		if len(args) < 1 || len(args) > 2 {
+			return archive.JSError("repackTgz requires 1 or 2 arguments (Uint8Array, options?)")
+		}
+
+		handler := js.FuncOf(func(_ js.Value, promise []js.Value) any {
+			resolve := promise[0]
+			reject := promise[1]
+
+			go func() {
+				jsArr := args[0]
+				length := jsArr.Get("length").Int()
+				var options js.Value
+				if len(args) == 2 {
+					options = args[1]
+				}
+				opts, err := archive.OptionsFromJS(options)
+				if err != nil {
+					reject.Invoke(js.Global().Get("Error").New("Invalid options: " + err.Error()))
+					return
+				}
+
+				if length > archive.MaxTotalSize {
+					reject.Invoke(js.Global().Get("Error").New("Archive too large (>100MB)"))
+					return
+				}
+
+				data := make([]byte, length)
+				js.CopyBytesToGo(data, jsArr)
+
+				out, err := archive.RepackTgz(data, opts)
+				if err != nil {
+					reject.Invoke(js.Global().Get("Error").New("Failed to repack tgz: " + err.Error()))
+					return
+				}
+
+				resolve.Invoke(archive.BytesToJS(out))
+			}()
+
+			return nil
+		})
+
+		return js.Global().Get("Promise").New(handler)
+	}))
+
+	// -----------------------------------------------------------------------
+	// __wasm_detectArchive(Uint8Array, options?: object) -> Promise<string>
+	// Sniff magic bytes to tell zip, tgz and tar apart.
+	// Returns JSON {type: "zip"|"tgz"|"tar"|"unknown"}.
+	// options is reserved and ignored.
+	// -----------------------------------------------------------------------
+	js.Global().Set("__wasm_detectArchive", js.FuncOf(archive.DetectArchive))
+
+	// Block forever — WASM instance must stay alive to serve calls.
+	select {}
 }
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+	"testing"
+
+	"pkg-inspector/wasm/internal/archive"
+)
+
+// gnuSparseTar returns an old-style GNU sparse entry (typeflag 'S') of
+// logical size realSize whose only data region is data at offset, padded
+// to a block, followed by a regular file next.txt holding "next" and the
+// end-of-archive marker.
+func gnuSparseTar(t *testing.T, name string, offset int, data string, realSize int) []byte {
+	t.Helper()
+	h := make([]byte, 512)
+	copy(h, name)
+	copy(h[100:], "0000644\x00")
+	copy(h[124:], fmt.Sprintf("%011o\x00", len(data)))
+	copy(h[136:], "00000000000\x00")
+	h[156] = tar.TypeGNUSparse
+	copy(h[257:], "ustar  \x00")
+	copy(h[386:], fmt.Sprintf("%011o\x00%011o\x00", offset, len(data)))
+	copy(h[483:], fmt.Sprintf("%011o\x00", realSize))
+	copy(h[148:], "        ")
+	sum := 0
+	for _, c := range h {
+		sum += int(c)
+	}
+	copy(h[148:], fmt.Sprintf("%06o\x00 ", sum))
+
+	b := append(h, data...)
+	b = append(b, make([]byte, (512-len(data)%512)%512)...)
+
+	var rest bytes.Buffer
+	tw := tar.NewWriter(&rest)
+	if err := tw.WriteHeader(&tar.Header{Typeflag: tar.TypeReg, Name: "next.txt", Mode: 0o644, Size: 4}); err != nil {
+		t.Fatal(err)
+	}
+	tw.Write([]byte("next"))
+	tw.Close()
+	return append(b, rest.Bytes()...)
+}
+
+// indexBytes runs indexTar over an uncompressed tar.
+func indexBytes(t *testing.T, data []byte, peek int) []FileIndexEntry {
+	t.Helper()
+	cw := &countingWriter{w: io.Discard}
+	result, err := indexTar(io.TeeReader(bytes.NewReader(data), cw), cw, peek, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return result.Files
+}
+
+// Sparse entries are read eagerly (text) or marked binary (holes, or too
+// large to read), and the entry after one still gets its true offset.
+func TestIndexSparse(t *testing.T) {
+	for _, tc := range []struct {
+		name, data   string
+		offset, size int
+		binary       bool
+		content      string
+	}{
+		{"dense.txt", "hello sparse", 0, 12, false, "hello sparse"},
+		{"holes.bin", "tail", 4096, 4100, true, ""},
+		{"huge.img", "tail", archive.MaxFileContentSize, archive.MaxFileContentSize + 4, true, ""},
+	} {
+		data := gnuSparseTar(t, tc.name, tc.offset, tc.data, tc.size)
+		files := indexBytes(t, data, archive.BinaryCheckSize)
+		if len(files) != 2 {
+			t.Fatalf("%s: %d entries, want 2", tc.name, len(files))
+		}
+		s := files[0]
+		if !s.Sparse || s.Size != int64(tc.size) || s.IsBinary != tc.binary || s.Content != tc.content {
+			t.Errorf("%s indexed as %+v", tc.name, s)
+		}
+		next := files[1]
+		if got := string(data[next.Offset : next.Offset+next.Size]); got != "next" {
+			t.Errorf("%s: next.txt at offset %d reads %q", tc.name, next.Offset, got)
+		}
+	}
+}
+
+// Entry offsets come from the tar layout alone, whatever peekBytes is.
+func TestIndexOffsetsIgnorePeek(t *testing.T) {
+	contents := map[string]string{
+		"empty.txt": "",
+		"a.txt":     "hello",
+		"block.txt": strings.Repeat("b", 512),
+		"long.txt":  strings.Repeat("line\n", 700),
+		"data.bin":  "\x00\x01\x02" + strings.Repeat("x", 1000),
+	}
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	tw.WriteHeader(&tar.Header{Typeflag: tar.TypeDir, Name: "dir/", Mode: 0o755})
+	for _, name := range []string{"empty.txt", "a.txt", "block.txt", "long.txt", "data.bin"} {
+		tw.WriteHeader(&tar.Header{Typeflag: tar.TypeReg, Name: "dir/" + name, Mode: 0o644, Size: int64(len(contents[name]))})
+		tw.Write([]byte(contents[name]))
+	}
+	tw.Close()
+	data := buf.Bytes()
+
+	want := indexBytes(t, data, archive.BinaryCheckSize)
+	for _, f := range want[1:] {
+		if got := string(data[f.Offset : f.Offset+f.Size]); got != contents[path.Base(f.Path)] {
+			t.Errorf("%s at offset %d reads %q", f.Path, f.Offset, got)
+		}
+	}
+	for _, peek := range []int{1, 100, 4096, archive.MaxFileContentSize} {
+		got := indexBytes(t, data, peek)
+		if len(got) != len(want) {
+			t.Fatalf("peek %d: %d entries, want %d", peek, len(got), len(want))
+		}
+		for i := range want {
+			if got[i].Path != want[i].Path || got[i].Offset != want[i].Offset {
+				t.Errorf("peek %d: %s at offset %d, want %s at %d", peek, got[i].Path, got[i].Offset, want[i].Path, want[i].Offset)
+			}
+		}
+	}
+}
+
+// Indexing in the smallest segments gives the index of a single pass:
+// no segment ends between a PAX global header and the entries after it,
+// or splits a long name from its entry.
+func TestIndexResume(t *testing.T) {
+	long := strings.Repeat("very-long-directory-name/", 6) + "file.txt"
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for _, hdr := range []*tar.Header{
+		{Typeflag: tar.TypeXGlobalHeader, Name: "pax_global_header", PAXRecords: map[string]string{"comment": "v1"}},
+		{Typeflag: tar.TypeReg, Name: "a.txt", Size: 3, Format: tar.FormatPAX, PAXRecords: map[string]string{"comment": "x"}},
+		{Typeflag: tar.TypeReg, Name: long, Size: 3, Format: tar.FormatGNU},
+		{Typeflag: tar.TypeSymlink, Name: "link", Linkname: long, Format: tar.FormatGNU},
+		{Typeflag: tar.TypeReg, Name: long + ".pax", Size: 3, Format: tar.FormatPAX},
+		{Typeflag: tar.TypeXGlobalHeader, Name: "pax_global_header", PAXRecords: map[string]string{"comment": "v2"}},
+		{Typeflag: tar.TypeReg, Name: "z.txt", Size: 3},
+	} {
+		if hdr.Typeflag != tar.TypeXGlobalHeader {
+			hdr.Mode = 0o644
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatal(err)
+		}
+		tw.Write([]byte("abc")[:hdr.Size])
+	}
+	tw.Close()
+	data := buf.Bytes()
+
+	want := indexBytes(t, data, archive.BinaryCheckSize)
+	var got []FileIndexEntry
+	var start int64
+	var entries int
+	for segments := 1; ; segments++ {
+		var chunks bytes.Buffer
+		result, err := indexTarFrom(bytes.NewReader(data[start:]), &chunks, start, entries, archive.BinaryCheckSize, 1)
+		if err != nil {
+			t.Fatalf("segment %d from %d: %v", segments, start, err)
+		}
+		if n := len(result.Files); n > 0 && result.Files[n-1].Path == "pax_global_header" && result.Resume != nil {
+			t.Errorf("segment %d ends on a PAX global header", segments)
+		}
+		got = append(got, result.Files...)
+		if result.Resume == nil {
+			break
+		}
+		if result.Resume.Entries != len(got) {
+			t.Errorf("segment %d: resume entries %d, want %d", segments, result.Resume.Entries, len(got))
+		}
+		start, entries = result.Resume.Offset, result.Resume.Entries
+	}
+	if len(got) != len(want) {
+		t.Fatalf("segments indexed %d entries, want %d", len(got), len(want))
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("entry %d: %+v in segments, %+v in one pass", i, got[i], want[i])
+		}
+	}
+}
@@ -1,3 +1,7 @@
 module pkg-inspector/wasm/zip-parser
 
 go 1.25.0
+
+require pkg-inspector/wasm/internal v0.0.0
+
+replace pkg-inspector/wasm/internal => ../internal
@@ -1,147 +1,270 @@
 package main
 
 import (
-	"archive/zip"
-	"bytes"
-	"encoding/base64"
 	"encoding/json"
-	"io"
-	"strings"
 	"syscall/js"
-	"unicode/utf8"
-)
 
-const (
-	maxFileContentSize = 512 * 1024        // 512KB: skip content for larger files
-	maxTotalSize       = 100 * 1024 * 1024 // 100MB: reject archives exceeding this
-	binaryCheckSize    = 512               // bytes to inspect for binary detection
+	"pkg-inspector/wasm/internal/archive"
 )
 
-// ParsedFile represents a single file entry extracted from the archive.
-type ParsedFile struct {
-	Path        string `json:"path"`
-	Size        int64  `json:"size"`
-	IsDir       bool   `json:"isDir"`
-	Content     string `json:"content"`
-	IsBinary    bool   `json:"isBinary"`
-	IsClassFile bool   `json:"isClassFile,omitempty"`
-	RawBase64   string `json:"rawBase64,omitempty"`
-}
+// ---------------------------------------------------------------------------
+// JS exports
+// ---------------------------------------------------------------------------
 
-// ParseResult is the top-level structure returned to JavaScript.
-type ParseResult struct {
-	Files []ParsedFile `json:"files"`
-}
+func main() {
+	// -----------------------------------------------------------------------
+	// __wasm_parseZip(Uint8Array, options?: object) -> Promise<string>
+	// Parse a zip archive from in-memory bytes.
+	// Returns JSON ParseResult.
+	// options: see archive.OptionsFromJS (WasmParseOptions in src/wasm.d.ts)
+	// -----------------------------------------------------------------------
+	js.Global().Set("__wasm_parseZip", js.FuncOf(func(_ js.Value, args []js.Value) any {
+		if len(args) < 1 || len(args) > 2 {
+			return archive.JSError("parseZip requires 1 or 2 arguments (Uint8Array, options?)")
+		}
+
+		handler := js.FuncOf(func(_ js.Value, promise []js.Value) any {
+			resolve := promise[0]
+			reject := promise[1]
+
+			go func() {
+				jsArr := args[0]
+				length := jsArr.Get("length").Int()
+				var options js.Value
+				if len(args) == 2 {
+					options = args[1]
+				}
+
+				opts, err := archive.OptionsFromJS(options)
+				if err != nil {
+					reject.Invoke(js.Global().Get("Error").New("Invalid options: " + err.Error()))
+					return
+				}
+
+				if length > archive.MaxTotalSize {
+					reject.Invoke(js.Global().Get("Error").New("Archive too large (>100MB)"))
+					return
+				}
+
+				data := make([]byte, length)
+				js.CopyBytesToGo(data, jsArr)
+
+				result, err := archive.ParseZipBytes(data, opts)
+				if err != nil {
+					reject.Invoke(js.Global().Get("Error").New("Failed to parse zip: " + err.Error()))
+					return
+				}
+
+				jsonBytes, err := json.Marshal(result)
+				if err != nil {
+					reject.Invoke(js.Global().Get("Error").New("Failed to serialize result: " + err.Error()))
+					return
+				}
+
+				resolve.Invoke(string(jsonBytes))
+			}()
+
+			return nil
+		})
+
+		return js.Global().Get("Promise").New(handler)
+	}))
 
-// isBinaryContent detects binary data by checking for null bytes
-// and invalid UTF-8 sequences in the first binaryCheckSize bytes.
-func isBinaryContent(data []byte) bool {
-	n := len(data)
-	if n > binaryCheckSize {
-		n = binaryCheckSize
-	}
-	for i := 0; i < n; i++ {
-		if data[i] == 0 {
-			return true
+	// -----------------------------------------------------------------------
+	// __wasm_parseZipStreaming(Uint8Array, onEntry: Function, options?: object) -> Promise<number>
+	// Like parseZip, but calls onEntry(jsonString) with each ParsedFile as
+	// it is read instead of building one large ParseResult. Resolves with
+	// the number of entries delivered. A throw from onEntry stops the
+	// walk and rejects.
+	// options: same as parseZip
+	// -----------------------------------------------------------------------
+	js.Global().Set("__wasm_parseZipStreaming", js.FuncOf(func(_ js.Value, args []js.Value) any {
+		if len(args) < 2 || len(args) > 3 {
+			return archive.JSError("parseZipStreaming requires 2 or 3 arguments (Uint8Array, onEntry, options?)")
 		}
-	}
-	return !utf8.Valid(data[:n])
-}
 
-// parseZipBytes parses a zip archive from an in-memory byte slice.
-func parseZipBytes(data []byte) (*ParseResult, error) {
-	r, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
-	if err != nil {
-		return nil, err
-	}
-
-	result := &ParseResult{
-		Files: make([]ParsedFile, 0, len(r.File)),
-	}
-
-	for _, f := range r.File {
-		entry := ParsedFile{
-			Path:  f.Name,
-			Size:  int64(f.UncompressedSize64),
-			IsDir: f.FileInfo().IsDir(),
+		handler := js.FuncOf(func(_ js.Value, promise []js.Value) any {
+			resolve := promise[0]
+			reject := promise[1]
+
+			go func() {
+				jsArr := args[0]
+				onEntry := args[1]
+				length := jsArr.Get("length").Int()
+				var options js.Value
+				if len(args) == 3 {
+					options = args[2]
+				}
+
+				if onEntry.Type() != js.TypeFunction {
+					reject.Invoke(js.Global().Get("Error").New("onEntry must be a function"))
+					return
+				}
+				opts, err := archive.OptionsFromJS(options)
+				if err != nil {
+					reject.Invoke(js.Global().Get("Error").New("Invalid options: " + err.Error()))
+					return
+				}
+
+				if length > archive.MaxTotalSize {
+					reject.Invoke(js.Global().Get("Error").New("Archive too large (>100MB)"))
+					return
+				}
+
+				data := make([]byte, length)
+				js.CopyBytesToGo(data, jsArr)
+
+				count := 0
+				err = archive.WalkZipBytes(data, opts, func(entry archive.ParsedFile) error {
+					jsonBytes, err := json.Marshal(entry)
+					if err != nil {
+						return err
+					}
+					if err := archive.InvokeCallback(onEntry, "onEntry", string(jsonBytes)); err != nil {
+						return err
+					}
+					count++
+					return nil
+				})
+				if err != nil {
+					reject.Invoke(js.Global().Get("Error").New("Failed to parse zip: " + err.Error()))
+					return
+				}
+
+				resolve.Invoke(count)
+			}()
+
+			return nil
+		})
+
+		return js.Global().Get("Promise").New(handler)
+	}))
+
+	// -----------------------------------------------------------------------
+	// __wasm_listPaths(Uint8Array, options?: object) -> Promise<string>
+	// The cheapest enumeration: entry names from the central directory,
+	// with no sizes, binary detection or content.
+	// Returns a JSON array of path strings.
+	// options: the path filters of parseZip (pathRegex, include, exclude)
+	// -----------------------------------------------------------------------
+	js.Global().Set("__wasm_listPaths", js.FuncOf(func(_ js.Value, args []js.Value) any {
+		if len(args) < 1 || len(args) > 2 {
+			return archive.JSError("listPaths requires 1 or 2 arguments (Uint8Array, options?)")
 		}
 
-		if !entry.IsDir {
-			if entry.Size > maxFileContentSize {
-				entry.IsBinary = true
-			} else {
-				rc, err := f.Open()
+		handler := js.FuncOf(func(_ js.Value, promise []js.Value) any {
+			resolve := promise[0]
+			reject := promise[1]
+
+			go func() {
+				jsArr := args[0]
+				length := jsArr.Get("length").Int()
+				var options js.Value
+				if len(args) == 2 {
+					options = args[1]
+				}
+				opts, err := archive.OptionsFromJS(options)
 				if err != nil {
-					return nil, err
+					reject.Invoke(js.Global().Get("Error").New("Invalid options: " + err.Error()))
+					return
+				}
+
+				if length > archive.MaxTotalSize {
+					reject.Invoke(js.Global().Get("Error").New("Archive too large (>100MB)"))
+					return
 				}
 
-				buf, err := io.ReadAll(rc)
-				rc.Close()
+				data := make([]byte, length)
+				js.CopyBytesToGo(data, jsArr)
+
+				paths, err := archive.ListZipPaths(data, opts)
 				if err != nil {
-					return nil, err
+					reject.Invoke(js.Global().Get("Error").New("Failed to list zip: " + err.Error()))
+					return
 				}
 
-				// Special handling for .class files: pass raw bytes as base64
-				if strings.HasSuffix(strings.ToLower(f.Name), ".class") {
-					entry.IsBinary = true
-					entry.IsClassFile = true
-					entry.RawBase64 = base64.StdEncoding.EncodeToString(buf)
-				} else if isBinaryContent(buf) {
-					entry.IsBinary = true
-				} else {
-					entry.Content = string(buf)
+				jsonBytes, err := json.Marshal(paths)
+				if err != nil {
+					reject.Invoke(js.Global().Get("Error").New("Failed to serialize result: " + err.Error()))
+					return
 				}
-			}
+
+				resolve.Invoke(string(jsonBytes))
+			}()
+
+			return nil
+		})
+
+		return js.Global().Get("Promise").New(handler)
+	}))
+
+	// -----------------------------------------------------------------------
+	// __wasm_checkZip(Uint8Array, options?: object) -> Promise<string>
+	// Decompress every entry and validate its stored CRC-32.
+	// Returns JSON {ok, corruptEntries: [{path, error}], totalEntries}.
+	// options: the path filters of parseZip, limiting which entries are checked
+	// -----------------------------------------------------------------------
+	js.Global().Set("__wasm_checkZip", js.FuncOf(func(_ js.Value, args []js.Value) any {
+		if len(args) < 1 || len(args) > 2 {
+			return archive.JSError("checkZip requires 1 or 2 arguments (Uint8Array, options?)")
 		}
 
-		result.Files = append(result.Files, entry)
-	}
+		handler := js.FuncOf(func(_ js.Value, promise []js.Value) any {
+			resolve := promise[0]
+			reject := promise[1]
 
-	return result, nil
-}
+			go func() {
+				jsArr := args[0]
+				length := jsArr.Get("length").Int()
+				var options js.Value
+				if len(args) == 2 {
+					options = args[1]
+				}
+				opts, err := archive.OptionsFromJS(options)
+				if err != nil {
+					reject.Invoke(js.Global().Get("Error").New("Invalid options: " + err.Error()))
+					return
+				}
 
-// Simple int-to-string without importing strconv (keeps binary small).
-func itoa(n int) string {
-	if n == 0 {
-		return "0"
-	}
-	buf := [20]byte{}
-	i := len(buf) - 1
-	neg := false
-	if n < 0 {
-		neg = true
-		n = -n
-	}
-	for n > 0 {
-		buf[i] = byte('0' + n%10)
-		i--
-		n /= 10
-	}
-	if neg {
-		buf[i] = '-'
-		i--
-	}
-	return string(buf[i+1:])
-}
+				if length > archive.MaxTotalSize {
+					reject.Invoke(js.Global().Get("Error").New("Archive too large (>100MB)"))
+					return
+				}
 
-func jsError(msg string) any {
-	return js.Global().Get("Promise").Call("reject",
-		js.Global().Get("Error").New(msg))
-}
+				data := make([]byte, length)
+				js.CopyBytesToGo(data, jsArr)
 
-// ---------------------------------------------------------------------------
-// JS exports
-// ---------------------------------------------------------------------------
+				result, err := archive.CheckZipBytes(data, opts)
+				if err != nil {
+					reject.Invoke(js.Global().Get("Error").New("Failed to check zip: " + err.Error()))
+					return
+				}
+
+				jsonBytes, err := json.Marshal(result)
+				if err != nil {
+					reject.Invoke(js.Global().Get("Error").New("Failed to serialize result: " + err.Error()))
+					return
+				}
+
+				resolve.Invoke(string(jsonBytes))
+			}()
+
+			return nil
+		})
+
+		return js.Global().Get("Promise").New(handler)
+	}))
 
-func main() {
 	// -----------------------------------------------------------------------
-	// __wasm_parseZip(Uint8Array) -> Promise<string>
-	// Parse a zip archive from in-memory bytes.
-	// Returns JSON ParseResult.
+	// __wasm_jarClassVersions(Uint8Array, options?: object) -> Promise<string>
+	// Histogram of the Java releases targeted by a jar's .class files,
+	// read from each class header without parsing it.
+	// Returns JSON {"8": 120, "11": 45, ...}.
+	// options: the path filters of parseZip (pathRegex, include, exclude)
 	// -----------------------------------------------------------------------
-	js.Global().Set("__wasm_parseZip", js.FuncOf(func(_ js.Value, args []js.Value) any {
-		if len(args) != 1 {
-			return jsError("parseZip requires exactly 1 argument (Uint8Array)")
+	js.Global().Set("__wasm_jarClassVersions", js.FuncOf(func(_ js.Value, args []js.Value) any {
+		if len(args) < 1 || len(args) > 2 {
+			return archive.JSError("jarClassVersions requires 1 or 2 arguments (Uint8Array, options?)")
 		}
 
 		handler := js.FuncOf(func(_ js.Value, promise []js.Value) any {
@@ -151,8 +274,17 @@ func main() {
 			go func() {
 				jsArr := args[0]
 				length := jsArr.Get("length").Int()
+				var options js.Value
+				if len(args) == 2 {
+					options = args[1]
+				}
+				opts, err := archive.OptionsFromJS(options)
+				if err != nil {
+					reject.Invoke(js.Global().Get("Error").New("Invalid options: " + err.Error()))
+					return
+				}
 
-				if length > maxTotalSize {
+				if length > archive.MaxTotalSize {
 					reject.Invoke(js.Global().Get("Error").New("Archive too large (>100MB)"))
 					return
 				}
@@ -160,9 +292,9 @@ func main() {
 				data := make([]byte, length)
 				js.CopyBytesToGo(data, jsArr)
 
-				result, err := parseZipBytes(data)
+				result, err := archive.JarClassVersions(data, opts)
 				if err != nil {
-					reject.Invoke(js.Global().Get("Error").New("Failed to parse zip: " + err.Error()))
+					reject.Invoke(js.Global().Get("Error").New("Failed to read jar: " + err.Error()))
 					return
 				}
 
@@ -181,6 +313,66 @@ func main() {
 		return js.Global().Get("Promise").New(handler)
 	}))
 
+	// -----------------------------------------------------------------------
+	// __wasm_zipToTgz(Uint8Array, options?: object) -> Promise<Uint8Array>
+	// Convert a zip archive into an equivalent gzip-compressed tar,
+	// keeping paths, sizes, permission bits, modtimes and symlinks.
+	// Returns the .tgz bytes.
+	// options: the path filters of parseZip, limiting which entries are copied
+	// -----------------------------------------------------------------------
+	js.Global().Set("__wasm_zipToTgz", js.FuncOf(func(_ js.Value, args []js.Value) any {
+		if len(args) < 1 || len(args) > 2 {
+			return archive.JSError("zipToTgz requires 1 or 2 arguments (Uint8Array, options?)")
+		}
+
+		handler := js.FuncOf(func(_ js.Value, promise []js.Value) any {
+			resolve := promise[0]
+			reject := promise[1]
+
+			go func() {
+				jsArr := args[0]
+				length := jsArr.Get("length").Int()
+				var options js.Value
+				if len(args) == 2 {
+					options = args[1]
+				}
+				opts, err := archive.OptionsFromJS(options)
+				if err != nil {
+					reject.Invoke(js.Global().Get("Error").New("Invalid options: " + err.Error()))
+					return
+				}
+
+				if length > archive.MaxTotalSize {
+					reject.Invoke(js.Global().Get("Error").New("Archive too large (>100MB)"))
+					return
+				}
+
+				data := make([]byte, length)
+				js.CopyBytesToGo(data, jsArr)
+
+				out, err := archive.ZipToTgz(data, opts)
+				if err != nil {
+					reject.Invoke(js.Global().Get("Error").New("Failed to convert zip: " + err.Error()))
+					return
+				}
+
+				resolve.Invoke(archive.BytesToJS(out))
+			}()
+
+			return nil
+		})
+
+		return js.Global().Get("Promise").New(handler)
+	}))
+
+	// -----------------------------------------------------------------------
+	// __wasm_detectArchive(Uint8Array, options?: object) -> Promise<string>
+	// Sniff magic bytes to tell zip, tgz and tar apart.
+	// Returns JSON {type: "zip"|"tgz"|"tar"|"unknown"}.
+	// options is reserved and ignored.
+	// -----------------------------------------------------------------------
+	js.Global().Set("__wasm_detectArchive", js.FuncOf(archive.DetectArchive))
+
 	// Block forever — WASM instance must stay alive to serve calls.
 	select {}
 }